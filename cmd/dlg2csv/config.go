@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/config"
+)
+
+// findConfig returns the config file to use: the -config flag, or
+// dlg2csv.yaml in the mod folder or the current directory.
+func findConfig(path, modRoot string) string {
+	if path != "" {
+		return path
+	}
+	if modRoot != "" {
+		dir := modRoot
+		if st, err := os.Stat(modRoot); err == nil && !st.IsDir() {
+			dir = filepath.Dir(modRoot)
+		}
+		if p := config.Find(dir); p != "" {
+			return p
+		}
+	}
+	return config.Find(".")
+}
+
+// applyConfig sets the flags of fs that weren't given on the command line
+// from cfg, so flags always override the config file. withArgs disables
+// the mod discovery when <traDir> <dDir> are given. override replaces the
+// config value used for a flag, for commands giving a flag another meaning.
+func applyConfig(fs *flag.FlagSet, cfg *config.Config, withArgs bool, override map[string]string) error {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	values := map[string]string{
+		"lang":            cfg.Source.Lang,
+		"tp2":             cfg.TP2,
+		"tlk":             cfg.TLK,
+		"override":        cfg.Override,
+		"map-file":        cfg.MapFile,
+		"encoding":        cfg.Source.Encoding,
+		"target-encoding": cfg.Target.Encoding,
+		"out":             cfg.Output.Dir,
+		"dialect":         cfg.Output.Dialect,
+		"delimiter":       cfg.Output.Delimiter,
+		"quote":           cfg.Output.Quote,
+	}
+	if !withArgs {
+		values["mod"] = cfg.Mod
+	}
+	if cfg.Output.BOM != nil {
+		values["bom"] = strconv.FormatBool(*cfg.Output.BOM)
+	}
+	if cfg.Output.CRLF != nil {
+		values["crlf"] = strconv.FormatBool(*cfg.Output.CRLF)
+	}
+	if cfg.HasColumn(config.ColumnSource) {
+		values["source"] = "true"
+	}
+	if cfg.HasColumn(config.ColumnContext) {
+		values["context"] = "true"
+	}
+	for name, v := range override {
+		values[name] = v
+	}
+
+	for name, v := range values {
+		if v == "" || set[name] || fs.Lookup(name) == nil {
+			continue
+		}
+		if err := fs.Set(name, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/charset"
+	"github.com/maciejjwojcik/dlg2csv/internal/tra"
+)
+
+func runDiff(args []string) int {
+	fs := newFlagSet("diff")
+	encoding := fs.String("encoding", charset.Auto, "encoding of the .tra files: auto, utf-8, cp1250, cp1251, ...")
+	exitCode := fs.Bool("exit-code", false, fmt.Sprintf("exit with %d if there are differences", exitFailure))
+	if code, done := parse(fs, args); done {
+		return code
+	}
+	if fs.NArg() != 2 {
+		return fail(usagef("expected <oldTraDir> <newTraDir>, got %d arguments", fs.NArg()))
+	}
+	enc, err := charset.Normalize(*encoding)
+	if err != nil {
+		return fail(usagef("%v", err))
+	}
+
+	old, err := tra.ParseDirWithOptions(fs.Arg(0), tra.Options{Encoding: enc, Logger: logger})
+	if err != nil {
+		return fail(fmt.Errorf("parse .tra: %w", err))
+	}
+	cur, err := tra.ParseDirWithOptions(fs.Arg(1), tra.Options{Encoding: enc, Logger: logger})
+	if err != nil {
+		return fail(fmt.Errorf("parse .tra: %w", err))
+	}
+
+	changes := tra.Diff(old, cur)
+	for _, c := range changes {
+		switch c.Kind {
+		case tra.Added:
+			fmt.Printf("%s %s.tra @%s: %s\n", c.Kind, c.File, c.ID, strconv.Quote(c.New))
+		case tra.Removed:
+			fmt.Printf("%s %s.tra @%s: %s\n", c.Kind, c.File, c.ID, strconv.Quote(c.Old))
+		default:
+			fmt.Printf("%s %s.tra @%s: %s -> %s\n", c.Kind, c.File, c.ID, strconv.Quote(c.Old), strconv.Quote(c.New))
+		}
+	}
+	fmt.Printf("%d changes\n", len(changes))
+
+	if *exitCode && len(changes) > 0 {
+		return exitFailure
+	}
+	return exitOK
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"io"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/dump"
+)
+
+func runDump(args []string) int {
+	fs := newFlagSet("dump")
+	src := addSourceFlags(fs)
+	format := fs.String("format", "json", "output format: json (one document) or ndjson (one line per occurrence and string)")
+	outPath := fs.String("o", "", "file to write to (default: standard output)")
+	if code, done := parse(fs, args); done {
+		return code
+	}
+	if *format != "json" && *format != "ndjson" {
+		return fail(usagef("unknown format %q, want json or ndjson", *format))
+	}
+
+	cfg, err := src.loadConfig(fs, nil)
+	if err != nil {
+		return fail(err)
+	}
+	m, err := src.read(fs.Args(), cfg)
+	if err != nil {
+		return fail(err)
+	}
+
+	err = writeOutput(*outPath, func(w io.Writer) error {
+		if *format == "ndjson" {
+			return dump.WriteNDJSON(w, m.dialogs, m.tras)
+		}
+		return dump.WriteJSON(w, m.dialogs, m.tras)
+	})
+	if err != nil {
+		return fail(err)
+	}
+	return exitOK
+}
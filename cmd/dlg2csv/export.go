@@ -0,0 +1,168 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/charset"
+	"github.com/maciejjwojcik/dlg2csv/internal/csv"
+	"github.com/maciejjwojcik/dlg2csv/internal/dlg"
+	"github.com/maciejjwojcik/dlg2csv/internal/tlk"
+	"github.com/maciejjwojcik/dlg2csv/internal/tm"
+	"github.com/maciejjwojcik/dlg2csv/internal/tra"
+)
+
+func runExport(args []string) int {
+	fs := newFlagSet("export")
+	src := addSourceFlags(fs)
+	source := fs.Bool("source", false, "add a Source column (file:line) to the CSV")
+	context := fs.Bool("context", false, "add a Context column with vanilla background (e.g. dialogf.tlk variants)")
+	tlkPath := fs.String("tlk", "", "path to the game's dialog.tlk (or its language folder), used to show #strref texts")
+	tlkEnc := fs.String("tlk-encoding", charset.Auto, "encoding of the -tlk files: auto (UTF-8, then the code page of the language folder), utf-8, cp1250, cp1251, ...")
+	override := fs.String("override", "", "game override folder with .dlg files; with -context shows the vanilla states targeted by INTERJECT/EXTEND")
+	outDir := fs.String("out", "", "folder to write the CSV files to (default: current directory)")
+	tmCSV := fs.String("tm-csv", "", "translation memory: folder with translated CSV sheets, e.g. of an earlier release")
+	tmTra := fs.String("tm-tra", "", "translation memory: folder with translated .tra files matching the source ones by @id")
+	tmTMX := fs.String("tm-tmx", "", "translation memory: TMX file, e.g. exported by a CAT tool")
+	tmScore := fs.Float64("tm-min-score", tm.DefaultMinScore, "lowest similarity (0-1) of a translation memory suggestion")
+	df := addDialectFlags(fs)
+	if code, done := parse(fs, args); done {
+		return code
+	}
+	if *tmScore < 0 || *tmScore > 1 {
+		return fail(usagef("-tm-min-score must be between 0 and 1, got %g", *tmScore))
+	}
+	tlkEncoding, err := charset.Normalize(*tlkEnc)
+	if err != nil {
+		return fail(usagef("%v", err))
+	}
+
+	cfg, err := src.loadConfig(fs, nil)
+	if err != nil {
+		return fail(err)
+	}
+	dialect, err := df.dialect(fs)
+	if err != nil {
+		return fail(err)
+	}
+	m, err := src.read(fs.Args(), cfg)
+	if err != nil {
+		return fail(err)
+	}
+
+	if *outDir != "" {
+		if err := os.MkdirAll(*outDir, 0o755); err != nil {
+			return fail(err)
+		}
+	}
+
+	opts := csv.Options{SourceColumn: *source, ContextColumn: *context, Tras: m.traMap, Dialect: dialect, OutDir: *outDir, Logger: logger}
+	if *tlkPath != "" {
+		logger.Info("reading TLK", "path", *tlkPath)
+		talk, err := tlk.OpenTalkWithOptions(*tlkPath, tlk.Options{Encoding: tlkEncoding})
+		if err != nil {
+			return fail(fmt.Errorf("read TLK: %w", err))
+		}
+		opts.Strrefs = talk
+	}
+	setup, err := src.setupIndex()
+	if err != nil {
+		return fail(err)
+	}
+	if setup != nil {
+		opts.Setup = setup
+	}
+	if *tmCSV != "" || *tmTra != "" || *tmTMX != "" {
+		mem := tm.New()
+		mem.MinScore = *tmScore
+		if *tmCSV != "" {
+			logger.Info("reading translation memory", "dir", *tmCSV)
+			if err := csv.LoadMemory(mem, *tmCSV, csv.Dialect{}); err != nil {
+				return fail(fmt.Errorf("translation memory: %w", err))
+			}
+		}
+		if *tmTra != "" {
+			logger.Info("reading translation memory", "dir", *tmTra)
+			target, err := tra.ParseDirWithOptions(*tmTra, tra.Options{Logger: logger})
+			if err != nil {
+				return fail(fmt.Errorf("translation memory: %w", err))
+			}
+			mem.AddTras(m.tras, target)
+		}
+		if *tmTMX != "" {
+			doc, err := readTMX(*tmTMX, "")
+			if err != nil {
+				return fail(fmt.Errorf("translation memory: %w", err))
+			}
+			doc.AddTo(mem)
+		}
+		logger.Info("translation memory loaded", "strings", mem.Len())
+		opts.Memory = mem
+	}
+	if *override != "" {
+		logger.Info("indexing .dlg files", "dir", *override)
+		vanilla, err := dlg.OpenOverride(*override)
+		if err != nil {
+			return fail(fmt.Errorf("read override: %w", err))
+		}
+		opts.Vanilla = vanilla
+	}
+	if _, err := csv.ExportWithOptions(m.dialogs, m.tras, opts); err != nil {
+		return fail(fmt.Errorf("export: %w", err))
+	}
+
+	logger.Info("done")
+	return exitOK
+}
+
+// dialectFlags select the CSV dialect written by export and merge.
+type dialectFlags struct {
+	name      *string
+	delimiter *string
+	bom       *bool
+	crlf      *bool
+	quoting   *string
+}
+
+func addDialectFlags(fs *flag.FlagSet) *dialectFlags {
+	return &dialectFlags{
+		name:      fs.String("dialect", "default", "CSV preset: default, excel, excel-eu (';' separated) or gsheets"),
+		delimiter: fs.String("delimiter", "", "CSV field separator, overrides -dialect (e.g. ';' or '\\t')"),
+		bom:       fs.Bool("bom", false, "start CSV files with a UTF-8 byte order mark, overrides -dialect"),
+		crlf:      fs.Bool("crlf", false, "end CSV lines with CRLF, overrides -dialect"),
+		quoting:   fs.String("quote", "", "CSV quoting, overrides -dialect: minimal or all"),
+	}
+}
+
+// dialect returns the preset adjusted by the flags given explicitly.
+func (df *dialectFlags) dialect(fs *flag.FlagSet) (csv.Dialect, error) {
+	dialect, err := csv.DialectByName(*df.name)
+	if err != nil {
+		return dialect, usagef("%v", err)
+	}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "delimiter":
+			sep := []rune(strings.ReplaceAll(*df.delimiter, `\t`, "\t"))
+			if len(sep) != 1 {
+				err = usagef("-delimiter must be a single character, got %q", *df.delimiter)
+			} else {
+				dialect.Delimiter = sep[0]
+			}
+		case "bom":
+			dialect.BOM = *df.bom
+		case "crlf":
+			dialect.CRLF = *df.crlf
+		case "quote":
+			dialect.Quoting = csv.Quoting(*df.quoting)
+		}
+	})
+	if err == nil {
+		if verr := dialect.Validate(); verr != nil {
+			err = usagef("%v", verr)
+		}
+	}
+	return dialect, err
+}
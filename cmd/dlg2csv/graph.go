@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/csv"
+	"github.com/maciejjwojcik/dlg2csv/internal/d"
+	"github.com/maciejjwojcik/dlg2csv/internal/graph"
+	"github.com/maciejjwojcik/dlg2csv/internal/mapping"
+)
+
+func runGraph(args []string) int {
+	fs := newFlagSet("graph")
+	src := addSourceFlags(fs)
+	dName := fs.String("d", "", "draw only this .d file, e.g. bdnpc.d (default: all)")
+	format := fs.String("format", "mermaid", "output format: mermaid or dot")
+	outPath := fs.String("o", "", "file to write the graph to (default: standard output)")
+	if code, done := parse(fs, args); done {
+		return code
+	}
+	if *format != "mermaid" && *format != "dot" {
+		return fail(usagef("unknown format %q, want mermaid or dot", *format))
+	}
+
+	cfg, err := src.loadConfig(fs, nil)
+	if err != nil {
+		return fail(err)
+	}
+	m, err := src.read(fs.Args(), cfg)
+	if err != nil {
+		return fail(err)
+	}
+
+	keys, occ, err := m.selectDialogs(*dName)
+	if err != nil {
+		return fail(err)
+	}
+	g := graph.Build(occ, textResolver(m))
+
+	name := "dialogs"
+	if len(keys) == 1 {
+		name = keys[0]
+	}
+	err = writeOutput(*outPath, func(w io.Writer) error {
+		if *format == "dot" {
+			return g.DOT(w, name)
+		}
+		return g.Mermaid(w)
+	})
+	if err != nil {
+		return fail(err)
+	}
+	return exitOK
+}
+
+// selectDialogs returns the keys and occurrences of the .d file named
+// dName, or of all .d files, sorted, if it is empty.
+func (m *modFiles) selectDialogs(dName string) ([]string, []d.TextOccurrence, error) {
+	var keys []string
+	if dName != "" {
+		k := mapping.Key(dName)
+		if _, ok := m.dialogs[k]; !ok {
+			return nil, nil, fmt.Errorf("no .d file %s", dName)
+		}
+		keys = []string{k}
+	} else {
+		for k := range m.dialogs {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+	}
+
+	var occ []d.TextOccurrence
+	for _, k := range keys {
+		occ = append(occ, m.dialogs[k]...)
+	}
+	return keys, occ, nil
+}
+
+// textResolver returns the source text of an occurrence: its .tra string
+// or "#strref" for vanilla lines.
+func textResolver(m *modFiles) func(d.TextOccurrence) string {
+	res := csv.TraResolver{Tras: m.tras, Mapping: m.traMap}
+	return func(o d.TextOccurrence) string {
+		switch {
+		case o.StrRef != nil:
+			return fmt.Sprintf("#%d", *o.StrRef)
+		case o.TraID == nil:
+			return ""
+		}
+		if t, ok := res.TraFor(mapping.Key(o.Pos.File), *o.TraID); ok {
+			return m.tras[t].Texts[strconv.Itoa(*o.TraID)]
+		}
+		return fmt.Sprintf("@%d", *o.TraID)
+	}
+}
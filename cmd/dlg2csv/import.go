@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/charset"
+	"github.com/maciejjwojcik/dlg2csv/internal/config"
+	"github.com/maciejjwojcik/dlg2csv/internal/csv"
+	"github.com/maciejjwojcik/dlg2csv/internal/tmx"
+	"github.com/maciejjwojcik/dlg2csv/internal/tra"
+	"github.com/maciejjwojcik/dlg2csv/internal/validate"
+)
+
+func runImport(args []string) int {
+	fs := newFlagSet("import")
+	src := addSourceFlags(fs)
+	csvDir := fs.String("csv", ".", "folder with the translated CSV files")
+	tmxPath := fs.String("tmx", "", "read the translations from a TMX file (e.g. from a CAT tool) instead of the CSV files")
+	xliffPath := fs.String("xliff", "", "read the translations from an XLIFF 2.0 file (e.g. from a CAT tool) instead of the CSV files")
+	poPath := fs.String("po", "", "read the translations from a gettext PO file (e.g. from Weblate) instead of the CSV files")
+	outDir := fs.String("out", "", "folder to write the translated .tra files to (required)")
+	outEnc := fs.String("out-encoding", charset.Auto, "encoding of the written .tra files; auto keeps the encoding of each source file, or uses the code page of the -out language folder")
+	female := fs.String("female", "", "female variants of the translations: source (only where the source has one) or optional (default: by the -out language folder)")
+	skip := fs.Bool("skip-untranslated", false, "leave untranslated strings out instead of keeping the source text")
+	if code, done := parse(fs, args); done {
+		return code
+	}
+
+	cfg, err := src.loadConfig(fs, func(cfg *config.Config) map[string]string {
+		return map[string]string{"csv": cfg.Output.Dir, "out": cfg.Target.Tra, "out-encoding": cfg.Target.Encoding, "female": cfg.Target.Female}
+	})
+	if err != nil {
+		return fail(err)
+	}
+	if *outDir == "" {
+		return fail(usagef("-out is required"))
+	}
+	if countSet(*tmxPath, *xliffPath, *poPath) > 1 {
+		return fail(usagef("-tmx, -xliff and -po are mutually exclusive"))
+	}
+	enc, err := charset.Normalize(*outEnc)
+	if err != nil {
+		return fail(usagef("%v", err))
+	}
+	optional, err := femaleOptional(*female, *outDir)
+	if err != nil {
+		return fail(err)
+	}
+	m, err := src.read(fs.Args(), cfg)
+	if err != nil {
+		return fail(err)
+	}
+
+	var translations csv.Translations
+	switch {
+	case *tmxPath != "":
+		doc, err := readTMX(*tmxPath, tmx.LangCode(*outDir))
+		if err != nil {
+			return fail(fmt.Errorf("import: %w", err))
+		}
+		var skipped int
+		translations, skipped = doc.Translations(m.tras, filepath.Base(*tmxPath))
+		if skipped > 0 {
+			logger.Warn("TMX units skipped, their source string changed or is gone, or they lack the male variant", "units", skipped)
+		}
+	case *xliffPath != "":
+		doc, err := readXLIFF(*xliffPath)
+		if err != nil {
+			return fail(fmt.Errorf("import: %w", err))
+		}
+		var skipped int
+		translations, skipped = doc.Translations(m.tras, filepath.Base(*xliffPath))
+		if skipped > 0 {
+			logger.Warn("XLIFF units skipped, their source string changed or is gone, or they lack the male variant", "units", skipped)
+		}
+	case *poPath != "":
+		doc, err := readPO(*poPath)
+		if err != nil {
+			return fail(fmt.Errorf("import: %w", err))
+		}
+		var skipped int
+		translations, skipped = doc.Translations(m.tras, filepath.Base(*poPath))
+		if skipped > 0 {
+			logger.Warn("PO entries skipped, their source string changed or is gone, or they lack the male variant", "entries", skipped)
+		}
+	default:
+		logger.Info("reading translations", "dir", *csvDir)
+		translations, err = csv.Import(m.dialogs, m.tras, *csvDir, csv.Options{Tras: m.traMap, Logger: logger})
+		if err != nil {
+			return fail(fmt.Errorf("import: %w", err))
+		}
+	}
+	for _, f := range validate.CheckTranslations(m.tras, validate.Options{Translations: translations, FemaleOptional: optional}) {
+		logger.Warn(f.Msg, "pos", f.Pos.String(), "rule", f.Rule)
+	}
+
+	if err := os.MkdirAll(*outDir, 0o755); err != nil {
+		return fail(err)
+	}
+
+	keys := make([]string, 0, len(m.tras))
+	for k := range m.tras {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		source := m.tras[k]
+		entries, missing := csv.TraEntries(source, translations[k], *skip)
+
+		fileEnc := enc
+		if fileEnc == charset.Auto {
+			fileEnc = charset.ForTarget(source.Encoding, source.ASCII(), *outDir)
+		}
+
+		var buf bytes.Buffer
+		if err := tra.Write(&buf, entries, fileEnc); err != nil {
+			return fail(fmt.Errorf("%s.tra: %w", k, err))
+		}
+		path := filepath.Join(*outDir, traFileName(k, source))
+		if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+			return fail(err)
+		}
+		logger.Info("writing .tra", "file", path, "encoding", fileEnc, "translated", len(source.Texts)-len(missing), "strings", len(source.Texts))
+	}
+
+	logger.Info("done")
+	return exitOK
+}
+
+// countSet counts the non-empty values, e.g. of exclusive flags.
+func countSet(values ...string) int {
+	n := 0
+	for _, v := range values {
+		if v != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// traFileName is the name of the source .tra file, as recorded in its
+// positions, or key + ".tra".
+func traFileName(key string, t tra.Tra) string {
+	for _, p := range t.Pos {
+		if p.File != "" {
+			return p.File
+		}
+	}
+	return key + ".tra"
+}
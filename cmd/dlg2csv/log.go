@@ -0,0 +1,64 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// logger receives the progress messages and warnings of all commands. It
+// writes to standard error, so results printed to standard output can be
+// piped; parse sets it up from the logging flags.
+var logger = newLogger(os.Stderr, slog.LevelInfo, "text")
+
+// logFlags are the logging flags shared by all commands; only one command
+// runs per process, so they live in the package-level logging.
+type logFlags struct {
+	quiet   bool
+	verbose bool
+	format  string
+}
+
+var logging logFlags
+
+func addLogFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&logging.quiet, "quiet", false, "only log warnings and errors")
+	fs.BoolVar(&logging.verbose, "verbose", false, "also log debug messages, e.g. input the parsers skip")
+	fs.StringVar(&logging.format, "log-format", "text", "log format: text or json")
+}
+
+// logger returns the logger selected by the flags.
+func (l logFlags) logger(w io.Writer) (*slog.Logger, error) {
+	if l.quiet && l.verbose {
+		return nil, usagef("-quiet and -verbose are mutually exclusive")
+	}
+	if l.format != "text" && l.format != "json" {
+		return nil, usagef("unknown -log-format %q, want text or json", l.format)
+	}
+
+	level := slog.LevelInfo
+	switch {
+	case l.quiet:
+		level = slog.LevelWarn
+	case l.verbose:
+		level = slog.LevelDebug
+	}
+	return newLogger(w, level, l.format), nil
+}
+
+func newLogger(w io.Writer, level slog.Level, format string) *slog.Logger {
+	if format == "json" {
+		return slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level}))
+	}
+	return slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{
+		Level: level,
+		// timestamps only clutter the terminal
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+}
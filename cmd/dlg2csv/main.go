@@ -1,55 +1,168 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"strings"
+)
 
-	"github.com/maciejjwojcik/dlg2csv/internal/csv"
-	"github.com/maciejjwojcik/dlg2csv/internal/d"
-	"github.com/maciejjwojcik/dlg2csv/internal/tra"
+// Exit codes shared by all commands.
+const (
+	exitOK      = 0
+	exitFailure = 1 // the command failed or found problems
+	exitUsage   = 2 // invalid command line
 )
 
-func usage() {
-	fmt.Fprintf(os.Stderr, "Usage:\n  %s                # read .tra and .d from current directory\n  %s <traDir> <dDir> # read .tra from traDir and .d from dDir\n", os.Args[0], os.Args[0])
+type command struct {
+	name    string
+	args    string // positional arguments, for the usage line
+	summary string
+	run     func(args []string) int
 }
 
-func main() {
-	args := os.Args[1:]
+var commands []command
 
-	traDir := "."
-	dDir := "."
+// commands is set in init: the commands refer back to it for their usage.
+func init() {
+	commands = []command{
+		{"export", "[<traDir> <dDir>]", "export .d and .tra files to CSV sheets for translators", runExport},
+		{"import", "[<traDir> <dDir>]", "write translated .tra files from the filled-in CSV sheets", runImport},
+		{"validate", "[<traDir> <dDir>]", "check for missing or unused strings and broken transitions", runValidate},
+		{"stats", "[<traDir> <dDir>]", "count lines, strings and words, and translation progress", runStats},
+		{"graph", "[<traDir> <dDir>]", "draw the dialogue states as a Mermaid or DOT graph", runGraph},
+		{"script", "[<traDir> <dDir>]", "render the dialogues as a Markdown or HTML screenplay for proofreading", runScript},
+		{"diff", "<oldTraDir> <newTraDir>", "list strings added, removed or changed between two releases", runDiff},
+		{"merge", "<oldCsvDir> <newCsvDir>", "carry translations over to the sheets of a new release", runMerge},
+		{"tmx", "[<traDir> <dDir>]", "export translations as a TMX translation memory for CAT tools", runTMX},
+		{"xliff", "[<traDir> <dDir>]", "export strings with dialogue context as XLIFF 2.0 for CAT tools", runXLIFF},
+		{"po", "[<traDir> <dDir>]", "export strings as a gettext POT template or PO file", runPO},
+		{"dump", "[<traDir> <dDir>]", "write the parsed .d and .tra files as JSON or NDJSON", runDump},
+	}
+}
 
-	switch len(args) {
-	case 0:
-		// defaults already set
-	case 2:
-		traDir = args[0]
-		dDir = args[1]
-	default:
-		usage()
-		fmt.Fprintf(os.Stderr, "\nError: expected 0 or 2 arguments, got %d\n", len(args))
-		os.Exit(2)
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "help", "-h", "-help", "--help":
+			if len(args) > 1 {
+				return run([]string{args[1], "-h"})
+			}
+			usage()
+			return exitOK
+		}
+		for _, c := range commands {
+			if args[0] == c.name {
+				return c.run(args[1:])
+			}
+		}
+		// "<traDir> <dDir>" without a command needs an existing folder,
+		// so a mistyped command with two arguments isn't taken for one
+		if !strings.HasPrefix(args[0], "-") && (len(args) != 2 || !isDir(args[0])) {
+			fmt.Fprintf(os.Stderr, "Error: unknown command %q\n\n", args[0])
+			usage()
+			return exitUsage
+		}
 	}
+	// without a command, behave like earlier versions: export
+	return runExport(args)
+}
+
+func isDir(path string) bool {
+	st, err := os.Stat(path)
+	return err == nil && st.IsDir()
+}
 
-	fmt.Println("Parsing .tra files from:", traDir)
-	traByFile, err := tra.ParseDir(traDir)
+// writeOutput calls write with the file at path, or with standard output
+// if path is empty. A failed close is an error too: the file may be
+// incomplete.
+func writeOutput(path string, write func(w io.Writer) error) error {
+	if path == "" {
+		return write(os.Stdout)
+	}
+	f, err := os.Create(path)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "TRA parse error: %v\n", err)
-		os.Exit(1)
+		return err
+	}
+	if err := write(f); err != nil {
+		_ = f.Close()
+		return err
 	}
+	return f.Close()
+}
 
-	fmt.Println("Parsing .d files from:", dDir)
-	dByFile, err := d.ParseDir(dDir)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "D parse error: %v\n", err)
-		os.Exit(1)
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage:\n  %s <command> [flags] [arguments]\n\nCommands:\n", os.Args[0])
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-9s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun '%s <command> -h' for the flags of a command. Without a command, export is run.\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Exit codes: %d success, %d failure or problems found, %d invalid command line.\n", exitOK, exitFailure, exitUsage)
+}
+
+// newFlagSet returns the flag set of command name, printing its usage on
+// -h and on errors.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		for _, c := range commands {
+			if c.name == name {
+				fmt.Fprintf(os.Stderr, "Usage:\n  %s %s [flags] %s\n\n%s.\n\nFlags:\n", os.Args[0], name, c.args, capitalize(c.summary))
+			}
+		}
+		fs.PrintDefaults()
 	}
+	addLogFlags(fs)
+	return fs
+}
 
-	fmt.Println("Exporting CSV...")
-	if _, err := csv.Export(dByFile, traByFile); err != nil {
-		fmt.Fprintf(os.Stderr, "Export error: %v\n", err)
-		os.Exit(1)
+// parse parses the command line; done is set with the exit code when the
+// command shouldn't continue (-h or a bad flag).
+func parse(fs *flag.FlagSet, args []string) (code int, done bool) {
+	err := fs.Parse(args)
+	switch {
+	case err == nil:
+		l, err := logging.logger(os.Stderr)
+		if err != nil {
+			return fail(err), true
+		}
+		logger = l
+		return exitOK, false
+	case errors.Is(err, flag.ErrHelp):
+		return exitOK, true
+	default:
+		return exitUsage, true
 	}
+}
+
+// usageError is an invalid command line, reported with exitUsage.
+type usageError struct{ msg string }
 
-	fmt.Println("Done.")
+func (e usageError) Error() string { return e.msg }
+
+func usagef(format string, args ...any) error {
+	return usageError{fmt.Sprintf(format, args...)}
+}
+
+// fail reports err and returns the matching exit code.
+func fail(err error) int {
+	logger.Error(err.Error())
+	var ue usageError
+	if errors.As(err, &ue) {
+		return exitUsage
+	}
+	return exitFailure
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
 }
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/csv"
+)
+
+func runMerge(args []string) int {
+	fs := newFlagSet("merge")
+	outDir := fs.String("out", "", "folder to write the merged CSV files to (default: <newCsvDir>, updated in place)")
+	df := addDialectFlags(fs)
+	if code, done := parse(fs, args); done {
+		return code
+	}
+	if fs.NArg() != 2 {
+		return fail(usagef("expected <oldCsvDir> <newCsvDir>, got %d arguments", fs.NArg()))
+	}
+	dialect, err := df.dialect(fs)
+	if err != nil {
+		return fail(err)
+	}
+	oldDir, newDir := fs.Arg(0), fs.Arg(1)
+	if *outDir == "" {
+		*outDir = newDir
+	}
+	if err := os.MkdirAll(*outDir, 0o755); err != nil {
+		return fail(err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(newDir, "*.[cC][sS][vV]"))
+	if err != nil {
+		return fail(err)
+	}
+
+	var total csv.MergeStats
+	for _, path := range files {
+		name := filepath.Base(path)
+		newRows, err := csv.ReadFile(path, csv.Dialect{})
+		if err != nil {
+			return fail(err)
+		}
+
+		var oldRows [][]string
+		if oldPath := filepath.Join(oldDir, name); fileExists(oldPath) {
+			if oldRows, err = csv.ReadFile(oldPath, csv.Dialect{}); err != nil {
+				return fail(err)
+			}
+		}
+
+		merged, st := csv.Merge(oldRows, newRows)
+		if err := csv.WriteFile(filepath.Join(*outDir, name), merged, dialect); err != nil {
+			return fail(err)
+		}
+		fmt.Printf("%s: %s\n", name, formatMergeStats(st))
+
+		total.Kept += st.Kept
+		total.Moved += st.Moved
+		total.Changed += st.Changed
+		total.Untranslated += st.Untranslated
+	}
+	fmt.Printf("total: %s\n", formatMergeStats(total))
+	return exitOK
+}
+
+func formatMergeStats(st csv.MergeStats) string {
+	return strings.Join([]string{
+		fmt.Sprintf("%d kept", st.Kept),
+		fmt.Sprintf("%d moved", st.Moved),
+		fmt.Sprintf("%d changed", st.Changed),
+		fmt.Sprintf("%d untranslated", st.Untranslated),
+	}, ", ")
+}
+
+func fileExists(path string) bool {
+	st, err := os.Stat(path)
+	return err == nil && !st.IsDir()
+}
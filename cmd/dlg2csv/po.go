@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/charset"
+	"github.com/maciejjwojcik/dlg2csv/internal/csv"
+	"github.com/maciejjwojcik/dlg2csv/internal/po"
+)
+
+func runPO(args []string) int {
+	fs := newFlagSet("po")
+	src := addSourceFlags(fs)
+	csvDir := fs.String("csv", "", "folder with translated CSV files to fill the msgstrs from")
+	target := fs.String("target", "", "folder with translated .tra files to fill the msgstrs from, paired with the source ones by @id")
+	targetEnc := fs.String("target-encoding", charset.Auto, "encoding of the -target .tra files: auto, utf-8, cp1250, cp1251, ...")
+	outPath := fs.String("o", "", "file to write the POT/PO to (default: standard output)")
+	lang := fs.String("target-lang", "", "language code of a PO file, e.g. pl (default: from the -target or the config's target language folder)")
+	if code, done := parse(fs, args); done {
+		return code
+	}
+
+	cfg, err := src.loadConfig(fs, nil)
+	if err != nil {
+		return fail(err)
+	}
+	if *csvDir != "" && *target != "" {
+		return fail(usagef("-csv and -target are mutually exclusive"))
+	}
+
+	m, err := src.read(fs.Args(), cfg)
+	if err != nil {
+		return fail(err)
+	}
+
+	translations, err := m.earlierTranslations(*target, *targetEnc, *csvDir)
+	if err != nil {
+		return fail(err)
+	}
+
+	// without translations nor -target-lang, this is a POT template
+	f := po.File{Language: *lang}
+	if f.Language == "" && translations != nil {
+		_, f.Language = guessLangs(fs.Args(), cfg, *target)
+		if f.Language == "" {
+			return fail(usagef("can't tell the language, set -target-lang"))
+		}
+	}
+	f.Entries = po.Entries(m.tras, csv.Usages(m.dialogs, m.tras, m.traMap), translations)
+
+	if err := writeOutput(*outPath, func(w io.Writer) error { return po.Write(w, f) }); err != nil {
+		return fail(err)
+	}
+	logger.Info("wrote PO", "entries", len(f.Entries), "language", f.Language)
+	return exitOK
+}
+
+// readPO reads a PO file to import.
+func readPO(path string) (po.File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return po.File{}, err
+	}
+	defer func() { _ = f.Close() }()
+
+	logger.Info("reading PO", "file", path)
+	doc, err := po.Read(f)
+	if err != nil {
+		return po.File{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return doc, nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"io"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/script"
+)
+
+func runScript(args []string) int {
+	fs := newFlagSet("script")
+	src := addSourceFlags(fs)
+	dName := fs.String("d", "", "render only this .d file, e.g. bdnpc.d (default: all)")
+	format := fs.String("format", "markdown", "output format: markdown or html")
+	outPath := fs.String("o", "", "file to write the script to (default: standard output)")
+	if code, done := parse(fs, args); done {
+		return code
+	}
+	if *format != "markdown" && *format != "html" {
+		return fail(usagef("unknown format %q, want markdown or html", *format))
+	}
+
+	cfg, err := src.loadConfig(fs, nil)
+	if err != nil {
+		return fail(err)
+	}
+	m, err := src.read(fs.Args(), cfg)
+	if err != nil {
+		return fail(err)
+	}
+
+	keys, occ, err := m.selectDialogs(*dName)
+	if err != nil {
+		return fail(err)
+	}
+	s := script.Build(occ, textResolver(m))
+
+	title := "dialogs"
+	if len(keys) == 1 {
+		title = keys[0] + ".d"
+	}
+	err = writeOutput(*outPath, func(w io.Writer) error {
+		if *format == "html" {
+			return s.HTML(w, title)
+		}
+		return s.Markdown(w, title)
+	})
+	if err != nil {
+		return fail(err)
+	}
+	return exitOK
+}
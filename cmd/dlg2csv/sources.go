@@ -0,0 +1,238 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/charset"
+	"github.com/maciejjwojcik/dlg2csv/internal/config"
+	"github.com/maciejjwojcik/dlg2csv/internal/csv"
+	"github.com/maciejjwojcik/dlg2csv/internal/d"
+	"github.com/maciejjwojcik/dlg2csv/internal/mapping"
+	"github.com/maciejjwojcik/dlg2csv/internal/tmx"
+	"github.com/maciejjwojcik/dlg2csv/internal/tp2"
+	"github.com/maciejjwojcik/dlg2csv/internal/tra"
+)
+
+// sourceFlags select the files of the mod; they are shared by the
+// commands reading a mod.
+type sourceFlags struct {
+	mod      *string
+	lang     *string
+	tp2      *string
+	mapFile  *string
+	encoding *string
+	config   *string
+	strict   *bool
+	maps     mapping.Map
+}
+
+func addSourceFlags(fs *flag.FlagSet) *sourceFlags {
+	s := &sourceFlags{maps: mapping.Map{}}
+	s.mod = fs.String("mod", "", "mod folder (or .tp2 file); find .d and .tra files from its LANGUAGE and COMPILE directives instead of <traDir> <dDir>")
+	s.lang = fs.String("lang", "", "with -mod or -tp2: language directory or name in the .tp2 (default: the first LANGUAGE)")
+	s.tp2 = fs.String("tp2", "", "mod folder with .tp2/.tpa/.tph files, used for USING hints and setup code usages")
+	s.mapFile = fs.String("map-file", "", "file pairing .d files with .tra files (lines like: bdnpc.d = bdnpc_dlg.tra, dialogs.tra)")
+	s.encoding = fs.String("encoding", charset.Auto, "encoding of the .tra files: auto (BOM, UTF-8, then the code page of the language folder), utf-8, cp1250, cp1251, ...")
+	s.config = fs.String("config", "", "project config file (default: "+config.FileName+" in the -mod folder or the current directory)")
+	s.strict = fs.Bool("strict-ids", false, "fail when a .tra file defines an @id twice instead of using the last definition")
+	fs.Var(s.maps, "map", "pair a .d file with .tra files, e.g. -map bdnpc.d=bdnpc_dlg.tra,dialogs.tra (repeatable)")
+	return s
+}
+
+// loadConfig reads the project config, if there is one, and applies it to
+// the flags not given on the command line. It returns nil without config.
+func (s *sourceFlags) loadConfig(fs *flag.FlagSet, override func(*config.Config) map[string]string) (*config.Config, error) {
+	path := findConfig(*s.config, *s.mod)
+	if path == "" {
+		return nil, nil
+	}
+
+	logger.Info("reading config", "file", path)
+	cfg, err := config.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	var values map[string]string
+	if override != nil {
+		values = override(cfg)
+	}
+	if err := applyConfig(fs, cfg, fs.NArg() != 0, values); err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	return cfg, nil
+}
+
+// modFiles are the parsed sources of a mod.
+type modFiles struct {
+	dialogs d.DByFile
+	tras    tra.TraByFile
+	traMap  mapping.Map // .d -> .tra files, see csv.Options.Tras
+}
+
+// read parses the .d and .tra files: those compiled by the .tp2 with -mod,
+// otherwise those in <traDir> <dDir> (args), the config or the current
+// directory.
+func (s *sourceFlags) read(args []string, cfg *config.Config) (*modFiles, error) {
+	enc, err := charset.Normalize(*s.encoding)
+	if err != nil {
+		return nil, usagef("%v", err)
+	}
+
+	traOpts := tra.Options{Encoding: enc, Logger: logger, Strict: *s.strict}
+	m := &modFiles{}
+	if *s.mod != "" {
+		if len(args) != 0 {
+			return nil, usagef("-mod doesn't take positional arguments, got %d", len(args))
+		}
+
+		logger.Info("reading mod layout", "dir", *s.mod)
+		layout, err := tp2.Discover(*s.mod)
+		if err != nil {
+			return nil, fmt.Errorf("read .tp2: %w", err)
+		}
+		language, ok := layout.Language(*s.lang)
+		if !ok {
+			return nil, fmt.Errorf("language %q not declared in %s", *s.lang, layout.TP2)
+		}
+		logger.Info("language", "name", language.Name, "dir", language.Dir)
+
+		files := layout.Files(language)
+		for _, p := range files.Missing {
+			logger.Warn("file not found", "file", p)
+		}
+		m.traMap = mapping.Map(files.Tras)
+
+		if m.tras, err = tra.ParseFilesWithOptions(files.Tra, traOpts); err != nil {
+			return nil, fmt.Errorf("parse .tra: %w", err)
+		}
+		if m.dialogs, err = d.ParseFilesWithOptions(files.D, d.Options{Logger: logger}); err != nil {
+			return nil, fmt.Errorf("parse .d: %w", err)
+		}
+	} else {
+		traDir := "."
+		dDirs := []string{"."}
+
+		switch len(args) {
+		case 0:
+			if cfg != nil && cfg.Source.Tra != "" {
+				traDir = cfg.Source.Tra
+			}
+			if cfg != nil && len(cfg.D) > 0 {
+				dDirs = cfg.D
+			}
+		case 2:
+			traDir = args[0]
+			dDirs = []string{args[1]}
+		default:
+			return nil, usagef("expected 0 or 2 arguments, got %d", len(args))
+		}
+
+		logger.Info("parsing .tra files", "dir", traDir)
+		if m.tras, err = tra.ParseDirWithOptions(traDir, traOpts); err != nil {
+			return nil, fmt.Errorf("parse .tra: %w", err)
+		}
+
+		m.dialogs = d.DByFile{}
+		for _, dDir := range dDirs {
+			logger.Info("parsing .d files", "dir", dDir)
+			parsed, err := d.ParseDirWithOptions(dDir, d.Options{Logger: logger})
+			if err != nil {
+				return nil, fmt.Errorf("parse .d: %w", err)
+			}
+			for k, v := range parsed {
+				if _, dup := m.dialogs[k]; dup {
+					return nil, fmt.Errorf("parse .d: %s.d found in several folders", k)
+				}
+				m.dialogs[k] = v
+			}
+		}
+
+		// USING hints from the .tp2, if the setup folder has one
+		if *s.tp2 != "" {
+			if layout, err := tp2.Discover(*s.tp2); err == nil {
+				if language, ok := layout.Language(*s.lang); ok {
+					m.traMap = mapping.Map(layout.Files(language).Tras)
+				}
+			}
+		}
+	}
+
+	// explicit mappings override what the .tp2 says
+	if *s.mapFile != "" {
+		fileMap, err := mapping.ParseFile(*s.mapFile)
+		if err != nil {
+			return nil, fmt.Errorf("mapping file: %w", err)
+		}
+		m.traMap = m.traMap.Merge(fileMap)
+	}
+	if cfg != nil {
+		cfgMap, _ := cfg.Mapping() // checked by config.Load
+		m.traMap = m.traMap.Merge(cfgMap)
+	}
+	m.traMap = m.traMap.Merge(s.maps)
+
+	return m, nil
+}
+
+// earlierTranslations reads the translations to fill in an export from:
+// the translated .tra files in target, read in targetEnc, or the CSV files
+// in csvDir. Both empty means there are none.
+func (m *modFiles) earlierTranslations(target, targetEnc, csvDir string) (csv.Translations, error) {
+	switch {
+	case target != "":
+		enc, err := charset.Normalize(targetEnc)
+		if err != nil {
+			return nil, usagef("%v", err)
+		}
+		logger.Info("parsing translated .tra files", "dir", target, "encoding", enc)
+		translated, err := tra.ParseDirWithOptions(target, tra.Options{Encoding: enc, Logger: logger})
+		if err != nil {
+			return nil, fmt.Errorf("parse .tra: %w", err)
+		}
+		return csv.TraTranslations(m.tras, translated), nil
+	case csvDir != "":
+		logger.Info("reading translations", "dir", csvDir)
+		translations, err := csv.Import(m.dialogs, m.tras, csvDir, csv.Options{Tras: m.traMap, Logger: logger})
+		if err != nil {
+			return nil, fmt.Errorf("import: %w", err)
+		}
+		return translations, nil
+	}
+	return nil, nil
+}
+
+// guessLangs guesses the language codes of the source, from the source
+// language folder or else the config (default "en"), and of the
+// translation, from target or else the config's target language folder.
+// The target is empty if it can't be told.
+func guessLangs(args []string, cfg *config.Config, target string) (string, string) {
+	source := ""
+	if len(args) > 0 {
+		source = tmx.LangCode(args[0])
+	} else if cfg != nil {
+		source = tmx.LangCode(cfg.Source.Tra + "/" + cfg.Source.Lang)
+	}
+	if source == "" {
+		source = "en"
+	}
+
+	translated := tmx.LangCode(target)
+	if translated == "" && cfg != nil {
+		translated = tmx.LangCode(cfg.Target.Tra + "/" + cfg.Target.Lang)
+	}
+	return source, translated
+}
+
+// setupIndex scans the setup code in the -tp2 folder, or returns nil.
+func (s *sourceFlags) setupIndex() (*tp2.Index, error) {
+	if *s.tp2 == "" {
+		return nil, nil
+	}
+	logger.Info("scanning setup code", "dir", *s.tp2)
+	usages, err := tp2.ParseDir(*s.tp2)
+	if err != nil {
+		return nil, fmt.Errorf("scan setup code: %w", err)
+	}
+	return tp2.NewIndex(usages), nil
+}
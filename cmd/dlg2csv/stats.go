@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/config"
+	"github.com/maciejjwojcik/dlg2csv/internal/csv"
+	"github.com/maciejjwojcik/dlg2csv/internal/stats"
+)
+
+func runStats(args []string) int {
+	fs := newFlagSet("stats")
+	src := addSourceFlags(fs)
+	csvDir := fs.String("csv", "", "folder with translated CSV files; adds translation progress")
+	if code, done := parse(fs, args); done {
+		return code
+	}
+
+	cfg, err := src.loadConfig(fs, func(cfg *config.Config) map[string]string {
+		return map[string]string{"csv": cfg.Output.Dir}
+	})
+	if err != nil {
+		return fail(err)
+	}
+	m, err := src.read(fs.Args(), cfg)
+	if err != nil {
+		return fail(err)
+	}
+
+	opts := stats.Options{Tras: m.traMap}
+	if *csvDir != "" {
+		translations, err := csv.Import(m.dialogs, m.tras, *csvDir, csv.Options{Tras: m.traMap, Logger: logger})
+		if err != nil {
+			return fail(fmt.Errorf("read translations: %w", err))
+		}
+		opts.Translated = func(traKey, id string) bool {
+			_, ok := translations[traKey][id]
+			return ok
+		}
+	}
+	r := stats.Compute(m.dialogs, m.tras, opts)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, ".d file\tstates\tNPC\tPC\tjournal\tvanilla\t")
+	for _, s := range r.Dialogs {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%d\t\n", s.File, s.States, s.NPC, s.PC, s.Journal, s.Vanilla)
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprint(w, ".tra file\tstrings\twords\tunused\t")
+	if opts.Translated != nil {
+		fmt.Fprint(w, "translated\t\t")
+	}
+	fmt.Fprintln(w)
+	for _, s := range append(r.Tras, r.Total()) {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t", s.File, s.Strings, s.Words, s.Unused)
+		if opts.Translated != nil {
+			fmt.Fprintf(w, "%d\t%.1f%%\t", s.Translated, s.Progress())
+		}
+		fmt.Fprintln(w)
+	}
+	if err := w.Flush(); err != nil {
+		return fail(err)
+	}
+	return exitOK
+}
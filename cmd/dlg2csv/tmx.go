@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/charset"
+	"github.com/maciejjwojcik/dlg2csv/internal/tmx"
+)
+
+func runTMX(args []string) int {
+	fs := newFlagSet("tmx")
+	src := addSourceFlags(fs)
+	csvDir := fs.String("csv", "", "folder with the translated CSV files to export")
+	target := fs.String("target", "", "folder with the translated .tra files to export, paired with the source ones by @id")
+	targetEnc := fs.String("target-encoding", charset.Auto, "encoding of the -target .tra files: auto, utf-8, cp1250, cp1251, ...")
+	outPath := fs.String("o", "", "file to write the TMX to (default: standard output)")
+	sourceLang := fs.String("source-lang", "", "language code of the source, e.g. en (default: from the source language folder, or en)")
+	targetLang := fs.String("target-lang", "", "language code of the translation, e.g. pl (default: from the -target or the config's target language folder)")
+	if code, done := parse(fs, args); done {
+		return code
+	}
+
+	cfg, err := src.loadConfig(fs, nil)
+	if err != nil {
+		return fail(err)
+	}
+	if *csvDir == "" && *target == "" && cfg != nil {
+		*target = cfg.Target.Tra
+	}
+	if (*csvDir == "") == (*target == "") {
+		return fail(usagef("give either -csv or -target"))
+	}
+
+	m, err := src.read(fs.Args(), cfg)
+	if err != nil {
+		return fail(err)
+	}
+
+	doc := tmx.Memory{SourceLang: *sourceLang, TargetLang: *targetLang}
+	srcLang, trgLang := guessLangs(fs.Args(), cfg, *target)
+	if doc.SourceLang == "" {
+		doc.SourceLang = srcLang
+	}
+	if doc.TargetLang == "" {
+		doc.TargetLang = trgLang
+	}
+	if doc.TargetLang == "" {
+		return fail(usagef("can't tell the target language, set -target-lang"))
+	}
+
+	translations, err := m.earlierTranslations(*target, *targetEnc, *csvDir)
+	if err != nil {
+		return fail(err)
+	}
+	doc.Units = tmx.FromTranslations(m.tras, translations)
+
+	if err := writeOutput(*outPath, func(w io.Writer) error { return tmx.Write(w, doc) }); err != nil {
+		return fail(err)
+	}
+	logger.Info("wrote TMX", "units", len(doc.Units))
+	return exitOK
+}
+
+// readTMX reads a TMX file for the translation memory or import; an empty
+// targetLang takes the first language besides the source one.
+func readTMX(path, targetLang string) (tmx.Memory, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return tmx.Memory{}, err
+	}
+	defer func() { _ = f.Close() }()
+
+	logger.Info("reading TMX", "file", path)
+	m, err := tmx.Read(f, targetLang)
+	if err != nil {
+		return tmx.Memory{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return m, nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/charset"
+	"github.com/maciejjwojcik/dlg2csv/internal/config"
+	"github.com/maciejjwojcik/dlg2csv/internal/csv"
+	"github.com/maciejjwojcik/dlg2csv/internal/dlg"
+	"github.com/maciejjwojcik/dlg2csv/internal/tlk"
+	"github.com/maciejjwojcik/dlg2csv/internal/validate"
+)
+
+// ruleFlags collects repeated -rule name=severity flags.
+type ruleFlags map[string]validate.Severity
+
+func (r ruleFlags) String() string {
+	var parts []string
+	for name, sev := range r {
+		parts = append(parts, name+"="+string(sev))
+	}
+	return strings.Join(parts, ",")
+}
+
+func (r ruleFlags) Set(v string) error {
+	name, sevName, ok := strings.Cut(v, "=")
+	if !ok {
+		return fmt.Errorf("want rule=severity, got %q", v)
+	}
+	if _, known := validate.Defaults[name]; !known {
+		return fmt.Errorf("unknown rule %q (rules: %s)", name, strings.Join(validate.Rules(), ", "))
+	}
+	sev, err := validate.ParseSeverity(sevName)
+	if err != nil {
+		return err
+	}
+	r[name] = sev
+	return nil
+}
+
+// femaleOptional resolves the -female policy; without one, translations
+// into the language folder found in target may add female variants if the
+// language has grammatical gender.
+func femaleOptional(policy, target string) (bool, error) {
+	switch strings.ToLower(policy) {
+	case config.FemaleSource:
+		return false, nil
+	case config.FemaleOptional:
+		return true, nil
+	case "":
+		return validate.GenderedLanguage(target), nil
+	}
+	return false, usagef("unknown -female %q, want %s or %s", policy, config.FemaleSource, config.FemaleOptional)
+}
+
+func runValidate(args []string) int {
+	fs := newFlagSet("validate")
+	src := addSourceFlags(fs)
+	tlkPath := fs.String("tlk", "", "path to the game's dialog.tlk (or its language folder); checks #strref references")
+	tlkEnc := fs.String("tlk-encoding", charset.Auto, "encoding of the -tlk files: auto (UTF-8, then the code page of the language folder), utf-8, cp1250, cp1251, ...")
+	override := fs.String("override", "", "game override folder with .dlg files; EXTERN targets found there are not reported")
+	csvDir := fs.String("csv", "", "folder with translated CSV files; checks the <TOKEN>s and female variants of the translations")
+	female := fs.String("female", "", "female variants of the translations: source (only where the source has one) or optional (default: by the target language in the config)")
+	strict := fs.Bool("strict", false, "fail on warnings too")
+	rules := ruleFlags{}
+	fs.Var(rules, "rule", "set the severity of a rule, e.g. -rule unused-tra=off (rules: "+strings.Join(validate.Rules(), ", ")+"; repeatable)")
+	if code, done := parse(fs, args); done {
+		return code
+	}
+	tlkEncoding, err := charset.Normalize(*tlkEnc)
+	if err != nil {
+		return fail(usagef("%v", err))
+	}
+
+	cfg, err := src.loadConfig(fs, func(cfg *config.Config) map[string]string {
+		return map[string]string{"csv": cfg.Output.Dir, "female": cfg.Target.Female}
+	})
+	if err != nil {
+		return fail(err)
+	}
+	m, err := src.read(fs.Args(), cfg)
+	if err != nil {
+		return fail(err)
+	}
+
+	opts := validate.Options{Rules: map[string]validate.Severity{}, Tras: m.traMap}
+	target := ""
+	if cfg != nil {
+		target = filepath.Join(cfg.Target.Tra, cfg.Target.Lang)
+		opts.External = cfg.External
+		for name, sev := range cfg.Validation {
+			if _, known := validate.Defaults[name]; !known {
+				return fail(fmt.Errorf("config: unknown validation rule %q (rules: %s)", name, strings.Join(validate.Rules(), ", ")))
+			}
+			s, err := validate.ParseSeverity(sev)
+			if err != nil {
+				return fail(fmt.Errorf("config: %w", err))
+			}
+			opts.Rules[name] = s
+		}
+	}
+	for name, sev := range rules {
+		opts.Rules[name] = sev
+	}
+	if opts.FemaleOptional, err = femaleOptional(*female, target); err != nil {
+		return fail(err)
+	}
+
+	if *tlkPath != "" {
+		talk, err := tlk.OpenTalkWithOptions(*tlkPath, tlk.Options{Encoding: tlkEncoding})
+		if err != nil {
+			return fail(fmt.Errorf("read TLK: %w", err))
+		}
+		opts.Strrefs = talk
+	}
+	if *override != "" {
+		vanilla, err := dlg.OpenOverride(*override)
+		if err != nil {
+			return fail(fmt.Errorf("read override: %w", err))
+		}
+		opts.Dialogs = func(resref string) bool {
+			f, err := vanilla.Dialog(resref)
+			return err == nil && f != nil
+		}
+	}
+	setup, err := src.setupIndex()
+	if err != nil {
+		return fail(err)
+	}
+	if setup != nil {
+		opts.Used = func(traKey string, id int) bool { return len(setup.Usages(traKey, id)) > 0 }
+	}
+
+	if *csvDir != "" {
+		translations, err := csv.Import(m.dialogs, m.tras, *csvDir, csv.Options{Tras: m.traMap, Logger: logger})
+		if err != nil {
+			return fail(fmt.Errorf("read translations: %w", err))
+		}
+		opts.Translations = translations
+	}
+
+	var errs, warnings int
+	for _, f := range validate.Run(m.dialogs, m.tras, opts) {
+		fmt.Println(f)
+		if f.Severity == validate.Error {
+			errs++
+		} else {
+			warnings++
+		}
+	}
+	fmt.Printf("%d errors, %d warnings\n", errs, warnings)
+
+	if errs > 0 || (*strict && warnings > 0) {
+		return exitFailure
+	}
+	return exitOK
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/charset"
+	"github.com/maciejjwojcik/dlg2csv/internal/csv"
+	"github.com/maciejjwojcik/dlg2csv/internal/xliff"
+)
+
+func runXLIFF(args []string) int {
+	fs := newFlagSet("xliff")
+	src := addSourceFlags(fs)
+	csvDir := fs.String("csv", "", "folder with translated CSV files to fill the targets from")
+	target := fs.String("target", "", "folder with translated .tra files to fill the targets from, paired with the source ones by @id")
+	targetEnc := fs.String("target-encoding", charset.Auto, "encoding of the -target .tra files: auto, utf-8, cp1250, cp1251, ...")
+	outPath := fs.String("o", "", "file to write the XLIFF to (default: standard output)")
+	sourceLang := fs.String("source-lang", "", "language code of the source, e.g. en (default: from the source language folder, or en)")
+	targetLang := fs.String("target-lang", "", "language code of the translation, e.g. pl (default: from the -target or the config's target language folder)")
+	if code, done := parse(fs, args); done {
+		return code
+	}
+
+	cfg, err := src.loadConfig(fs, nil)
+	if err != nil {
+		return fail(err)
+	}
+	if *csvDir != "" && *target != "" {
+		return fail(usagef("-csv and -target are mutually exclusive"))
+	}
+
+	m, err := src.read(fs.Args(), cfg)
+	if err != nil {
+		return fail(err)
+	}
+
+	doc := xliff.Document{SourceLang: *sourceLang, TargetLang: *targetLang}
+	srcLang, trgLang := guessLangs(fs.Args(), cfg, *target)
+	if doc.SourceLang == "" {
+		doc.SourceLang = srcLang
+	}
+	if doc.TargetLang == "" {
+		doc.TargetLang = trgLang
+	}
+
+	translations, err := m.earlierTranslations(*target, *targetEnc, *csvDir)
+	if err != nil {
+		return fail(err)
+	}
+	if translations != nil && doc.TargetLang == "" {
+		return fail(usagef("can't tell the target language, set -target-lang"))
+	}
+	doc.Units = xliff.Units(m.tras, csv.Usages(m.dialogs, m.tras, m.traMap), translations)
+
+	if err := writeOutput(*outPath, func(w io.Writer) error { return xliff.Write(w, doc) }); err != nil {
+		return fail(err)
+	}
+	logger.Info("wrote XLIFF", "units", len(doc.Units))
+	return exitOK
+}
+
+// readXLIFF reads an XLIFF file to import.
+func readXLIFF(path string) (xliff.Document, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return xliff.Document{}, err
+	}
+	defer func() { _ = f.Close() }()
+
+	logger.Info("reading XLIFF", "file", path)
+	doc, err := xliff.Read(f)
+	if err != nil {
+		return xliff.Document{}, fmt.Errorf("%s: %w", path, err)
+	}
+	return doc, nil
+}
@@ -0,0 +1,226 @@
+// Package charset handles the code pages .tra files are written in.
+//
+// Infinity Engine games read text in the code page of the game language
+// (cp1250 for Polish and Czech, cp1251 for Russian, cp936 for Simplified
+// Chinese, ...), while Enhanced Edition mods usually ship UTF-8. Text is
+// decoded to UTF-8 for parsing and encoded back when writing .tra files.
+//
+// cp949 is the full Korean Windows code page (Unified Hangul Code): the
+// EUC-KR table plus the Hangul syllables EUC-KR lacks. "euc-kr" is an
+// alias for it, so EUC-KR files read the same, but text written as
+// "euc-kr" may use the cp949 extension bytes.
+package charset
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/korean"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+const (
+	// Auto detects the encoding: BOM, then valid UTF-8, then the code page
+	// of the language named in the file's path, then cp1252.
+	Auto = "auto"
+
+	UTF8    = "utf-8"
+	UTF8BOM = "utf-8-bom" // UTF-8 written with a byte order mark
+)
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+var encodings = map[string]encoding.Encoding{
+	UTF8:       unicode.UTF8,
+	UTF8BOM:    unicode.UTF8,
+	"utf-16le": unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM),
+	"utf-16be": unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM),
+	"cp1250":   charmap.Windows1250,
+	"cp1251":   charmap.Windows1251,
+	"cp1252":   charmap.Windows1252,
+	"cp1253":   charmap.Windows1253,
+	"cp1254":   charmap.Windows1254,
+	"cp1257":   charmap.Windows1257,
+	"cp932":    japanese.ShiftJIS,
+	"cp936":    simplifiedchinese.GBK,
+	"cp949":    korean.EUCKR, // x/text's EUCKR decodes and encodes all of cp949
+	"cp950":    traditionalchinese.Big5,
+}
+
+var aliases = map[string]string{
+	"utf8":      UTF8,
+	"utf8-bom":  UTF8BOM,
+	"utf8bom":   UTF8BOM,
+	"gbk":       "cp936",
+	"big5":      "cp950",
+	"shift_jis": "cp932",
+	"sjis":      "cp932",
+	"euc-kr":    "cp949", // a subset of cp949, see the package doc
+}
+
+// code pages used by the games for each language folder name
+var languageCodePages = map[string]string{
+	"english":    "cp1252",
+	"american":   "cp1252",
+	"french":     "cp1252",
+	"francais":   "cp1252",
+	"german":     "cp1252",
+	"deutsch":    "cp1252",
+	"italian":    "cp1252",
+	"italiano":   "cp1252",
+	"spanish":    "cp1252",
+	"espanol":    "cp1252",
+	"castilian":  "cp1252",
+	"portuguese": "cp1252",
+	"polish":     "cp1250",
+	"polski":     "cp1250",
+	"czech":      "cp1250",
+	"cesky":      "cp1250",
+	"hungarian":  "cp1250",
+	"magyar":     "cp1250",
+	"russian":    "cp1251",
+	"russki":     "cp1251",
+	"ukrainian":  "cp1251",
+	"greek":      "cp1253",
+	"turkish":    "cp1254",
+	"chinese":    "cp936",
+	"schinese":   "cp936",
+	"tchinese":   "cp950",
+	"japanese":   "cp932",
+	"korean":     "cp949",
+}
+
+// Normalize returns the canonical name of an encoding, e.g. "Windows-1250"
+// -> "cp1250", or an error listing the supported names.
+func Normalize(name string) (string, error) {
+	n := strings.ToLower(strings.TrimSpace(name))
+	if n == "" || n == Auto {
+		return Auto, nil
+	}
+	if strings.HasPrefix(n, "windows-") {
+		n = "cp" + strings.TrimPrefix(n, "windows-")
+	}
+	if a, ok := aliases[n]; ok {
+		n = a
+	}
+	if _, ok := encodings[n]; !ok {
+		return "", fmt.Errorf("unknown encoding %q (supported: %s, %s)", name, Auto, strings.Join(Names(), ", "))
+	}
+	return n, nil
+}
+
+// Names lists the supported encodings.
+func Names() []string {
+	names := make([]string, 0, len(encodings))
+	for n := range encodings {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ForLanguage guesses the code page from a language folder name found
+// anywhere in path, e.g. "lang/polish/setup.tra" -> "cp1250". It returns
+// "" if no element of the path is a known language.
+func ForLanguage(path string) string {
+	for _, part := range strings.Split(filepath.ToSlash(path), "/") {
+		if cp, ok := languageCodePages[strings.ToLower(part)]; ok {
+			return cp
+		}
+	}
+	return ""
+}
+
+// ForTarget picks the encoding to write a translation of a file read in
+// sourceEnc into the language folder found in outDir. UTF-8 sources stay
+// UTF-8, unless ascii tells they are plain ASCII, which was only detected
+// as UTF-8 and reads the same in any code page; for those and for
+// code-page sources, the code page of the language folder (e.g.
+// "tra/polish" -> cp1250) wins over the source's.
+func ForTarget(sourceEnc string, ascii bool, outDir string) string {
+	switch {
+	case sourceEnc == UTF8BOM, sourceEnc == UTF8 && !ascii:
+		return sourceEnc
+	case ForLanguage(outDir) != "":
+		return ForLanguage(outDir)
+	case sourceEnc == "":
+		return UTF8
+	}
+	return sourceEnc
+}
+
+// Decode converts data in encoding name to UTF-8 and strips a byte order
+// mark. With Auto, path is used as a hint for ForLanguage. It returns the
+// encoding actually used, which is the one to write the file back in.
+func Decode(data []byte, name, path string) ([]byte, string, error) {
+	name, err := Normalize(name)
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch {
+	case bytes.HasPrefix(data, utf8BOM):
+		if name == Auto || name == UTF8 || name == UTF8BOM {
+			return data[len(utf8BOM):], UTF8BOM, nil
+		}
+		// a forced code page wins, but the mark isn't text
+		data = data[len(utf8BOM):]
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}) && (name == Auto || name == "utf-16le"):
+		name, data = "utf-16le", data[2:]
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}) && (name == Auto || name == "utf-16be"):
+		name, data = "utf-16be", data[2:]
+	}
+
+	if name == Auto {
+		switch {
+		case utf8.Valid(data):
+			name = UTF8
+		case ForLanguage(path) != "":
+			name = ForLanguage(path)
+		default:
+			name = "cp1252"
+		}
+	}
+
+	if name == UTF8 || name == UTF8BOM {
+		return bytes.TrimPrefix(data, utf8BOM), name, nil
+	}
+
+	out, err := encodings[name].NewDecoder().Bytes(data)
+	if err != nil {
+		return nil, "", fmt.Errorf("decode %s: %w", name, err)
+	}
+	return out, name, nil
+}
+
+// Encode converts UTF-8 data to encoding name. UTF8BOM prepends the byte
+// order mark; Auto is treated as UTF-8. Characters the code page can't
+// represent are an error rather than being replaced silently.
+func Encode(data []byte, name string) ([]byte, error) {
+	name, err := Normalize(name)
+	if err != nil {
+		return nil, err
+	}
+
+	switch name {
+	case Auto, UTF8:
+		return data, nil
+	case UTF8BOM:
+		return append(append([]byte(nil), utf8BOM...), data...), nil
+	}
+
+	out, err := encodings[name].NewEncoder().Bytes(data)
+	if err != nil {
+		return nil, fmt.Errorf("encode %s: %w", name, err)
+	}
+	return out, nil
+}
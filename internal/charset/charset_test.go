@@ -0,0 +1,124 @@
+package charset
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := map[string]string{
+		"":             Auto,
+		"AUTO":         Auto,
+		"Windows-1250": "cp1250",
+		"UTF8":         UTF8,
+		"gbk":          "cp936",
+		"cp1251":       "cp1251",
+	}
+	for in, want := range tests {
+		got, err := Normalize(in)
+		if err != nil || got != want {
+			t.Fatalf("Normalize(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := Normalize("ebcdic"); err == nil {
+		t.Fatalf("expected error for unknown encoding")
+	}
+}
+
+func TestForLanguage(t *testing.T) {
+	tests := map[string]string{
+		"mymod/tra/Polish/setup.tra": "cp1250",
+		"lang/russian":               "cp1251",
+		"tra/schinese/dialog.tra":    "cp936",
+		"tra/klingon/dialog.tra":     "",
+	}
+	for path, want := range tests {
+		if got := ForLanguage(path); got != want {
+			t.Fatalf("ForLanguage(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestForTarget(t *testing.T) {
+	tests := []struct {
+		sourceEnc string
+		ascii     bool
+		outDir    string
+		want      string
+	}{
+		{UTF8, true, "tra/polish", "cp1250"}, // plain ASCII English
+		{UTF8, false, "tra/polish", UTF8},
+		{UTF8BOM, true, "tra/polish", UTF8BOM},
+		{"cp1252", true, "tra/russian", "cp1251"},
+		{"cp1252", false, "out", "cp1252"},
+		{UTF8, true, "out", UTF8},
+		{"", false, "out", UTF8},
+	}
+	for _, tt := range tests {
+		if got := ForTarget(tt.sourceEnc, tt.ascii, tt.outDir); got != tt.want {
+			t.Errorf("ForTarget(%q, %v, %q) = %q, want %q", tt.sourceEnc, tt.ascii, tt.outDir, got, tt.want)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	zolw := []byte{0xBF, 0xF3, 0xB3, 0x77}               // "żółw" in cp1250
+	privet := []byte{0xEF, 0xF0, 0xE8, 0xE2, 0xE5, 0xF2} // "привет" in cp1251
+
+	tests := []struct {
+		name     string
+		data     []byte
+		enc      string
+		path     string
+		want     string
+		wantUsed string
+	}{
+		{"utf-8 detected", []byte("żółw"), Auto, "polish/a.tra", "żółw", UTF8},
+		{"utf-8 BOM stripped", append([]byte{0xEF, 0xBB, 0xBF}, "żółw"...), Auto, "", "żółw", UTF8BOM},
+		{"language hint", zolw, Auto, "tra/polish/a.tra", "żółw", "cp1250"},
+		{"explicit", privet, "windows-1251", "a.tra", "привет", "cp1251"},
+		{"BOM with explicit code page", append([]byte{0xEF, 0xBB, 0xBF}, zolw...), "cp1250", "a.tra", "żółw", "cp1250"},
+		{"fallback cp1252", []byte{0x63, 0x61, 0x66, 0xE9}, Auto, "a.tra", "café", "cp1252"},
+		{"utf-16le BOM", []byte{0xFF, 0xFE, 'h', 0, 'i', 0}, Auto, "", "hi", "utf-16le"},
+	}
+	for _, tt := range tests {
+		got, used, err := Decode(tt.data, tt.enc, tt.path)
+		if err != nil {
+			t.Fatalf("%s: Decode error: %v", tt.name, err)
+		}
+		if string(got) != tt.want || used != tt.wantUsed {
+			t.Fatalf("%s: got %q (%s), want %q (%s)", tt.name, got, used, tt.want, tt.wantUsed)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	got, err := Encode([]byte("żółw"), "cp1250")
+	if err != nil {
+		t.Fatalf("Encode error: %v", err)
+	}
+	if !bytes.Equal(got, []byte{0xBF, 0xF3, 0xB3, 0x77}) {
+		t.Fatalf("Encode cp1250 = % x", got)
+	}
+
+	got, err = Encode([]byte("a"), UTF8BOM)
+	if err != nil || !bytes.Equal(got, []byte{0xEF, 0xBB, 0xBF, 'a'}) {
+		t.Fatalf("Encode utf-8-bom = % x, %v", got, err)
+	}
+
+	if _, err := Encode([]byte("привет"), "cp1250"); err == nil {
+		t.Fatalf("expected error for characters missing from the code page")
+	}
+}
+
+func TestEncode_CP949Extension(t *testing.T) {
+	// 갂 is one of the Hangul syllables cp949 adds to EUC-KR
+	got, err := Encode([]byte("갂"), "cp949")
+	if err != nil || !bytes.Equal(got, []byte{0x81, 0x41}) {
+		t.Fatalf("Encode cp949 = % x, %v", got, err)
+	}
+	back, _, err := Decode(got, "cp949", "")
+	if err != nil || string(back) != "갂" {
+		t.Fatalf("Decode cp949 = %q, %v", back, err)
+	}
+}
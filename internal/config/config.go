@@ -0,0 +1,238 @@
+// Package config reads dlg2csv.yaml, the project file kept at the root of
+// a mod with the settings that would otherwise be repeated on every run.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/charset"
+	"github.com/maciejjwojcik/dlg2csv/internal/mapping"
+)
+
+// FileName is the name the config is looked up by.
+const FileName = "dlg2csv.yaml"
+
+// Column names accepted in Columns.
+const (
+	ColumnSource  = "source"
+	ColumnContext = "context"
+)
+
+// Severities of validation rules.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityOff     = "off"
+)
+
+// Female variant policies of a target language.
+const (
+	FemaleSource   = "source"   // female variants exactly where the source has them
+	FemaleOptional = "optional" // also where the source has none
+)
+
+// Config is the content of dlg2csv.yaml:
+//
+//	mod: .
+//	source:
+//	  lang: english
+//	target:
+//	  lang: polish
+//	  encoding: cp1250
+//	mappings:
+//	  bdnpcj.d: dialogs.tra, bdnpcj.tra
+//	output:
+//	  dir: csv
+//	  dialect: excel-eu
+//	columns: [source, context]
+//
+// Relative paths are relative to the folder of the file.
+type Config struct {
+	// Mod is the mod folder or .tp2 file; .d and .tra files are found from
+	// its LANGUAGE and COMPILE directives. Alternative to Source.Tra and D.
+	Mod string `yaml:"mod"`
+
+	Source Language `yaml:"source"` // language translated from
+	Target Language `yaml:"target"` // language translated to
+
+	D []string `yaml:"d"` // folders with .d files
+
+	TP2      string `yaml:"tp2"`      // folder with .tp2/.tpa/.tph files
+	TLK      string `yaml:"tlk"`      // dialog.tlk or its folder
+	Override string `yaml:"override"` // folder with vanilla .dlg files
+
+	// Mappings pair .d files with .tra files, as in a -map-file:
+	// "bdnpc.d: bdnpc_dlg.tra, dialogs.tra". MapFile is read first.
+	Mappings map[string]string `yaml:"mappings"`
+	MapFile  string            `yaml:"map_file"`
+
+	Output Output `yaml:"output"`
+
+	// Columns lists the optional columns to add: source, context.
+	Columns []string `yaml:"columns"`
+
+	// External lists dialogs of the base game or other mods that the .d
+	// files may refer to (EXTERN, INTERJECT, ...) without defining them.
+	External []string `yaml:"external"`
+
+	// Validation sets the severity of validation rules by name: error,
+	// warning or off.
+	Validation map[string]string `yaml:"validation"`
+
+	dir string
+}
+
+// Language is a language of the mod.
+type Language struct {
+	Lang     string `yaml:"lang"`     // language directory or name in the .tp2
+	Tra      string `yaml:"tra"`      // folder with its .tra files
+	Encoding string `yaml:"encoding"` // encoding of the .tra files, default auto
+	Female   string `yaml:"female"`   // target only: source or optional, default by language
+}
+
+// Output controls the written files.
+type Output struct {
+	Dir       string `yaml:"dir"`
+	Format    string `yaml:"format"`    // csv
+	Dialect   string `yaml:"dialect"`   // CSV preset, e.g. excel-eu
+	Delimiter string `yaml:"delimiter"` // overrides the preset
+	BOM       *bool  `yaml:"bom"`       // overrides the preset
+	CRLF      *bool  `yaml:"crlf"`      // overrides the preset
+	Quote     string `yaml:"quote"`     // minimal or all
+}
+
+// Find returns the path of dlg2csv.yaml in dir, or "" if there is none.
+func Find(dir string) string {
+	path := filepath.Join(dir, FileName)
+	if st, err := os.Stat(path); err == nil && !st.IsDir() {
+		return path
+	}
+	return ""
+}
+
+// Load reads a config file and resolves its paths.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := Parse(bytes.NewReader(data), filepath.Base(path))
+	if err != nil {
+		return nil, err
+	}
+	c.resolve(filepath.Dir(path))
+	return c, nil
+}
+
+// Parse reads a config without resolving paths. Unknown keys are an error,
+// so typos don't go unnoticed.
+func Parse(r io.Reader, fileName string) (*Config, error) {
+	var c Config
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	if err := dec.Decode(&c); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+	if err := c.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", fileName, err)
+	}
+	return &c, nil
+}
+
+func (c *Config) validate() error {
+	for _, enc := range []string{c.Source.Encoding, c.Target.Encoding} {
+		if _, err := charset.Normalize(enc); err != nil {
+			return err
+		}
+	}
+	if f := strings.ToLower(c.Output.Format); f != "" && f != "csv" {
+		return fmt.Errorf("unsupported output format %q", c.Output.Format)
+	}
+	for _, col := range c.Columns {
+		switch strings.ToLower(col) {
+		case ColumnSource, ColumnContext:
+		default:
+			return fmt.Errorf("unknown column %q (supported: %s, %s)", col, ColumnSource, ColumnContext)
+		}
+	}
+	switch strings.ToLower(c.Target.Female) {
+	case "", FemaleSource, FemaleOptional:
+	default:
+		return fmt.Errorf("unknown female policy %q (supported: %s, %s)", c.Target.Female, FemaleSource, FemaleOptional)
+	}
+	for rule, sev := range c.Validation {
+		switch strings.ToLower(sev) {
+		case SeverityError, SeverityWarning, SeverityOff:
+		default:
+			return fmt.Errorf("validation rule %s: unknown severity %q (supported: %s, %s, %s)",
+				rule, sev, SeverityError, SeverityWarning, SeverityOff)
+		}
+	}
+	if _, err := c.Mapping(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// resolve makes paths relative to dir.
+func (c *Config) resolve(dir string) {
+	c.dir = dir
+	abs := func(p *string) {
+		if *p != "" && !filepath.IsAbs(*p) {
+			*p = filepath.Join(dir, filepath.FromSlash(*p))
+		}
+	}
+	abs(&c.Mod)
+	abs(&c.Source.Tra)
+	abs(&c.Target.Tra)
+	abs(&c.TP2)
+	abs(&c.TLK)
+	abs(&c.Override)
+	abs(&c.MapFile)
+	abs(&c.Output.Dir)
+	for i := range c.D {
+		abs(&c.D[i])
+	}
+}
+
+// Dir is the folder the config was loaded from; empty for Parse.
+func (c *Config) Dir() string {
+	return c.dir
+}
+
+// Mapping returns Mappings as a mapping.Map. MapFile is not included.
+func (c *Config) Mapping() (mapping.Map, error) {
+	keys := make([]string, 0, len(c.Mappings))
+	for k := range c.Mappings {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	m := mapping.Map{}
+	for _, k := range keys {
+		if err := m.Set(k + "=" + c.Mappings[k]); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// HasColumn reports whether the optional column name is enabled.
+func (c *Config) HasColumn(name string) bool {
+	for _, col := range c.Columns {
+		if strings.EqualFold(col, name) {
+			return true
+		}
+	}
+	return false
+}
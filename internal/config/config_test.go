@@ -0,0 +1,117 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/mapping"
+)
+
+func TestParse(t *testing.T) {
+	input := `mod: .
+source:
+  lang: english
+target:
+  lang: polish
+  tra: tra/polish
+  encoding: windows-1250
+  female: optional
+d: [dlg, dlg/banter]
+mappings:
+  bdnpc.d: bdnpc_dlg.tra
+  BDNPCJ.d: dialogs.tra, bdnpcj.tra
+output:
+  dir: csv
+  dialect: excel-eu
+  bom: false
+columns: [Source]
+external: [JAHEIJ, IMOEN2J]
+validation:
+  missing-tra: error
+  unused-tra: off
+`
+	c, err := Parse(strings.NewReader(input), FileName)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	if c.Source.Lang != "english" || c.Target.Tra != "tra/polish" || c.Target.Encoding != "windows-1250" || c.Target.Female != FemaleOptional {
+		t.Fatalf("languages mismatch: %+v / %+v", c.Source, c.Target)
+	}
+	if !reflect.DeepEqual(c.D, []string{"dlg", "dlg/banter"}) {
+		t.Fatalf("D: got %v", c.D)
+	}
+	if c.Output.BOM == nil || *c.Output.BOM || c.Output.CRLF != nil {
+		t.Fatalf("BOM/CRLF should distinguish false from unset: %+v", c.Output)
+	}
+	if !c.HasColumn(ColumnSource) || c.HasColumn(ColumnContext) {
+		t.Fatalf("unexpected columns: %v", c.Columns)
+	}
+	if c.Validation["unused-tra"] != SeverityOff {
+		t.Fatalf("validation: got %v", c.Validation)
+	}
+
+	m, err := c.Mapping()
+	if err != nil {
+		t.Fatalf("Mapping error: %v", err)
+	}
+	want := mapping.Map{"bdnpc": {"bdnpc_dlg"}, "bdnpcj": {"dialogs", "bdnpcj"}}
+	if !reflect.DeepEqual(m, want) {
+		t.Fatalf("Mapping:\n got: %v\nwant: %v", m, want)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := map[string]string{
+		"unknown key":      "colums: [source]\n",
+		"unknown encoding": "source:\n  encoding: ebcdic\n",
+		"unknown format":   "output:\n  format: xls\n",
+		"unknown column":   "columns: [notes]\n",
+		"unknown severity": "validation:\n  missing-tra: fatal\n",
+		"unknown female":   "target:\n  female: always\n",
+		"bad mapping":      "mappings:\n  bdnpc.d: \"\"\n",
+	}
+	for name, input := range tests {
+		if _, err := Parse(strings.NewReader(input), FileName); err == nil {
+			t.Fatalf("%s: expected error", name)
+		} else if !strings.HasPrefix(err.Error(), FileName+":") {
+			t.Fatalf("%s: error should name the file: %v", name, err)
+		}
+	}
+
+	if _, err := Parse(strings.NewReader(""), FileName); err != nil {
+		t.Fatalf("empty config should be valid: %v", err)
+	}
+}
+
+func TestLoad_ResolvesPaths(t *testing.T) {
+	dir := t.TempDir()
+	input := "source:\n  tra: language/english\nd: [dlg]\ntlk: /games/bg2/dialog.tlk\noutput:\n  dir: csv\n"
+	if err := os.WriteFile(filepath.Join(dir, FileName), []byte(input), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	path := Find(dir)
+	if path == "" {
+		t.Fatalf("Find didn't find %s", FileName)
+	}
+	c, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load error: %v", err)
+	}
+
+	if c.Source.Tra != filepath.Join(dir, "language", "english") || c.D[0] != filepath.Join(dir, "dlg") ||
+		c.Output.Dir != filepath.Join(dir, "csv") || c.Dir() != dir {
+		t.Fatalf("relative paths not resolved: %+v", c)
+	}
+	if c.TLK != filepath.FromSlash("/games/bg2/dialog.tlk") {
+		t.Fatalf("absolute path changed: %q", c.TLK)
+	}
+
+	if Find(filepath.Join(dir, "missing")) != "" {
+		t.Fatalf("Find should return empty for a folder without config")
+	}
+}
@@ -0,0 +1,145 @@
+package csv
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Quoting controls which fields are enclosed in double quotes.
+type Quoting string
+
+const (
+	// QuoteMinimal quotes only fields that need it (delimiter, quotes,
+	// line breaks, leading space).
+	QuoteMinimal Quoting = "minimal"
+
+	// QuoteAll quotes every field, which keeps spreadsheets from
+	// interpreting values such as "@12" or "0012".
+	QuoteAll Quoting = "all"
+)
+
+// Dialect describes the flavour of CSV written. The zero value is plain
+// comma-separated CSV with LF line endings and no byte order mark.
+type Dialect struct {
+	Delimiter rune // field separator; 0 means ','
+	BOM       bool // start the file with a UTF-8 byte order mark
+	CRLF      bool // end lines with \r\n instead of \n
+	Quoting   Quoting
+}
+
+// Dialects are the named presets accepted by DialectByName.
+var Dialects = map[string]Dialect{
+	"default": {},
+	// Excel opens UTF-8 only with a BOM and expects CRLF
+	"excel": {Delimiter: ',', BOM: true, CRLF: true},
+	// Excel with a European locale uses ';' as list separator
+	"excel-eu": {Delimiter: ';', BOM: true, CRLF: true},
+	"gsheets":  {Delimiter: ','},
+}
+
+// DialectByName returns the preset with the given name.
+func DialectByName(name string) (Dialect, error) {
+	d, ok := Dialects[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		names := make([]string, 0, len(Dialects))
+		for n := range Dialects {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return Dialect{}, fmt.Errorf("unknown CSV dialect %q (supported: %s)", name, strings.Join(names, ", "))
+	}
+	return d, nil
+}
+
+// Comma returns the field separator.
+func (d Dialect) Comma() rune {
+	if d.Delimiter == 0 {
+		return ','
+	}
+	return d.Delimiter
+}
+
+// Validate reports options encoding/csv can't write.
+func (d Dialect) Validate() error {
+	c := d.Comma()
+	if c == '"' || c == '\r' || c == '\n' || c == utf8.RuneError {
+		return fmt.Errorf("invalid CSV delimiter %q", c)
+	}
+	switch d.Quoting {
+	case "", QuoteMinimal, QuoteAll:
+	default:
+		return fmt.Errorf("unknown quoting %q (supported: %s, %s)", d.Quoting, QuoteMinimal, QuoteAll)
+	}
+	return nil
+}
+
+// rowWriter writes records in a Dialect. It mirrors the subset of
+// encoding/csv.Writer used by the exporter.
+type rowWriter struct {
+	d   Dialect
+	buf *bufio.Writer
+	cw  *csv.Writer // used for QuoteMinimal
+	err error
+}
+
+func newRowWriter(w io.Writer, d Dialect) *rowWriter {
+	rw := &rowWriter{d: d, buf: bufio.NewWriter(w)}
+	if d.BOM {
+		_, rw.err = rw.buf.WriteString("\ufeff")
+	}
+	if d.Quoting != QuoteAll {
+		rw.cw = csv.NewWriter(rw.buf)
+		rw.cw.Comma = d.Comma()
+		rw.cw.UseCRLF = d.CRLF
+	}
+	return rw
+}
+
+func (rw *rowWriter) Write(record []string) error {
+	if rw.err != nil {
+		return rw.err
+	}
+	if rw.cw != nil {
+		return rw.cw.Write(record)
+	}
+
+	newline := "\n"
+	if rw.d.CRLF {
+		newline = "\r\n"
+	}
+	for i, field := range record {
+		if i > 0 {
+			rw.buf.WriteRune(rw.d.Comma())
+		}
+		field = strings.ReplaceAll(field, `"`, `""`)
+		if rw.d.CRLF {
+			field = strings.ReplaceAll(strings.ReplaceAll(field, "\r\n", "\n"), "\n", "\r\n")
+		}
+		rw.buf.WriteString(`"` + field + `"`)
+	}
+	_, rw.err = rw.buf.WriteString(newline)
+	return rw.err
+}
+
+func (rw *rowWriter) Flush() {
+	if rw.cw != nil {
+		rw.cw.Flush()
+	}
+	if rw.err == nil {
+		rw.err = rw.buf.Flush()
+	}
+}
+
+func (rw *rowWriter) Error() error {
+	if rw.cw != nil {
+		if err := rw.cw.Error(); err != nil {
+			return err
+		}
+	}
+	return rw.err
+}
@@ -0,0 +1,95 @@
+package csv
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/tra"
+)
+
+func TestRowWriter_Dialects(t *testing.T) {
+	rows := [][]string{
+		{"Name", "Dialog"},
+		{"@1", "Hello; \"friend\"\nbye"},
+	}
+
+	tests := []struct {
+		name    string
+		dialect Dialect
+		want    string
+	}{
+		{
+			name: "default",
+			want: "Name,Dialog\n@1,\"Hello; \"\"friend\"\"\nbye\"\n",
+		},
+		{
+			name:    "excel-eu",
+			dialect: Dialects["excel-eu"],
+			want:    "\ufeffName;Dialog\r\n@1;\"Hello; \"\"friend\"\"\r\nbye\"\r\n",
+		},
+		{
+			name:    "quote all",
+			dialect: Dialect{Delimiter: '\t', Quoting: QuoteAll},
+			want:    "\"Name\"\t\"Dialog\"\n\"@1\"\t\"Hello; \"\"friend\"\"\nbye\"\n",
+		},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		w := newRowWriter(&buf, tt.dialect)
+		for _, r := range rows {
+			if err := w.Write(r); err != nil {
+				t.Fatalf("%s: Write error: %v", tt.name, err)
+			}
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			t.Fatalf("%s: Flush error: %v", tt.name, err)
+		}
+		if buf.String() != tt.want {
+			t.Fatalf("%s:\n got: %q\nwant: %q", tt.name, buf.String(), tt.want)
+		}
+	}
+}
+
+func TestDialectByName(t *testing.T) {
+	d, err := DialectByName("Excel-EU")
+	if err != nil || d.Comma() != ';' || !d.BOM || !d.CRLF {
+		t.Fatalf("DialectByName(Excel-EU) = %+v, %v", d, err)
+	}
+	if _, err := DialectByName("lotus"); err == nil || !strings.Contains(err.Error(), "gsheets") {
+		t.Fatalf("expected error listing presets, got %v", err)
+	}
+	if err := (Dialect{Delimiter: '"'}).Validate(); err == nil {
+		t.Fatalf("expected error for quote delimiter")
+	}
+}
+
+func TestExportWithOptions_Dialect(t *testing.T) {
+	tmp := t.TempDir()
+	oldWD, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(oldWD) })
+
+	if err := os.Chdir(tmp); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+
+	tr := tra.TraByFile{"items": tra.NewTra(map[string]string{"1": "Sword"})}
+	if _, err := ExportWithOptions(nil, tr, Options{Dialect: Dialects["excel-eu"]}); err != nil {
+		t.Fatalf("ExportWithOptions: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmp, "items.csv"))
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	want := "\ufeff" + strings.Join(header, ";") + "\r\n;items;;@1;Sword;;;;TRA_ONLY;;;;\r\n"
+	if string(data) != want {
+		t.Fatalf("unexpected output:\n got: %q\nwant: %q", data, want)
+	}
+}
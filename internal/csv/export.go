@@ -1,15 +1,21 @@
 package csv
 
 import (
-	"encoding/csv"
 	"fmt"
+	"log/slog"
+	"math"
 	"os"
+	"path/filepath"
 	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/maciejjwojcik/dlg2csv/internal/d"
+	"github.com/maciejjwojcik/dlg2csv/internal/dlg"
+	"github.com/maciejjwojcik/dlg2csv/internal/tm"
+	"github.com/maciejjwojcik/dlg2csv/internal/tp2"
 	"github.com/maciejjwojcik/dlg2csv/internal/tra"
 )
 
@@ -51,14 +57,177 @@ const (
 
 	colComment = 8
 
-	// translator-only columns (must remain empty in export)
-	/* colMaleNPC   = 9
+	// translator columns
+	colMaleNPC   = 9
 	colMalePC    = 10
 	colFemaleNPC = 11
-	colFemalePC  = 12 */
+	colFemalePC  = 12
+
+	// optional columns, appended after the translator columns
+	colSource = 13
+
+	// Comment of rows listing .tra strings no .d file uses; their DialogID
+	// is the .tra file.
+	commentUnused  = "UNUSED IN .D"
+	commentTraOnly = "TRA_ONLY"
 )
 
+// Options controls optional parts of the export.
+type Options struct {
+	// SourceColumn adds a "Source" column pointing at the origin of each
+	// row, e.g. "02_dialog.d:14" for dialogue lines or "items.tra:3" for
+	// strings that are only defined in a .tra file.
+	SourceColumn bool
+
+	// ContextColumn adds a "Context" column with read-only background for
+	// translators, e.g. the dialogf.tlk variant of a vanilla string.
+	ContextColumn bool
+
+	// Strrefs resolves vanilla #strref references, usually a *tlk.Talk
+	// loaded from the user's game. When nil, their text is left empty.
+	// If it also implements FemaleStrrefLookup, differing female variants
+	// are shown in the Context column.
+	Strrefs StrrefLookup
+
+	// Vanilla gives access to the game's compiled dialogues, usually a
+	// *dlg.Override. With ContextColumn, the first row of an interjection or
+	// EXTEND block shows what the targeted vanilla state says.
+	Vanilla VanillaDialogs
+
+	// Tras maps a .d file (base name) to the .tra files it uses, in the
+	// order WeiDU loads them; later files override earlier ones. Dialogs
+	// without an entry use the .tra with the same base name. One .tra may
+	// feed several .d files.
+	Tras map[string][]string
+
+	// Setup reports where strings are used in .tp2/.tpa/.tph code, usually
+	// a *tp2.Index. With ContextColumn, rows of strings not used in any .d
+	// file show those usages.
+	Setup SetupUsages
+
+	// Dialect selects the delimiter, byte order mark, line endings and
+	// quoting of the written files, e.g. Dialects["excel-eu"].
+	Dialect Dialect
+
+	// OutDir is the folder the files are written to; it must exist.
+	// Empty means the current directory.
+	OutDir string
+
+	// Logger receives progress messages, e.g. the files written. Nil
+	// discards them.
+	Logger *slog.Logger
+
+	// Memory holds earlier translations, usually a *tm.Memory. Exact
+	// matches prefill the translator columns; otherwise the best fuzzy
+	// match is shown in a "Suggestion" column.
+	Memory TranslationMemory
+}
+
+func (o Options) logger() *slog.Logger {
+	if o.Logger == nil {
+		return slog.New(slog.DiscardHandler)
+	}
+	return o.Logger
+}
+
+// SetupUsages looks up @id references in WeiDU setup code.
+type SetupUsages interface {
+	Usages(traKey string, id int) []tp2.Usage
+}
+
+// VanillaDialogs looks up states of compiled .dlg files.
+type VanillaDialogs interface {
+	State(resref, state string) (dlg.State, bool)
+}
+
+// StrrefLookup resolves game strrefs to text.
+type StrrefLookup interface {
+	Lookup(strref int) (string, bool)
+}
+
+// TranslationMemory finds the translation of a source text, or of the
+// most similar one.
+type TranslationMemory interface {
+	Lookup(source string) (tm.Match, bool)
+}
+
+// FemaleStrrefLookup resolves game strrefs to their dialogf.tlk text.
+type FemaleStrrefLookup interface {
+	LookupFemale(strref int) (string, bool)
+}
+
+// sortTraIDs is tra.SortIDs, which ExportWithOptions can't name because
+// its tra parameter shadows the package.
+var sortTraIDs = tra.SortIDs
+
+func headerFor(opts Options) []string {
+	h := append([]string(nil), header...)
+	if opts.SourceColumn {
+		h = append(h, "Source")
+	}
+	if opts.ContextColumn {
+		h = append(h, "Context")
+	}
+	if opts.Memory != nil {
+		h = append(h, "Suggestion")
+	}
+	return h
+}
+
 func Export(dialogs d.DByFile, tra tra.TraByFile) (ExportResult, error) {
+	return ExportWithOptions(dialogs, tra, Options{})
+}
+
+func ExportWithOptions(dialogs d.DByFile, tra tra.TraByFile, opts Options) (ExportResult, error) {
+	if err := opts.Dialect.Validate(); err != nil {
+		return ExportResult{}, err
+	}
+
+	log := opts.logger()
+	header := headerFor(opts)
+	colContext := slices.Index(header, "Context")
+	colSuggestion := slices.Index(header, "Suggestion")
+
+	// suggest prefills the translator columns of a row from an exact match
+	// in the translation memory, or shows the best fuzzy match.
+	suggest := func(row []string, source string, colMale, colFemale int) {
+		if opts.Memory == nil {
+			return
+		}
+		m, ok := opts.Memory.Lookup(source)
+		switch {
+		case !ok:
+		case m.Exact():
+			row[colMale], row[colFemale] = m.Male, m.Female
+		default:
+			row[colSuggestion] = formatSuggestion(m)
+		}
+	}
+
+	setupContext := func(traKey, id string) string {
+		n, err := strconv.Atoi(id)
+		if opts.Setup == nil || err != nil {
+			return ""
+		}
+		var parts []string
+		for _, u := range opts.Setup.Usages(traKey, n) {
+			parts = append(parts, formatSetupUsage(u))
+		}
+		return strings.Join(parts, " | ")
+	}
+
+	// traContext is the context of a string not used in any .d file.
+	traContext := func(traKey, id string) string {
+		var parts []string
+		if tra[traKey].HasFemale(id) {
+			parts = append(parts, "female: "+tra[traKey].Female[id])
+		}
+		if usages := setupContext(traKey, id); usages != "" {
+			parts = append(parts, usages)
+		}
+		return strings.Join(parts, " | ")
+	}
+
 	dKeys := make([]string, 0, len(dialogs))
 	for k := range dialogs {
 		dKeys = append(dKeys, k)
@@ -68,43 +237,109 @@ func Export(dialogs d.DByFile, tra tra.TraByFile) (ExportResult, error) {
 	makeEmptyRow := func() []string {
 		return make([]string, len(header))
 	}
-	sortTraIDs := func(ids []string) {
-		sort.Slice(ids, func(i, j int) bool {
-			ai, aErr := strconv.Atoi(ids[i])
-			aj, bErr := strconv.Atoi(ids[j])
-			if aErr == nil && bErr == nil {
-				return ai < aj
-			}
-			if aErr == nil {
-				return true
+
+	res := TraResolver{Tras: tra, Mapping: opts.Tras}
+	trasFor, traFor := res.TrasFor, res.TraFor
+
+	// ids used by any .d file, per .tra; owner is the .d file whose CSV
+	// lists the unused ids of a .tra: the first one using it, or the .d
+	// with the same name. Other .tra files are exported as tra-only.
+	used := map[string]map[string]struct{}{}
+	owner := map[string]string{}
+	for _, k := range dKeys {
+		for _, o := range dialogs[k] {
+			if o.TraID == nil {
+				continue
 			}
-			if bErr == nil {
-				return false
+			if t, ok := traFor(k, *o.TraID); ok {
+				if used[t] == nil {
+					used[t] = map[string]struct{}{}
+				}
+				used[t][strconv.Itoa(*o.TraID)] = struct{}{}
+				if _, ok := owner[t]; !ok {
+					owner[t] = k
+				}
 			}
-			return ids[i] < ids[j]
-		})
+		}
+	}
+	for _, k := range dKeys {
+		if _, ok := owner[k]; !ok && slices.Contains(trasFor(k), k) {
+			owner[k] = k
+		}
 	}
 
 	// loops over .d files and retrieves values from corresponding .tra
 	for _, k := range dKeys {
-		used := map[string]struct{}{}
-		csvFileName := sanitizeFilename(k) + ".csv"
-		fmt.Println("creating:", csvFileName)
+		csvFileName := filepath.Join(opts.OutDir, sanitizeFilename(k)+".csv")
+		log.Info("writing CSV", "file", csvFileName)
 		f, err := os.Create(csvFileName)
 		if err != nil {
 			return ExportResult{}, fmt.Errorf("create %s: %w", csvFileName, err)
 		}
-		w := csv.NewWriter(f)
+		w := newRowWriter(f, opts.Dialect)
 
 		if err := w.Write(header); err != nil {
 			return ExportResult{}, fmt.Errorf("write header %s: %w", csvFileName, err)
 		}
 
-		formatTraID := func(id *int) string {
-			if id == nil {
+		formatRef := func(o d.TextOccurrence) string {
+			switch {
+			case o.StrRef != nil:
+				return fmt.Sprintf("#%d", *o.StrRef)
+			case o.TraID != nil:
+				return fmt.Sprintf("@%d", *o.TraID)
+			default:
 				return ""
 			}
-			return fmt.Sprintf("@%d", *id)
+		}
+
+		textFor := func(o d.TextOccurrence) string {
+			if o.Ref() != d.RefStrref {
+				if o.TraID == nil {
+					return ""
+				}
+				t, _ := traFor(k, *o.TraID)
+				return tra[t].GetTextByID(o.TraID)
+			}
+			if opts.Strrefs == nil {
+				return ""
+			}
+			if txt, ok := opts.Strrefs.Lookup(*o.StrRef); ok {
+				return txt
+			}
+			return fmt.Sprintf("#MISSING(#%d)", *o.StrRef)
+		}
+
+		lastVanilla := ""
+		formatContext := func(o d.TextOccurrence, text string) string {
+			var parts []string
+
+			// vanilla states targeted by the block, shown once per block
+			targets := vanillaTargets(o)
+			key := strings.Join(targets, " ")
+			if key != lastVanilla && opts.Vanilla != nil {
+				for _, t := range targets {
+					dlgName, state, _ := strings.Cut(t, ":")
+					if st, ok := opts.Vanilla.State(dlgName, state); ok {
+						parts = append(parts, formatVanillaState(t, st, opts.Strrefs))
+					}
+				}
+			}
+			lastVanilla = key
+
+			if o.Ref() == d.RefStrref {
+				if fl, ok := opts.Strrefs.(FemaleStrrefLookup); ok {
+					if female, ok := fl.LookupFemale(*o.StrRef); ok && female != text {
+						parts = append(parts, "dialogf.tlk: "+female)
+					}
+				}
+			} else if o.TraID != nil {
+				t, _ := traFor(k, *o.TraID)
+				if id := strconv.Itoa(*o.TraID); tra[t].HasFemale(id) {
+					parts = append(parts, "female: "+tra[t].Female[id])
+				}
+			}
+			return strings.Join(parts, " | ")
 		}
 
 		formatComment := func(o d.TextOccurrence, text string) string {
@@ -116,92 +351,120 @@ func Export(dialogs d.DByFile, tra tra.TraByFile) (ExportResult, error) {
 				}
 			}
 
-			notes := strings.Join(filtered, ", ")
-
-			if o.Condition == "" {
-				return notes
+			parts := make([]string, 0, 7)
+			if o.Ref() == d.RefStrref {
+				parts = append(parts, "VANILLA (no translation needed)")
 			}
-			if notes == "" {
-				return o.Condition
+			if o.Block != "" {
+				parts = append(parts, formatBlock(o))
 			}
-			return notes + " | " + o.Condition
-		}
-
-		formatGoto := func(o d.TextOccurrence) string {
-			switch strings.ToUpper(o.ToType) {
-			case "EXIT":
-				return "EXIT"
-			case "EXTERN":
-				if o.ToDlg != nil && o.ToState != nil {
-					return fmt.Sprintf("EXTERN:%s:%s", *o.ToDlg, *o.ToState)
-				}
-				return "EXTERN" // fallback
-			case "GOTO":
-				if o.ToState != nil {
-					return *o.ToState
-				}
-				return "GOTO" // fallback
-			default:
-				return ""
+			if o.Interject != nil {
+				parts = append(parts, formatInterject(o.Interject))
 			}
+			if o.Patch != "" {
+				parts = append(parts, o.Patch)
+			}
+			if o.Kind == d.KindJournal {
+				parts = append(parts, "JOURNAL")
+			}
+			if o.Branch != "" {
+				parts = append(parts, "BRANCH "+o.Branch)
+			}
+			if notes := strings.Join(filtered, ", "); notes != "" {
+				parts = append(parts, notes)
+			}
+			if o.Condition != "" {
+				parts = append(parts, o.Condition)
+			}
+			return strings.Join(parts, " | ")
 		}
+
 		occ := dialogs[k]
 
 		for _, o := range occ {
-			if o.TraID != nil {
-				used[strconv.Itoa(*o.TraID)] = struct{}{}
-			}
 			row := makeEmptyRow()
 
-			text := tra[k].GetTextByID(o.TraID)
+			text := textFor(o)
 
 			// columns always filled
 			row[colName] = o.SpeakerDlg
 			row[colDialogID] = o.Dialog
 			row[colState] = o.State
 			row[colComment] = formatComment(o, text)
+			if opts.SourceColumn {
+				row[colSource] = o.Pos.String()
+			}
+			if opts.ContextColumn {
+				row[colContext] = formatContext(o, text)
+			}
 
 			switch o.Kind {
 			case d.KindNPC:
-				row[colNPCStrref] = formatTraID(o.TraID)
+				row[colNPCStrref] = formatRef(o)
 				row[colNPCText] = text
 
 			case d.KindPC:
-				row[colPCStrref] = formatTraID(o.TraID)
+				row[colPCStrref] = formatRef(o)
 				row[colPCText] = text
-				row[colGoto] = formatGoto(o)
+				row[colGoto] = FormatGoto(o)
+
+			case d.KindJournal:
+				row[colNPCStrref] = formatRef(o)
+				row[colNPCText] = text
 
 			default:
 				continue
 			}
 
-			// translator columns intentionally left empty:
-			// colMaleNPC, colMalePC, colFemaleNPC, colFemalePC
+			// translator columns are left empty, unless the translation
+			// memory knows the string; vanilla strings need no translation
+			if o.Ref() != d.RefStrref {
+				if o.Kind == d.KindPC {
+					suggest(row, text, colMalePC, colFemalePC)
+				} else {
+					suggest(row, text, colMaleNPC, colFemaleNPC)
+				}
+			}
 
 			if err := w.Write(row); err != nil {
 				return ExportResult{}, fmt.Errorf("write row %s: %w", csvFileName, err)
 			}
 		}
 
-		ids := make([]string, 0, len(tra[k].Texts))
-		for id := range tra[k].Texts {
-			if _, ok := used[id]; ok {
+		listed := map[string]bool{}
+		for _, t := range trasFor(k) {
+			if owner[t] != k || listed[t] {
 				continue
 			}
-			ids = append(ids, id)
-		}
-		sortTraIDs(ids)
+			listed[t] = true
 
-		for _, id := range ids {
-			row := makeEmptyRow()
-
-			row[colDialogID] = k
-			row[colNPCStrref] = "@" + id
-			row[colNPCText] = tra[k].Texts[id]
-			row[colComment] = "UNUSED IN .D"
+			ids := make([]string, 0, len(tra[t].Texts))
+			for id := range tra[t].Texts {
+				if _, ok := used[t][id]; ok {
+					continue
+				}
+				ids = append(ids, id)
+			}
+			sortTraIDs(ids)
+
+			for _, id := range ids {
+				row := makeEmptyRow()
+
+				row[colDialogID] = t
+				row[colNPCStrref] = "@" + id
+				row[colNPCText] = tra[t].Texts[id]
+				row[colComment] = commentUnused
+				suggest(row, tra[t].Texts[id], colMaleNPC, colFemaleNPC)
+				if opts.SourceColumn {
+					row[colSource] = tra[t].Pos[id].String()
+				}
+				if opts.ContextColumn {
+					row[colContext] = traContext(t, id)
+				}
 
-			if err := w.Write(row); err != nil {
-				return ExportResult{}, fmt.Errorf("write unused row %s: %w", csvFileName, err)
+				if err := w.Write(row); err != nil {
+					return ExportResult{}, fmt.Errorf("write unused row %s: %w", csvFileName, err)
+				}
 			}
 		}
 
@@ -225,18 +488,22 @@ func Export(dialogs d.DByFile, tra tra.TraByFile) (ExportResult, error) {
 	sort.Strings(traKeys)
 
 	for _, k := range traKeys {
-		if _, hasDialog := dialogs[k]; hasDialog {
+		if _, hasDialog := owner[k]; hasDialog {
 			continue
 		}
 
-		csvFileName := sanitizeFilename(k) + ".csv"
-		fmt.Println("creating (tra-only):", csvFileName)
+		csvFileName := filepath.Join(opts.OutDir, sanitizeFilename(k)+".csv")
+		if _, clash := dialogs[k]; clash {
+			// a .d with this name is mapped to other .tra files
+			csvFileName = filepath.Join(opts.OutDir, sanitizeFilename(k)+"_tra.csv")
+		}
+		log.Info("writing CSV", "file", csvFileName, "tra-only", true)
 
 		f, err := os.Create(csvFileName)
 		if err != nil {
 			return ExportResult{}, fmt.Errorf("create %s: %w", csvFileName, err)
 		}
-		w := csv.NewWriter(f)
+		w := newRowWriter(f, opts.Dialect)
 
 		if err := w.Write(header); err != nil {
 			return ExportResult{}, fmt.Errorf("write header %s: %w", csvFileName, err)
@@ -256,7 +523,14 @@ func Export(dialogs d.DByFile, tra tra.TraByFile) (ExportResult, error) {
 			row[colDialogID] = k
 			row[colNPCStrref] = "@" + id
 			row[colNPCText] = t.Texts[id]
-			row[colComment] = "TRA_ONLY"
+			row[colComment] = commentTraOnly
+			suggest(row, t.Texts[id], colMaleNPC, colFemaleNPC)
+			if opts.SourceColumn {
+				row[colSource] = t.Pos[id].String()
+			}
+			if opts.ContextColumn {
+				row[colContext] = traContext(k, id)
+			}
 
 			if err := w.Write(row); err != nil {
 				if err := f.Close(); err != nil {
@@ -281,6 +555,184 @@ func Export(dialogs d.DByFile, tra tra.TraByFile) (ExportResult, error) {
 	return ExportResult{}, nil
 }
 
+// TraResolver tells which .tra file an @id of a dialog is read from, as
+// ExportWithOptions does.
+type TraResolver struct {
+	Tras    tra.TraByFile
+	Mapping map[string][]string // Options.Tras
+}
+
+// TrasFor returns the .tra files used by dialog k, in load order.
+func (r TraResolver) TrasFor(k string) []string {
+	if keys := r.Mapping[k]; len(keys) > 0 {
+		return keys
+	}
+	return []string{k}
+}
+
+// TraFor returns the .tra (of those used by dialog k) defining @id; the
+// last one loaded wins. If none does, it returns the last one and false.
+func (r TraResolver) TraFor(k string, id int) (string, bool) {
+	keys := r.TrasFor(k)
+	key := strconv.Itoa(id)
+	for i := len(keys) - 1; i >= 0; i-- {
+		if _, ok := r.Tras[keys[i]].Texts[key]; ok {
+			return keys[i], true
+		}
+	}
+	return keys[len(keys)-1], false
+}
+
+// Usages returns the lines of dialogs using each .tra string, by .tra
+// file and @id, reading an @id from the .tra file ExportWithOptions would
+// with the same mapping (Options.Tras). Lines of dialogs are kept in the
+// order of the sorted .d files.
+func Usages(dialogs d.DByFile, tras tra.TraByFile, mapping map[string][]string) map[string]map[string][]d.TextOccurrence {
+	res := TraResolver{Tras: tras, Mapping: mapping}
+	dKeys := make([]string, 0, len(dialogs))
+	for k := range dialogs {
+		dKeys = append(dKeys, k)
+	}
+	sort.Strings(dKeys)
+
+	out := map[string]map[string][]d.TextOccurrence{}
+	for _, k := range dKeys {
+		for _, o := range dialogs[k] {
+			if o.TraID == nil || o.Ref() == d.RefStrref {
+				continue
+			}
+			t, ok := res.TraFor(k, *o.TraID)
+			if !ok {
+				continue
+			}
+			if out[t] == nil {
+				out[t] = map[string][]d.TextOccurrence{}
+			}
+			id := strconv.Itoa(*o.TraID)
+			out[t][id] = append(out[t][id], o)
+		}
+	}
+	return out
+}
+
+// FormatGoto describes where a transition leads as in the Goto column,
+// e.g. "EXIT", "12" or "EXTERN:BDNPC:12"; empty for lines without one.
+func FormatGoto(o d.TextOccurrence) string {
+	switch strings.ToUpper(o.ToType) {
+	case "EXIT":
+		return "EXIT"
+	case "EXTERN":
+		if o.ToDlg != nil && o.ToState != nil {
+			return fmt.Sprintf("EXTERN:%s:%s", *o.ToDlg, *o.ToState)
+		}
+		return "EXTERN" // fallback
+	case "GOTO":
+		if o.ToState != nil {
+			return *o.ToState
+		}
+		return "GOTO" // fallback
+	case "COPY_TRANS":
+		if o.ToDlg != nil && o.ToState != nil {
+			return fmt.Sprintf("COPY_TRANS:%s:%s", *o.ToDlg, *o.ToState)
+		}
+		return "COPY_TRANS" // fallback
+	default:
+		return ""
+	}
+}
+
+// formatBlock describes the enclosing APPEND/REPLACE/EXTEND_* block,
+// e.g. "REPLACE" or "EXTEND_BOTTOM 6 7 #4".
+func formatBlock(o d.TextOccurrence) string {
+	if o.Extend == nil {
+		return o.Block
+	}
+	s := o.Block + " " + strings.Join(o.Extend.States, " ")
+	if o.Extend.Position != nil {
+		s += fmt.Sprintf(" #%d", *o.Extend.Position)
+	}
+	return s
+}
+
+// formatInterject describes an INTERJECT-family block for the Comment column,
+// e.g. "INTERJECT_COPY_TRANS JAHEIJ:12 (copies transitions)".
+func formatInterject(ij *d.Interjection) string {
+	s := fmt.Sprintf("%s %s:%s", ij.Keyword, ij.Dlg, ij.State)
+	if ij.CopyTrans {
+		s += " (copies transitions)"
+	}
+	return s
+}
+
+// vanillaTargets lists the "DLG:state" targets of an interjection or EXTEND
+// block the occurrence belongs to.
+func vanillaTargets(o d.TextOccurrence) []string {
+	switch {
+	case o.Interject != nil:
+		return []string{o.Interject.Dlg + ":" + o.Interject.State}
+	case o.Extend != nil:
+		targets := make([]string, 0, len(o.Extend.States))
+		for _, st := range o.Extend.States {
+			targets = append(targets, o.Dialog+":"+st)
+		}
+		return targets
+	default:
+		return nil
+	}
+}
+
+// formatVanillaState renders a compiled state for the Context column, e.g.
+// `JAHEIJ:12 "Npc line." -> "Reply one." / "Reply two."`. Strrefs are shown
+// as #n when they can't be resolved.
+func formatVanillaState(target string, st dlg.State, strrefs StrrefLookup) string {
+	text := func(strref int) string {
+		if strrefs != nil {
+			if s, ok := strrefs.Lookup(strref); ok {
+				return strconv.Quote(s)
+			}
+		}
+		return fmt.Sprintf("#%d", strref)
+	}
+
+	s := target + " " + text(st.Text)
+	var replies []string
+	for _, tr := range st.Transitions {
+		if tr.HasText() {
+			replies = append(replies, text(tr.Text))
+		}
+	}
+	if len(replies) > 0 {
+		s += " -> " + strings.Join(replies, " / ")
+	}
+	return s
+}
+
+// formatSuggestion shows a fuzzy match of the translation memory, e.g.
+// `87% "Farewell, friend." -> "Żegnaj, przyjacielu."`.
+func formatSuggestion(m tm.Match) string {
+	s := fmt.Sprintf("%.0f%% %q -> %q", math.Floor(m.Score*100), m.Source, m.Male)
+	if m.Female != "" {
+		s += fmt.Sprintf(" / %q", m.Female)
+	}
+	return s
+}
+
+// formatSetupUsage describes a setup-code usage for the Context column,
+// e.g. "setup-mymod.tp2:14 component 2 SAY DESC AC#SWRD.ITM".
+func formatSetupUsage(u tp2.Usage) string {
+	parts := []string{u.Pos.String()}
+	if u.Component >= 0 {
+		parts = append(parts, fmt.Sprintf("component %d", u.Component))
+	}
+	if u.Action != "" {
+		parts = append(parts, u.Action)
+	}
+	if u.Target != "" {
+		parts = append(parts, u.Target)
+	}
+	return strings.Join(parts, " ")
+}
+
 func sanitizeFilename(s string) string {
 	re := regexp.MustCompile(`[^A-Za-z0-9._-]+`)
 	return re.ReplaceAllString(s, "_")
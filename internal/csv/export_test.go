@@ -8,7 +8,10 @@ import (
 	"testing"
 
 	"github.com/maciejjwojcik/dlg2csv/internal/d"
+	"github.com/maciejjwojcik/dlg2csv/internal/dlg"
+	"github.com/maciejjwojcik/dlg2csv/internal/tp2"
 	"github.com/maciejjwojcik/dlg2csv/internal/tra"
+	helpers "github.com/maciejjwojcik/dlg2csv/internal/utils"
 )
 
 var wantHeader = []string{
@@ -242,6 +245,485 @@ func TestExport_GotoFormatting_EXIT_and_EXTERNFallback(t *testing.T) {
 
 }
 
+func TestExportWithOptions_SourceColumn(t *testing.T) {
+	tmp := t.TempDir()
+	oldWD, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(oldWD) })
+
+	if err := os.Chdir(tmp); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+
+	id1 := 1
+
+	dialogs := d.DByFile{
+		"04": {
+			{
+				Kind:       d.KindNPC,
+				TraID:      &id1,
+				SpeakerDlg: "D",
+				Dialog:     "D",
+				State:      "S",
+				Pos:        helpers.Pos{File: "04.d", Line: 14, Col: 7},
+			},
+		},
+	}
+
+	t04 := tra.NewTra(map[string]string{"1": "Hi", "2": "Unused"})
+	t04.Pos = map[string]helpers.Pos{
+		"1": {File: "04.tra", Line: 1, Col: 1},
+		"2": {File: "04.tra", Line: 2, Col: 1},
+	}
+	tr := tra.TraByFile{"04": t04}
+
+	if _, err := ExportWithOptions(dialogs, tr, Options{SourceColumn: true}); err != nil {
+		t.Fatalf("ExportWithOptions: %v", err)
+	}
+
+	got := mustReadCSV(t, filepath.Join(tmp, "04.csv"))
+	if len(got) != 3 {
+		t.Fatalf("expected 3 rows (header + 2), got %d: %#v", len(got), got)
+	}
+
+	if got[0][colSource] != "Source" {
+		t.Fatalf("expected Source header, got %#v", got[0])
+	}
+	if got[1][colSource] != "04.d:14" {
+		t.Fatalf("expected dialog row source 04.d:14, got %q", got[1][colSource])
+	}
+	if got[2][colSource] != "04.tra:2" {
+		t.Fatalf("expected unused row source 04.tra:2, got %q", got[2][colSource])
+	}
+}
+
+func TestExport_InterjectCopyTrans_AddsTargetContext(t *testing.T) {
+	tmp := t.TempDir()
+	oldWD, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(oldWD) })
+
+	if err := os.Chdir(tmp); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+
+	id1 := 1
+	ict := &d.Interjection{Keyword: "INTERJECT_COPY_TRANS", Dlg: "JAHEIJ", State: "12", Var: "AC#V", CopyTrans: true}
+
+	dialogs := d.DByFile{
+		"05": {
+			{
+				Kind:       d.KindNPC,
+				TraID:      &id1,
+				SpeakerDlg: "AC#NPCJ",
+				Dialog:     "JAHEIJ",
+				State:      "12",
+				Condition:  `InParty("AC#NPC")`,
+				Interject:  ict,
+			},
+		},
+	}
+	tr := tra.TraByFile{"05": mustMakeTra(t, map[string]string{"1": "Hey!"})}
+
+	if _, err := Export(dialogs, tr); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	got := mustReadCSV(t, filepath.Join(tmp, "05.csv"))
+	want := `INTERJECT_COPY_TRANS JAHEIJ:12 (copies transitions) | InParty("AC#NPC")`
+	if got[1][colComment] != want {
+		t.Fatalf("comment mismatch:\n got: %q\nwant: %q", got[1][colComment], want)
+	}
+}
+
+func TestExport_PatchJournal_IsExportedNotUnused(t *testing.T) {
+	tmp := t.TempDir()
+	oldWD, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(oldWD) })
+
+	if err := os.Chdir(tmp); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+
+	id3 := 3
+
+	dialogs := d.DByFile{
+		"06": {
+			{
+				Kind:   d.KindJournal,
+				TraID:  &id3,
+				Dialog: "PLAYER1",
+				State:  "33",
+				Patch:  "ALTER_TRANS",
+			},
+		},
+	}
+	tr := tra.TraByFile{"06": mustMakeTra(t, map[string]string{"3": "Journal entry."})}
+
+	if _, err := Export(dialogs, tr); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	got := mustReadCSV(t, filepath.Join(tmp, "06.csv"))
+	want := [][]string{
+		wantHeader,
+		padToHeaderLen([]string{
+			"", "PLAYER1", "33",
+			"@3", "Journal entry.",
+			"", "", "",
+			"ALTER_TRANS | JOURNAL",
+		}),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("csv mismatch\nGOT : %#v\nWANT: %#v", got, want)
+	}
+}
+
+type mapStrrefs map[int]string
+
+func (m mapStrrefs) Lookup(strref int) (string, bool) {
+	s, ok := m[strref]
+	return s, ok
+}
+
+func TestExportWithOptions_StrrefsResolvedFromTLK(t *testing.T) {
+	tmp := t.TempDir()
+	oldWD, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(oldWD) })
+
+	if err := os.Chdir(tmp); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+
+	say, reply, missing := 12345, 678, 999
+
+	dialogs := d.DByFile{
+		"07": {
+			{Kind: d.KindNPC, StrRef: &say, SpeakerDlg: "AC#NPC", Dialog: "AC#NPC", State: "0"},
+			{Kind: d.KindPC, StrRef: &reply, Dialog: "AC#NPC", State: "0", ToType: "EXIT"},
+			{Kind: d.KindPC, StrRef: &missing, Dialog: "AC#NPC", State: "0", ToType: "EXIT"},
+		},
+	}
+	tr := tra.TraByFile{"07": mustMakeTra(t, map[string]string{})}
+	opts := Options{Strrefs: mapStrrefs{12345: "Hail, traveler.", 678: "Farewell."}}
+
+	if _, err := ExportWithOptions(dialogs, tr, opts); err != nil {
+		t.Fatalf("ExportWithOptions: %v", err)
+	}
+
+	got := mustReadCSV(t, filepath.Join(tmp, "07.csv"))
+	want := [][]string{
+		wantHeader,
+		padToHeaderLen([]string{
+			"AC#NPC", "AC#NPC", "0",
+			"#12345", "Hail, traveler.",
+			"", "", "",
+			"VANILLA (no translation needed)",
+		}),
+		padToHeaderLen([]string{
+			"", "AC#NPC", "0",
+			"", "",
+			"#678", "Farewell.", "EXIT",
+			"VANILLA (no translation needed)",
+		}),
+		padToHeaderLen([]string{
+			"", "AC#NPC", "0",
+			"", "",
+			"#999", "#MISSING(#999)", "EXIT",
+			"VANILLA (no translation needed)",
+		}),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("csv mismatch\nGOT : %#v\nWANT: %#v", got, want)
+	}
+}
+
+// genderedStrrefs mimics a dialog.tlk / dialogf.tlk pair.
+type genderedStrrefs struct {
+	male, female mapStrrefs
+}
+
+func (g genderedStrrefs) Lookup(strref int) (string, bool) { return g.male.Lookup(strref) }
+
+func (g genderedStrrefs) LookupFemale(strref int) (string, bool) { return g.female.Lookup(strref) }
+
+func TestExportWithOptions_ContextColumn_FemaleVariant(t *testing.T) {
+	tmp := t.TempDir()
+	oldWD, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(oldWD) })
+
+	if err := os.Chdir(tmp); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+
+	gendered, same, id1 := 10, 11, 1
+
+	dialogs := d.DByFile{
+		"08": {
+			{Kind: d.KindNPC, StrRef: &gendered, Dialog: "D", State: "0"},
+			{Kind: d.KindNPC, StrRef: &same, Dialog: "D", State: "0"},
+			{Kind: d.KindNPC, TraID: &id1, Dialog: "D", State: "0"},
+		},
+	}
+	tr := tra.TraByFile{"08": mustMakeTra(t, map[string]string{"1": "Mod line."})}
+	opts := Options{
+		ContextColumn: true,
+		Strrefs: genderedStrrefs{
+			male:   mapStrrefs{10: "Welcome, sir.", 11: "Farewell."},
+			female: mapStrrefs{10: "Welcome, milady.", 11: "Farewell."},
+		},
+	}
+
+	if _, err := ExportWithOptions(dialogs, tr, opts); err != nil {
+		t.Fatalf("ExportWithOptions: %v", err)
+	}
+
+	got := mustReadCSV(t, filepath.Join(tmp, "08.csv"))
+	if len(got) != 4 {
+		t.Fatalf("expected 4 rows (header + 3), got %d: %#v", len(got), got)
+	}
+
+	colContext := len(header)
+	if got[0][colContext] != "Context" {
+		t.Fatalf("expected Context header, got %#v", got[0])
+	}
+	wantContext := []string{"dialogf.tlk: Welcome, milady.", "", ""}
+	for i, want := range wantContext {
+		if got[i+1][colContext] != want {
+			t.Fatalf("row %d context: got %q, want %q", i+1, got[i+1][colContext], want)
+		}
+	}
+	if got[1][colNPCText] != "Welcome, sir." {
+		t.Fatalf("expected male vanilla text in Dialog column, got %q", got[1][colNPCText])
+	}
+}
+
+type mapVanilla map[string]dlg.State
+
+func (m mapVanilla) State(resref, state string) (dlg.State, bool) {
+	st, ok := m[resref+":"+state]
+	return st, ok
+}
+
+func TestExportWithOptions_ContextColumn_VanillaTargetState(t *testing.T) {
+	tmp := t.TempDir()
+	oldWD, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(oldWD) })
+
+	if err := os.Chdir(tmp); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+
+	id1, id2, id3 := 1, 2, 3
+	ij := &d.Interjection{Keyword: "INTERJECT_COPY_TRANS", Dlg: "JAHEIJ", State: "12", CopyTrans: true}
+
+	dialogs := d.DByFile{
+		"09": {
+			{Kind: d.KindNPC, TraID: &id1, SpeakerDlg: "AC#NPCJ", Dialog: "JAHEIJ", State: "12", Interject: ij},
+			{Kind: d.KindNPC, TraID: &id2, SpeakerDlg: "JAHEIJ", Dialog: "JAHEIJ", State: "12", Interject: ij},
+			{Kind: d.KindPC, TraID: &id3, Dialog: "PLAYER1", State: "33", Block: "EXTEND_BOTTOM",
+				Extend: &d.Extension{States: []string{"33"}}, ToType: "EXIT"},
+		},
+	}
+	tr := tra.TraByFile{"09": mustMakeTra(t, map[string]string{"1": "Mod line.", "2": "Reply.", "3": "New reply."})}
+	opts := Options{
+		ContextColumn: true,
+		Strrefs:       mapStrrefs{500: "Vanilla line.", 501: "Vanilla reply."},
+		Vanilla: mapVanilla{
+			"JAHEIJ:12": {Text: 500, Transitions: []dlg.Transition{
+				{Flags: dlg.FlagHasText, Text: 501},
+				{Flags: 0, Text: -1},
+			}},
+			"PLAYER1:33": {Text: 777},
+		},
+	}
+
+	if _, err := ExportWithOptions(dialogs, tr, opts); err != nil {
+		t.Fatalf("ExportWithOptions: %v", err)
+	}
+
+	got := mustReadCSV(t, filepath.Join(tmp, "09.csv"))
+	colContext := len(header)
+	want := []string{
+		`JAHEIJ:12 "Vanilla line." -> "Vanilla reply."`,
+		"", // same interjection, shown once
+		"PLAYER1:33 #777",
+	}
+	for i, w := range want {
+		if got[i+1][colContext] != w {
+			t.Fatalf("row %d context: got %q, want %q", i+1, got[i+1][colContext], w)
+		}
+	}
+}
+
+func TestExportWithOptions_ContextColumn_SetupUsages(t *testing.T) {
+	tmp := t.TempDir()
+	oldWD, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(oldWD) })
+
+	if err := os.Chdir(tmp); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+
+	tr := tra.TraByFile{"items": mustMakeTra(t, map[string]string{
+		"1": "Sword of Testing",
+		"2": "Unused.",
+	})}
+	opts := Options{
+		ContextColumn: true,
+		Setup: tp2.NewIndex([]tp2.Usage{
+			{TraID: 1, Pos: helpers.Pos{File: "setup-mymod.tp2", Line: 14}, Component: 2,
+				Action: "SAY NAME1", Target: "AC#SWRD.ITM", Tras: []string{"items"}},
+			{TraID: 1, Pos: helpers.Pos{File: "lib/items.tpa", Line: 3}, Component: -1, Action: "STRING_SET"},
+			{TraID: 2, Pos: helpers.Pos{File: "setup-mymod.tp2", Line: 20}, Component: 0, Tras: []string{"setup"}},
+		}),
+	}
+
+	if _, err := ExportWithOptions(d.DByFile{}, tr, opts); err != nil {
+		t.Fatalf("ExportWithOptions: %v", err)
+	}
+
+	got := mustReadCSV(t, filepath.Join(tmp, "items.csv"))
+	colContext := len(header)
+	want := []string{
+		"setup-mymod.tp2:14 component 2 SAY NAME1 AC#SWRD.ITM | lib/items.tpa:3 STRING_SET",
+		"", // used by another .tra file's @2
+	}
+	for i, w := range want {
+		if got[i+1][colContext] != w {
+			t.Fatalf("row %d context: got %q, want %q", i+1, got[i+1][colContext], w)
+		}
+	}
+}
+
+func TestExportWithOptions_TraMapping_SharedAndLayered(t *testing.T) {
+	tmp := t.TempDir()
+	oldWD, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("getwd: %v", err)
+	}
+	t.Cleanup(func() { _ = os.Chdir(oldWD) })
+
+	if err := os.Chdir(tmp); err != nil {
+		t.Fatalf("chdir: %v", err)
+	}
+
+	id1, id2, id5 := 1, 2, 5
+
+	dialogs := d.DByFile{
+		"bdnpc":  {{Kind: d.KindNPC, TraID: &id1, Dialog: "BDNPC", State: "0"}},
+		"bdnpcj": {{Kind: d.KindNPC, TraID: &id2, Dialog: "BDNPCJ", State: "0"}, {Kind: d.KindNPC, TraID: &id5, Dialog: "BDNPCJ", State: "1"}},
+	}
+	tr := tra.TraByFile{
+		"setup":    mustMakeTra(t, map[string]string{"1": "Component", "2": "Overridden"}),
+		"dialogs":  mustMakeTra(t, map[string]string{"1": "Hello.", "2": "Bye.", "3": "Unused."}),
+		"bdnpc":    mustMakeTra(t, map[string]string{"9": "Same name, not mapped."}),
+		"unmapped": mustMakeTra(t, map[string]string{"1": "Items."}),
+	}
+	opts := Options{Tras: map[string][]string{
+		"bdnpc":  {"setup", "dialogs"},
+		"bdnpcj": {"setup", "dialogs"},
+	}}
+
+	if _, err := ExportWithOptions(dialogs, tr, opts); err != nil {
+		t.Fatalf("ExportWithOptions: %v", err)
+	}
+
+	bdnpc := mustReadCSV(t, filepath.Join(tmp, "bdnpc.csv"))
+	want := [][]string{
+		wantHeader,
+		padToHeaderLen([]string{"", "BDNPC", "0", "@1", "Hello."}),
+		padToHeaderLen([]string{"", "dialogs", "", "@3", "Unused.", "", "", "", "UNUSED IN .D"}),
+	}
+	if !reflect.DeepEqual(bdnpc, want) {
+		t.Fatalf("bdnpc.csv mismatch\nGOT : %#v\nWANT: %#v", bdnpc, want)
+	}
+
+	bdnpcj := mustReadCSV(t, filepath.Join(tmp, "bdnpcj.csv"))
+	want = [][]string{
+		wantHeader,
+		padToHeaderLen([]string{"", "BDNPCJ", "0", "@2", "Bye."}),
+		padToHeaderLen([]string{"", "BDNPCJ", "1", "@5", "#MISSING(@5)"}),
+	}
+	if !reflect.DeepEqual(bdnpcj, want) {
+		t.Fatalf("bdnpcj.csv mismatch\nGOT : %#v\nWANT: %#v", bdnpcj, want)
+	}
+
+	// .tra files no dialog uses are exported flat
+	for _, name := range []string{"setup.csv", "unmapped.csv", "bdnpc_tra.csv"} {
+		if _, err := os.Stat(filepath.Join(tmp, name)); err != nil {
+			t.Fatalf("expected tra-only %s: %v", name, err)
+		}
+	}
+}
+
+func TestUsages_TraMapping(t *testing.T) {
+	id1, id2, id5 := 1, 2, 5
+	strref := 100
+	dialogs := d.DByFile{
+		"bdnpc":  {{Kind: d.KindNPC, TraID: &id1, Dialog: "BDNPC", State: "0"}, {Kind: d.KindPC, StrRef: &strref, Dialog: "BDNPC", State: "0"}},
+		"bdnpcj": {{Kind: d.KindNPC, TraID: &id2, Dialog: "BDNPCJ", State: "0"}, {Kind: d.KindNPC, TraID: &id1, Dialog: "BDNPCJ", State: "1"}, {Kind: d.KindNPC, TraID: &id5, Dialog: "BDNPCJ", State: "2"}},
+	}
+	tr := tra.TraByFile{
+		"setup":   mustMakeTra(t, map[string]string{"2": "Overridden"}),
+		"dialogs": mustMakeTra(t, map[string]string{"1": "Hello.", "2": "Bye."}),
+	}
+	mapping := map[string][]string{"bdnpc": {"setup", "dialogs"}, "bdnpcj": {"setup", "dialogs"}}
+
+	got := Usages(dialogs, tr, mapping)
+	if len(got) != 1 || len(got["dialogs"]) != 2 {
+		t.Fatalf("expected @1 and @2 of dialogs only, got %v", got)
+	}
+	var states []string
+	for _, o := range got["dialogs"]["1"] {
+		states = append(states, o.Dialog+":"+o.State)
+	}
+	if want := []string{"BDNPC:0", "BDNPCJ:1"}; !reflect.DeepEqual(states, want) {
+		t.Fatalf("usages of @1 = %v, want %v", states, want)
+	}
+}
+
+func TestFormatBlock(t *testing.T) {
+	pos := 4
+	tests := []struct {
+		name string
+		in   d.TextOccurrence
+		want string
+	}{
+		{"none", d.TextOccurrence{}, ""},
+		{"replace", d.TextOccurrence{Block: "REPLACE"}, "REPLACE"},
+		{"extend_no_position", d.TextOccurrence{Block: "EXTEND_TOP", Extend: &d.Extension{States: []string{"33"}}}, "EXTEND_TOP 33"},
+		{"extend_with_position", d.TextOccurrence{Block: "EXTEND_BOTTOM", Extend: &d.Extension{States: []string{"6", "7"}, Position: &pos}}, "EXTEND_BOTTOM 6 7 #4"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := formatBlock(tc.in); got != tc.want {
+				t.Fatalf("formatBlock()=%q want %q", got, tc.want)
+			}
+		})
+	}
+}
+
 func TestSanitizeFilename(t *testing.T) {
 	tests := []struct {
 		in, want string
@@ -0,0 +1,178 @@
+package csv
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/d"
+	"github.com/maciejjwojcik/dlg2csv/internal/tra"
+	helpers "github.com/maciejjwojcik/dlg2csv/internal/utils"
+)
+
+// Translation is a translated string read back from the CSV files.
+type Translation struct {
+	Male   string
+	Female string // empty if the translator didn't give a female variant
+
+	// Pos is the CSV file and row (counting the header as row 1).
+	Pos helpers.Pos
+}
+
+// Translations holds the translations of each .tra file (base name) by @id.
+type Translations map[string]map[string]Translation
+
+// DropFemaleOnly removes the strings with a female translation but no male
+// one, which Import refuses, and returns how many it removed. Readers of
+// formats with a unit per variant (TMX, XLIFF, PO) use it.
+func (t Translations) DropFemaleOnly() int {
+	n := 0
+	for k, ids := range t {
+		for id, tr := range ids {
+			if tr.Male == "" {
+				delete(ids, id)
+				n++
+			}
+		}
+		if len(ids) == 0 {
+			delete(t, k)
+		}
+	}
+	return n
+}
+
+// Import reads the translator columns of the CSV files in dir, exported
+// from dialogs and tras with the same opts.Tras, and returns the
+// translations by .tra file. The same string translated differently in
+// two rows is an error.
+func Import(dialogs d.DByFile, tras tra.TraByFile, dir string, opts Options) (Translations, error) {
+	res := TraResolver{Tras: tras, Mapping: opts.Tras}
+
+	// CSV file name -> .d file, as named by ExportWithOptions
+	byFile := map[string]string{}
+	for k := range dialogs {
+		byFile[sanitizeFilename(k)+".csv"] = k
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, ent := range entries {
+		if !ent.IsDir() && strings.EqualFold(filepath.Ext(ent.Name()), ".csv") {
+			files = append(files, ent.Name())
+		}
+	}
+	sort.Strings(files)
+
+	out := Translations{}
+	for _, name := range files {
+		rows, err := ReadFile(filepath.Join(dir, name), opts.Dialect)
+		if err != nil {
+			return nil, err
+		}
+		if len(rows) == 0 {
+			continue
+		}
+		cols := columnsOf(rows[0])
+		if _, ok := cols[header[colNPCStrref]]; !ok {
+			opts.logger().Debug("skipping CSV, not an exported sheet", "file", name)
+			continue
+		}
+		dKey, isDialog := byFile[strings.ToLower(name)]
+
+		for i, row := range rows[1:] {
+			pos := helpers.Pos{File: name, Line: i + 2}
+			comment := cols.get(row, header[colComment])
+			traOnly := comment == commentUnused || comment == commentTraOnly
+
+			for _, c := range [][3]int{
+				{colNPCStrref, colMaleNPC, colFemaleNPC},
+				{colPCStrref, colMalePC, colFemalePC},
+			} {
+				ref := strings.TrimSpace(cols.get(row, header[c[0]]))
+				male := cols.get(row, header[c[1]])
+				female := cols.get(row, header[c[2]])
+				if !strings.HasPrefix(ref, "@") || (male == "" && female == "") {
+					continue
+				}
+				id := ref[1:]
+				if male == "" {
+					return nil, fmt.Errorf("%s: %s has a female but no male translation", pos, ref)
+				}
+
+				var traKey string
+				switch n, err := strconv.Atoi(id); {
+				case traOnly:
+					traKey = strings.ToLower(cols.get(row, header[colDialogID]))
+				case !isDialog:
+					return nil, fmt.Errorf("%s: no .d file matches %s", pos, name)
+				case err != nil:
+					return nil, fmt.Errorf("%s: invalid reference %s", pos, ref)
+				default:
+					traKey, _ = res.TraFor(dKey, n)
+				}
+
+				t := Translation{Male: male, Female: female, Pos: pos}
+				if prev, ok := out[traKey][id]; ok {
+					if prev.Male != t.Male || prev.Female != t.Female {
+						return nil, fmt.Errorf("%s: @%s of %s.tra is translated differently in %s", pos, id, traKey, prev.Pos)
+					}
+					continue
+				}
+				if out[traKey] == nil {
+					out[traKey] = map[string]Translation{}
+				}
+				out[traKey][id] = t
+			}
+		}
+	}
+	return out, nil
+}
+
+// TraEntries lists the strings of a source .tra with their translations,
+// ready for tra.Write. Untranslated strings keep the source text, female variant
+// included, unless skipUntranslated is set; their ids are returned as missing.
+func TraEntries(source tra.Tra, translated map[string]Translation, skipUntranslated bool) (entries []tra.Entry, missing []string) {
+	for _, id := range source.IDs() {
+		t, ok := translated[id]
+		if !ok {
+			missing = append(missing, id)
+			if skipUntranslated {
+				continue
+			}
+			t.Male, t.Female = source.Texts[id], source.Female[id]
+		}
+		entries = append(entries, tra.Entry{ID: id, Male: t.Male, Female: t.Female})
+	}
+	return entries, missing
+}
+
+// TraTranslations pairs the strings of source with the strings of target
+// having the same file and @id, e.g. to fill in an XLIFF or PO export
+// from an earlier translation. Strings left in the source language are
+// skipped.
+func TraTranslations(source, target tra.TraByFile) Translations {
+	out := Translations{}
+	for k, t := range target {
+		for id, text := range t.Texts {
+			src, ok := source[k].Texts[id]
+			if !ok || text == src {
+				continue
+			}
+			if out[k] == nil {
+				out[k] = map[string]Translation{}
+			}
+			tr := Translation{Male: text, Pos: t.Pos[id]}
+			if t.HasFemale(id) {
+				tr.Female = t.Female[id]
+			}
+			out[k][id] = tr
+		}
+	}
+	return out
+}
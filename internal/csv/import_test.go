@@ -0,0 +1,137 @@
+package csv
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/d"
+	"github.com/maciejjwojcik/dlg2csv/internal/tra"
+)
+
+func TestImport_RoundTrip(t *testing.T) {
+	tmp := t.TempDir()
+	id1, id2 := 1, 2
+
+	dialogs := d.DByFile{
+		"bdnpc": {
+			{Kind: d.KindNPC, TraID: &id1, SpeakerDlg: "BDNPC", Dialog: "BDNPC", State: "hello"},
+			{Kind: d.KindPC, TraID: &id2, SpeakerDlg: "BDNPC", Dialog: "BDNPC", State: "hello", ToType: "EXIT"},
+		},
+	}
+	tras := tra.TraByFile{
+		"dialogs": tra.NewTra(map[string]string{"1": "Greetings."}),
+		"bdnpc":   tra.NewTra(map[string]string{"2": "Bye.", "3": "Unused"}),
+		"items":   tra.NewTra(map[string]string{"5": "Sword"}),
+	}
+	opts := Options{Tras: map[string][]string{"bdnpc": {"dialogs", "bdnpc"}}, Dialect: Dialects["excel-eu"], OutDir: tmp}
+
+	if _, err := ExportWithOptions(dialogs, tras, opts); err != nil {
+		t.Fatalf("ExportWithOptions: %v", err)
+	}
+
+	// the translator fills in the sheets
+	translate := func(name string, fill map[string][2]string) {
+		path := filepath.Join(tmp, name)
+		rows, err := ReadFile(path, Dialect{})
+		if err != nil {
+			t.Fatalf("ReadFile: %v", err)
+		}
+		for _, row := range rows[1:] {
+			if tr, ok := fill[row[colNPCStrref]]; ok {
+				row[colMaleNPC], row[colFemaleNPC] = tr[0], tr[1]
+			}
+			if tr, ok := fill[row[colPCStrref]]; ok {
+				row[colMalePC], row[colFemalePC] = tr[0], tr[1]
+			}
+		}
+		if err := WriteFile(path, rows, Dialects["excel-eu"]); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	translate("bdnpc.csv", map[string][2]string{
+		"@1": {"Witaj.", ""},
+		"@2": {"Żegnaj, panie.", "Żegnaj, pani."},
+		"@3": {"Nieużywany", ""},
+	})
+	translate("items.csv", map[string][2]string{"@5": {"Miecz", ""}})
+
+	got, err := Import(dialogs, tras, tmp, Options{Tras: opts.Tras})
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+
+	strip := func(m map[string]Translation) map[string]Translation {
+		for id, tr := range m {
+			tr.Pos.File, tr.Pos.Line = "", 0
+			m[id] = tr
+		}
+		return m
+	}
+	want := Translations{
+		"dialogs": {"1": {Male: "Witaj."}},
+		"bdnpc":   {"2": {Male: "Żegnaj, panie.", Female: "Żegnaj, pani."}, "3": {Male: "Nieużywany"}},
+		"items":   {"5": {Male: "Miecz"}},
+	}
+	if got["bdnpc"]["2"].Pos.String() != "bdnpc.csv:3" {
+		t.Fatalf("unexpected position: %v", got["bdnpc"]["2"].Pos)
+	}
+	for k := range got {
+		got[k] = strip(got[k])
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Import:\n got: %+v\nwant: %+v", got, want)
+	}
+
+	entries, missing := TraEntries(tras["bdnpc"], got["bdnpc"], false)
+	if len(missing) != 0 || len(entries) != 2 || entries[0].Female != "Żegnaj, pani." {
+		t.Fatalf("TraEntries: %+v, missing %v", entries, missing)
+	}
+}
+
+func TestImport_ConflictingTranslations(t *testing.T) {
+	tmp := t.TempDir()
+	content := strings.Join(header, ",") + "\n" +
+		",items,,@5,Sword,,,,TRA_ONLY,Miecz,,,\n" +
+		",items,,@5,Sword,,,,TRA_ONLY,Szabla,,,\n"
+	if err := os.WriteFile(filepath.Join(tmp, "items.csv"), []byte(content), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	tras := tra.TraByFile{"items": tra.NewTra(map[string]string{"5": "Sword"})}
+
+	_, err := Import(nil, tras, tmp, Options{})
+	if err == nil || !strings.Contains(err.Error(), "items.csv:3") {
+		t.Fatalf("expected conflict at items.csv:3, got %v", err)
+	}
+}
+
+func TestTranslations_DropFemaleOnly(t *testing.T) {
+	translations := Translations{
+		"bdnpc": {"1": {Male: "Gotowy?", Female: "Gotowa?"}, "2": {Female: "Gotowa."}},
+		"bdpc":  {"3": {Female: "Sama."}},
+	}
+	if n := translations.DropFemaleOnly(); n != 2 {
+		t.Fatalf("DropFemaleOnly = %d, want 2", n)
+	}
+	want := Translations{"bdnpc": {"1": {Male: "Gotowy?", Female: "Gotowa?"}}}
+	if !reflect.DeepEqual(translations, want) {
+		t.Fatalf("left %+v, want %+v", translations, want)
+	}
+}
+
+func TestTraEntries_Untranslated(t *testing.T) {
+	source := tra.NewTra(map[string]string{"1": "One", "2": "Two", "10": "Ten"})
+	translated := map[string]Translation{"2": {Male: "Dwa"}}
+
+	entries, missing := TraEntries(source, translated, false)
+	want := []tra.Entry{{ID: "1", Male: "One"}, {ID: "2", Male: "Dwa"}, {ID: "10", Male: "Ten"}}
+	if !reflect.DeepEqual(entries, want) || !reflect.DeepEqual(missing, []string{"1", "10"}) {
+		t.Fatalf("TraEntries: %+v, missing %v", entries, missing)
+	}
+
+	if entries, _ := TraEntries(source, translated, true); len(entries) != 1 {
+		t.Fatalf("expected only the translated entry, got %+v", entries)
+	}
+}
@@ -0,0 +1,55 @@
+package csv
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/tm"
+)
+
+// LoadMemory adds the translated rows of the exported CSV files in dir to
+// mem, e.g. the sheets of an earlier release or of another mod. Files
+// that weren't written by the exporter are skipped.
+func LoadMemory(mem *tm.Memory, dir string, dialect Dialect) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	var files []string
+	for _, ent := range entries {
+		if !ent.IsDir() && strings.EqualFold(filepath.Ext(ent.Name()), ".csv") {
+			files = append(files, ent.Name())
+		}
+	}
+	sort.Strings(files)
+
+	for _, name := range files {
+		rows, err := ReadFile(filepath.Join(dir, name), dialect)
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			continue
+		}
+		cols := columnsOf(rows[0])
+		if _, ok := cols[header[colNPCStrref]]; !ok {
+			continue
+		}
+
+		for _, row := range rows[1:] {
+			for _, c := range [][4]int{
+				{colNPCStrref, colNPCText, colMaleNPC, colFemaleNPC},
+				{colPCStrref, colPCText, colMalePC, colFemalePC},
+			} {
+				// vanilla strings aren't translated in the sheets
+				if strings.HasPrefix(strings.TrimSpace(cols.get(row, header[c[0]])), "#") {
+					continue
+				}
+				mem.Add(cols.get(row, header[c[1]]), cols.get(row, header[c[2]]), cols.get(row, header[c[3]]))
+			}
+		}
+	}
+	return nil
+}
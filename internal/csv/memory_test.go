@@ -0,0 +1,63 @@
+package csv
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/d"
+	"github.com/maciejjwojcik/dlg2csv/internal/tm"
+	"github.com/maciejjwojcik/dlg2csv/internal/tra"
+)
+
+func TestLoadMemory_PrefillsExport(t *testing.T) {
+	old, tmp := t.TempDir(), t.TempDir()
+
+	// translated sheets of an earlier release
+	rows := [][]string{
+		header,
+		padToHeaderLen([]string{"BDNPC", "BDNPC", "hello", "@1", "Farewell.", "", "", "", "", "Żegnaj.", "", "", ""}),
+		padToHeaderLen([]string{"", "BDNPC", "hello", "", "", "@2", "What do you want?", "EXIT", "", "", "Czego chcesz?", "", "Czego chcesz, pani?"}),
+		padToHeaderLen([]string{"BDNPC", "BDNPC", "bye", "#100", "Vanilla", "", "", "", "", "Waniliowy", "", "", ""}),
+	}
+	if err := WriteFile(filepath.Join(old, "bdnpc.csv"), rows, Dialect{}); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	mem := tm.New()
+	if err := LoadMemory(mem, old, Dialect{}); err != nil {
+		t.Fatalf("LoadMemory: %v", err)
+	}
+	if mem.Len() != 2 {
+		t.Fatalf("expected 2 strings in memory (vanilla rows skipped), got %d", mem.Len())
+	}
+
+	id1, id2 := 1, 2
+	dialogs := d.DByFile{
+		"bdnpc": {
+			{Kind: d.KindNPC, TraID: &id1, SpeakerDlg: "BDNPC", Dialog: "BDNPC", State: "hello"},
+			{Kind: d.KindPC, TraID: &id2, SpeakerDlg: "BDNPC", Dialog: "BDNPC", State: "hello", ToType: "EXIT"},
+		},
+	}
+	tras := tra.TraByFile{"bdnpc": tra.NewTra(map[string]string{"1": "Farewell.", "2": "What do you want now?"})}
+
+	if _, err := ExportWithOptions(dialogs, tras, Options{OutDir: tmp, Memory: mem}); err != nil {
+		t.Fatalf("ExportWithOptions: %v", err)
+	}
+	got, err := ReadFile(filepath.Join(tmp, "bdnpc.csv"), Dialect{})
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+
+	if !reflect.DeepEqual(got[0], append(append([]string(nil), header...), "Suggestion")) {
+		t.Fatalf("header: %q", got[0])
+	}
+	colSuggestion := len(got[0]) - 1
+	if got[1][colMaleNPC] != "Żegnaj." || got[1][colSuggestion] != "" {
+		t.Fatalf("exact match should prefill the row: %q", got[1])
+	}
+	want := `80% "What do you want?" -> "Czego chcesz?" / "Czego chcesz, pani?"`
+	if got[2][colMalePC] != "" || got[2][colSuggestion] != want {
+		t.Fatalf("fuzzy match: got %q, want suggestion %q", got[2], want)
+	}
+}
@@ -0,0 +1,92 @@
+package csv
+
+import "strings"
+
+// MergeStats counts what Merge did with the strings of the new export.
+type MergeStats struct {
+	Kept         int // same reference and source text
+	Moved        int // source text found under another reference
+	Changed      int // reference found, but its source text changed
+	Untranslated int // no translation to carry over
+}
+
+// Merge carries the translator columns of old, a translated export, over to
+// new, a fresh export of an updated mod, and returns the merged rows. A
+// translation is kept when the source text is unchanged, preferably under
+// the same @id; strings whose text changed are left for the translator.
+// Rows are matched by header names, so the files may have different
+// optional columns.
+func Merge(old, new [][]string) ([][]string, MergeStats) {
+	var st MergeStats
+	if len(old) == 0 || len(new) == 0 {
+		return new, st
+	}
+	oc, nc := columnsOf(old[0]), columnsOf(new[0])
+
+	type translation struct{ male, female string }
+	type side struct{ ref, text, male, female string }
+	sides := []side{
+		{header[colNPCStrref], header[colNPCText], header[colMaleNPC], header[colFemaleNPC]},
+		{header[colPCStrref], header[colPCText], header[colMalePC], header[colFemalePC]},
+	}
+
+	byRef := map[string]translation{}  // ref + text
+	byText := map[string]translation{} // text only
+	refText := map[string]string{}     // ref -> old text
+	for _, row := range old[1:] {
+		for _, s := range sides {
+			t := translation{oc.get(row, s.male), oc.get(row, s.female)}
+			ref, text := oc.get(row, s.ref), oc.get(row, s.text)
+			if ref == "" {
+				continue
+			}
+			refText[ref] = text
+			if t.male == "" && t.female == "" {
+				continue
+			}
+			byRef[ref+"\x00"+text] = t
+			if _, ok := byText[text]; !ok && strings.TrimSpace(text) != "" {
+				byText[text] = t
+			}
+		}
+	}
+
+	out := make([][]string, len(new))
+	out[0] = new[0]
+	for i, row := range new[1:] {
+		row = append([]string(nil), row...)
+		for len(row) < len(new[0]) {
+			row = append(row, "")
+		}
+		for _, s := range sides {
+			if _, ok := nc[s.male]; !ok {
+				continue
+			}
+			ref, text := nc.get(row, s.ref), nc.get(row, s.text)
+			if !strings.HasPrefix(ref, "@") || nc.get(row, s.male) != "" || nc.get(row, s.female) != "" {
+				continue
+			}
+			t, ok := byRef[ref+"\x00"+text]
+			switch {
+			case ok:
+				st.Kept++
+			case byText[text] != (translation{}):
+				t = byText[text]
+				st.Moved++
+			default:
+				if prev, seen := refText[ref]; seen && prev != text {
+					st.Changed++
+				} else {
+					st.Untranslated++
+				}
+				continue
+			}
+			row[nc[s.male]] = t.male
+			if i, ok := nc[s.female]; ok {
+				row[i] = t.female
+			}
+		}
+		out[i+1] = row
+	}
+	return out, st
+}
@@ -0,0 +1,63 @@
+package csv
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	row := func(npcRef, npcText, pcRef, pcText, maleNPC, malePC string) []string {
+		r := make([]string, len(header))
+		r[colNPCStrref], r[colNPCText] = npcRef, npcText
+		r[colPCStrref], r[colPCText] = pcRef, pcText
+		r[colMaleNPC], r[colMalePC] = maleNPC, malePC
+		return r
+	}
+
+	old := [][]string{
+		header,
+		row("@1", "Hello.", "", "", "Witaj.", ""),
+		row("", "", "@2", "Bye.", "", "Żegnaj."),
+		row("@3", "Old text.", "", "", "Stary tekst.", ""),
+		row("@4", "Not translated.", "", "", "", ""),
+	}
+	// a new release: @2 renumbered to @20, @3 reworded, @5 added
+	withSource := append(append([]string(nil), header...), "Source")
+	newRows := [][]string{
+		withSource,
+		row("@1", "Hello.", "", "", "", ""),
+		row("", "", "@20", "Bye.", "", ""),
+		row("@3", "New text.", "", "", "", ""),
+		row("@5", "Added.", "", "", "", ""),
+	}
+
+	got, st := Merge(old, newRows)
+
+	if got[1][colMaleNPC] != "Witaj." || got[2][colMalePC] != "Żegnaj." || got[3][colMaleNPC] != "" {
+		t.Fatalf("unexpected merge result: %q", got)
+	}
+	if len(got[1]) != len(withSource) {
+		t.Fatalf("rows should be padded to the new header, got %d fields", len(got[1]))
+	}
+	want := MergeStats{Kept: 1, Moved: 1, Changed: 1, Untranslated: 1}
+	if !reflect.DeepEqual(st, want) {
+		t.Fatalf("stats: got %+v, want %+v", st, want)
+	}
+	if newRows[1][colMaleNPC] != "" {
+		t.Fatalf("Merge must not modify its input")
+	}
+}
+
+func TestSniffComma(t *testing.T) {
+	tests := map[string]rune{
+		"Name,DialogID\n":      ',',
+		"Name;DialogID\r\n":    ';',
+		"\"Name\"\t\"Dialog\"": '\t',
+		"Something else":       ',',
+	}
+	for in, want := range tests {
+		if got := sniffComma([]byte(in)); got != want {
+			t.Fatalf("sniffComma(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package csv
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ReadFile reads a CSV file written by the exporter, possibly edited in a
+// spreadsheet since. A byte order mark is skipped; with a zero Delimiter
+// the separator is taken from the header row ("Name,..." or "Name;...").
+func ReadFile(path string, dialect Dialect) ([][]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comma = dialect.Delimiter
+	if r.Comma == 0 {
+		r.Comma = sniffComma(data)
+	}
+	r.FieldsPerRecord = -1
+
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rows, nil
+}
+
+// WriteFile writes rows to path in the given dialect.
+func WriteFile(path string, rows [][]string, dialect Dialect) error {
+	if err := dialect.Validate(); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := newRowWriter(f, dialect)
+	for _, row := range rows {
+		if err := w.Write(row); err != nil {
+			_ = f.Close()
+			return fmt.Errorf("write %s: %w", path, err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("flush %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+// sniffComma returns the character following the first header name, or
+// ',' if the header isn't recognised.
+func sniffComma(data []byte) rune {
+	first := strings.TrimPrefix(string(data), `"`)
+	if rest, ok := strings.CutPrefix(first, header[0]); ok {
+		rest = strings.TrimPrefix(rest, `"`)
+		for _, c := range rest {
+			if c != '\r' && c != '\n' {
+				return c
+			}
+			break
+		}
+	}
+	return ','
+}
+
+// columns maps header names to their index in a CSV file.
+type columns map[string]int
+
+func columnsOf(header []string) columns {
+	c := columns{}
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		if _, dup := c[name]; !dup {
+			c[name] = i
+		}
+	}
+	return c
+}
+
+// get returns the named field of row, or "" if the file has no such
+// column or the row is short.
+func (c columns) get(row []string, name string) string {
+	i, ok := c[name]
+	if !ok || i >= len(row) {
+		return ""
+	}
+	return row[i]
+}
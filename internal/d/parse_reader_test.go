@@ -1382,3 +1382,482 @@ END
 		t.Fatalf("expected occurrences, got 0")
 	}
 }
+
+func TestParseReader_RecordsSourcePositions(t *testing.T) {
+	input := `BEGIN AC#TEST
+
+IF ~~ THEN BEGIN A
+  SAY @1
+  IF ~Global("X","GLOBAL",1)
+      !Dead("Y")~ THEN REPLY @12 GOTO B
+END
+
+CHAIN AC#TEST B
+@2
+== JAHEIJ @3
+EXIT
+`
+	occ, err := ParseReader(strings.NewReader(input), "x.d")
+	if err != nil {
+		t.Fatalf("ParseReader error: %v", err)
+	}
+	if len(occ) != 4 {
+		t.Fatalf("expected 4 occurrences, got %d: %+v", len(occ), occ)
+	}
+
+	want := []struct {
+		line, col int
+	}{
+		{4, 7},  // SAY @1
+		{6, 30}, // REPLY @12 on the second line of a multiline condition
+		{10, 1}, // @2
+		{11, 11},
+	}
+	for i, w := range want {
+		p := occ[i].Pos
+		if p.File != "x.d" || p.Line != w.line || p.Col != w.col {
+			t.Fatalf("occ[%d] pos mismatch: got %s:%d:%d, want x.d:%d:%d", i, p.File, p.Line, p.Col, w.line, w.col)
+		}
+	}
+	if got := occ[1].Pos.String(); got != "x.d:6" {
+		t.Fatalf("Pos.String() = %q, want %q", got, "x.d:6")
+	}
+}
+
+func TestParseReader_InterjectCopyTrans_Variants(t *testing.T) {
+	tests := []struct {
+		header      string
+		wantKeyword string
+		wantCopy    bool
+	}{
+		{"INTERJECT_COPY_TRANS JAHEIJ 12 AC#JahICT", "INTERJECT_COPY_TRANS", true},
+		{"I_C_T JAHEIJ 12 AC#JahICT", "INTERJECT_COPY_TRANS", true},
+		{"I_C_T2 ~JAHEIJ~ 12 AC#JahICT", "INTERJECT_COPY_TRANS2", true},
+		{"i_c_t3 JAHEIJ 12 AC#JahICT", "INTERJECT_COPY_TRANS3", true},
+		{"I_C_T4 JAHEIJ 12 AC#JahICT", "INTERJECT_COPY_TRANS4", true},
+		{"INTERJECT_COPY_TRANS2 JAHEIJ 12 AC#JahICT", "INTERJECT_COPY_TRANS2", true},
+		{"INTERJECT_COPY_TRANS3 JAHEIJ 12 AC#JahICT", "INTERJECT_COPY_TRANS3", true},
+		{"INTERJECT_COPY_TRANS4 SAFE JAHEIJ 12 AC#JahICT", "INTERJECT_COPY_TRANS4", true},
+		{"INTERJECT JAHEIJ 12 AC#JahICT", "INTERJECT", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			input := tt.header + `
+== AC#NPCJ IF ~InParty("AC#NPC")~ THEN @1
+= @2
+== JAHEIJ @3
+END
+`
+			occ, err := ParseReader(strings.NewReader(input), "ict.d")
+			if err != nil {
+				t.Fatalf("ParseReader error: %v", err)
+			}
+			if len(occ) != 3 {
+				t.Fatalf("expected 3 occurrences, got %d: %+v", len(occ), occ)
+			}
+
+			wantSpeakers := []string{"AC#NPCJ", "AC#NPCJ", "JAHEIJ"}
+			for i, o := range occ {
+				if o.Kind != KindNPC || o.Dialog != "JAHEIJ" || o.State != "12" {
+					t.Fatalf("occ[%d] expected NPC in JAHEIJ/12, got: %+v", i, o)
+				}
+				if o.SpeakerDlg != wantSpeakers[i] {
+					t.Fatalf("occ[%d] speaker mismatch: got %q want %q", i, o.SpeakerDlg, wantSpeakers[i])
+				}
+				if o.Interject == nil {
+					t.Fatalf("occ[%d] expected Interject to be set", i)
+				}
+				if o.Interject.Keyword != tt.wantKeyword || o.Interject.CopyTrans != tt.wantCopy {
+					t.Fatalf("occ[%d] interjection mismatch: %+v", i, *o.Interject)
+				}
+				if o.Interject.Dlg != "JAHEIJ" || o.Interject.State != "12" || o.Interject.Var != "AC#JahICT" {
+					t.Fatalf("occ[%d] interjection target mismatch: %+v", i, *o.Interject)
+				}
+			}
+
+			// "= @2" continues the conditional AC#NPCJ segment
+			if occ[1].Condition != `InParty("AC#NPC")` {
+				t.Fatalf("occ[1] expected inherited condition, got %q", occ[1].Condition)
+			}
+			if occ[2].Condition != "" {
+				t.Fatalf("occ[2] expected no condition, got %q", occ[2].Condition)
+			}
+
+			last := occ[2]
+			if tt.wantCopy {
+				if last.ToType != "COPY_TRANS" || last.ToDlg == nil || *last.ToDlg != "JAHEIJ" || last.ToState == nil || *last.ToState != "12" {
+					t.Fatalf("expected COPY_TRANS JAHEIJ 12 on last line, got: %+v", last)
+				}
+			} else if last.ToType != "" {
+				t.Fatalf("plain INTERJECT should not copy transitions, got: %+v", last)
+			}
+		})
+	}
+}
+
+func TestParseReader_InterjectCopyTrans_DoesNotLeakIntoFollowingChain(t *testing.T) {
+	input := `
+I_C_T PLAYER1 33 AC#P1
+== AC#NPCJ @1
+END
+
+CHAIN AC#NPCJ AFTER
+@2
+EXIT
+`
+	occ, err := ParseReader(strings.NewReader(input), "ict.d")
+	if err != nil {
+		t.Fatalf("ParseReader error: %v", err)
+	}
+	if len(occ) != 2 {
+		t.Fatalf("expected 2 occurrences, got %d: %+v", len(occ), occ)
+	}
+	if occ[0].Interject == nil {
+		t.Fatalf("occ[0] expected Interject, got: %+v", occ[0])
+	}
+	if occ[1].Interject != nil || occ[1].SpeakerDlg != "AC#NPCJ" || occ[1].ToType != "EXIT" {
+		t.Fatalf("occ[1] expected plain CHAIN line, got: %+v", occ[1])
+	}
+}
+
+func TestParseReader_PatchActions(t *testing.T) {
+	input := `
+// fix vanilla typo
+REPLACE_SAY JAHEIJ 12 @1
+
+ALTER_TRANS PLAYER1
+BEGIN 33 34 END
+BEGIN 0 END
+BEGIN
+  "REPLY" ~@2~
+  "TRIGGER" ~Global("X","GLOBAL",1)~
+  "JOURNAL" ~@3~
+END
+
+ADD_TRANS_ACTION JAHEIJ BEGIN 12 END BEGIN END
+  ~SetGlobal("X","GLOBAL",1)~
+REPLACE_TRANS_TRIGGER JAHEIJ BEGIN 12 END BEGIN 0 END ~True()~ ~False()~
+REPLACE_STATE_TRIGGER JAHEIJ 12 ~False()~
+ADD_STATE_TRIGGER JAHEIJ 13 ~Global("X","GLOBAL",2)~
+ADD_TRANS_TRIGGER JAHEIJ 14 ~Global("X","GLOBAL",3)~ DO 0
+REPLACE_SAY ~JAHEIJ~ 15 ~Not translatable here.~
+
+BEGIN AC#TEST
+IF ~~ THEN BEGIN A
+  SAY @4
+END
+`
+	occ, err := ParseReader(strings.NewReader(input), "patch.d")
+	if err != nil {
+		t.Fatalf("ParseReader error: %v", err)
+	}
+
+	// REPLACE_SAY @1, ALTER_TRANS REPLY @2 x2 states, JOURNAL @3 x2 states, SAY @4
+	if len(occ) != 6 {
+		t.Fatalf("expected 6 occurrences, got %d: %+v", len(occ), occ)
+	}
+
+	say := occ[0]
+	if say.Kind != KindNPC || *say.TraID != 1 || say.Dialog != "JAHEIJ" || say.State != "12" || say.Patch != "REPLACE_SAY" {
+		t.Fatalf("REPLACE_SAY mismatch: %+v", say)
+	}
+
+	wantAlter := []struct {
+		kind  TextKind
+		id    int
+		state string
+	}{
+		{KindPC, 2, "33"},
+		{KindPC, 2, "34"},
+		{KindJournal, 3, "33"},
+		{KindJournal, 3, "34"},
+	}
+	for i, w := range wantAlter {
+		o := occ[1+i]
+		if o.Kind != w.kind || *o.TraID != w.id || o.Dialog != "PLAYER1" || o.State != w.state || o.Patch != "ALTER_TRANS" {
+			t.Fatalf("occ[%d] ALTER_TRANS mismatch: %+v", 1+i, o)
+		}
+		if o.ReplyIndex == nil || *o.ReplyIndex != 0 {
+			t.Fatalf("occ[%d] expected ReplyIndex=0, got: %+v", 1+i, o)
+		}
+	}
+
+	last := occ[5]
+	if *last.TraID != 4 || last.Dialog != "AC#TEST" || last.State != "A" || last.Patch != "" {
+		t.Fatalf("expected regular SAY after patches, got: %+v", last)
+	}
+}
+
+func TestParseReader_Err_UnterminatedPatchAction(t *testing.T) {
+	input := `
+ALTER_TRANS PLAYER1 BEGIN 33 END BEGIN 0 END BEGIN
+  "REPLY" ~@2~
+`
+	_, err := ParseReader(strings.NewReader(input), "x.d")
+	if err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+	if !strings.Contains(err.Error(), "unterminated ALTER_TRANS") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestParseReader_ReplaceAndAppendEarlyBlocks(t *testing.T) {
+	input := `
+REPLACE ~JAHEIJ~
+IF ~~ THEN BEGIN 12
+  SAY @1
+  IF ~~ THEN REPLY @2 GOTO 13
+END
+END
+
+APPEND_EARLY IMOEN2J
+IF ~~ THEN BEGIN AC#Early
+  SAY @3
+END
+END
+
+APPEND IF_FILE_EXISTS AC#NPC
+IF ~~ THEN BEGIN AC#Late
+  SAY @4
+END
+END
+`
+	occ, err := ParseReader(strings.NewReader(input), "blocks.d")
+	if err != nil {
+		t.Fatalf("ParseReader error: %v", err)
+	}
+	if len(occ) != 4 {
+		t.Fatalf("expected 4 occurrences, got %d: %+v", len(occ), occ)
+	}
+
+	want := []struct {
+		dlg, state, block string
+	}{
+		{"JAHEIJ", "12", "REPLACE"},
+		{"JAHEIJ", "12", "REPLACE"},
+		{"IMOEN2J", "AC#Early", "APPEND_EARLY"},
+		{"AC#NPC", "AC#Late", "APPEND"},
+	}
+	for i, w := range want {
+		o := occ[i]
+		if o.Dialog != w.dlg || o.State != w.state || o.Block != w.block {
+			t.Fatalf("occ[%d] mismatch: got %s/%s block=%q, want %s/%s block=%q", i, o.Dialog, o.State, o.Block, w.dlg, w.state, w.block)
+		}
+	}
+}
+
+func TestParseReader_ExtendRecordsStatesAndPosition(t *testing.T) {
+	input := `EXTEND_BOTTOM FATESP 6 7 #4
+  IF ~~ THEN REPLY @0 GOTO 8
+END
+
+EXTEND_TOP ~PLAYER1~ 33 # 1
+  IF ~~ THEN REPLY @1 EXIT
+END
+
+BEGIN AC#TEST
+IF ~~ THEN BEGIN A
+  SAY @2
+END
+`
+	occ, err := ParseReader(strings.NewReader(input), "extend.d")
+	if err != nil {
+		t.Fatalf("ParseReader error: %v", err)
+	}
+	if len(occ) != 3 {
+		t.Fatalf("expected 3 occurrences, got %d: %+v", len(occ), occ)
+	}
+
+	o := occ[0]
+	if o.Dialog != "FATESP" || o.State != "6" || o.Block != "EXTEND_BOTTOM" || o.Extend == nil {
+		t.Fatalf("occ[0] mismatch: %+v", o)
+	}
+	if !equalStringSlices(o.Extend.States, []string{"6", "7"}) || o.Extend.Position == nil || *o.Extend.Position != 4 {
+		t.Fatalf("occ[0] extension mismatch: %+v", *o.Extend)
+	}
+
+	o = occ[1]
+	if o.Dialog != "PLAYER1" || o.State != "33" || o.Block != "EXTEND_TOP" || o.Extend == nil {
+		t.Fatalf("occ[1] mismatch: %+v", o)
+	}
+	if o.Extend.Position == nil || *o.Extend.Position != 1 {
+		t.Fatalf("occ[1] expected position 1, got: %+v", *o.Extend)
+	}
+
+	if occ[2].Block != "" || occ[2].Extend != nil {
+		t.Fatalf("occ[2] should not inherit extend context: %+v", occ[2])
+	}
+}
+
+func TestParseReader_ChainBranchAndDoActions(t *testing.T) {
+	input := `
+BEGIN AC#NPC
+
+CHAIN IF ~Global("AC#Talk","GLOBAL",1)~ THEN AC#NPC TALK
+@1 DO ~SetGlobal("AC#Talk","GLOBAL",2)~
+== JAHEIJ IF ~InParty("JAHEIRA")~ THEN @2 DO ~SetGlobal("AC#Jah","GLOBAL",1)~
+BRANCH ~InParty("IMOEN2")~ BEGIN
+  == IMOEN2J @3
+  = @4 DO ~SetGlobal("AC#Imo","GLOBAL",1)~
+  BRANCH ~Gender(Player1,FEMALE)~
+  BEGIN
+    == IMOEN2J @5
+  END
+END
+== AC#NPC @6
+COPY_TRANS JAHEIJ 12
+
+CHAIN AC#NPC NEXT
+@7
+END AC#NPC TALK
+`
+	occ, err := ParseReader(strings.NewReader(input), "branch.d")
+	if err != nil {
+		t.Fatalf("ParseReader error: %v", err)
+	}
+	if len(occ) != 7 {
+		t.Fatalf("expected 7 occurrences, got %d: %+v", len(occ), occ)
+	}
+
+	want := []struct {
+		id      int
+		speaker string
+		branch  string
+	}{
+		{1, "AC#NPC", ""},
+		{2, "JAHEIJ", ""},
+		{3, "IMOEN2J", `InParty("IMOEN2")`},
+		{4, "IMOEN2J", `InParty("IMOEN2")`},
+		{5, "IMOEN2J", `InParty("IMOEN2") Gender(Player1,FEMALE)`},
+		{6, "AC#NPC", ""},
+		{7, "AC#NPC", ""},
+	}
+	for i, w := range want {
+		o := occ[i]
+		if o.TraID == nil || *o.TraID != w.id || o.SpeakerDlg != w.speaker || o.Branch != w.branch {
+			t.Fatalf("occ[%d] mismatch: got @%v speaker=%q branch=%q, want @%d speaker=%q branch=%q",
+				i, o.TraID, o.SpeakerDlg, o.Branch, w.id, w.speaker, w.branch)
+		}
+	}
+
+	if occ[1].Condition != `InParty("JAHEIRA")` {
+		t.Fatalf("occ[1] condition mismatch: %q", occ[1].Condition)
+	}
+
+	last := occ[5]
+	if last.ToType != "COPY_TRANS" || last.ToDlg == nil || *last.ToDlg != "JAHEIJ" || last.ToState == nil || *last.ToState != "12" {
+		t.Fatalf("expected COPY_TRANS JAHEIJ 12, got: %+v", last)
+	}
+
+	next := occ[6]
+	if next.State != "NEXT" || next.ToType != "EXTERN" || next.ToDlg == nil || *next.ToDlg != "AC#NPC" || *next.ToState != "TALK" {
+		t.Fatalf("expected END AC#NPC TALK to act as EXTERN, got: %+v", next)
+	}
+}
+
+func TestParseReader_ChainBranchOneLine(t *testing.T) {
+	input := `
+BEGIN AC#NPC
+
+CHAIN AC#NPC TALK
+@1
+BRANCH ~InParty("IMOEN2")~ BEGIN == IMOEN2J @2 END
+BRANCH ~InParty("JAHEIRA")~ BEGIN == JAHEIJ @3 DO ~SetGlobal("AC#Jah","GLOBAL",1)~ END
+BRANCH ~InParty("MINSC")~ BEGIN == MINSCJ @4
+END
+== AC#NPC @5
+EXIT
+`
+	occ, err := ParseReader(strings.NewReader(input), "branch.d")
+	if err != nil {
+		t.Fatalf("ParseReader error: %v", err)
+	}
+	want := []struct {
+		id      int
+		speaker string
+		branch  string
+		line    int
+	}{
+		{1, "AC#NPC", "", 5},
+		{2, "IMOEN2J", `InParty("IMOEN2")`, 6},
+		{3, "JAHEIJ", `InParty("JAHEIRA")`, 7},
+		{4, "MINSCJ", `InParty("MINSC")`, 8},
+		{5, "AC#NPC", "", 10},
+	}
+	if len(occ) != len(want) {
+		t.Fatalf("expected %d occurrences, got %d: %+v", len(want), len(occ), occ)
+	}
+	for i, w := range want {
+		o := occ[i]
+		if o.TraID == nil || *o.TraID != w.id || o.SpeakerDlg != w.speaker || o.Branch != w.branch || o.Pos.Line != w.line {
+			t.Fatalf("occ[%d] mismatch: got @%v speaker=%q branch=%q line %d, want @%d speaker=%q branch=%q line %d",
+				i, o.TraID, o.SpeakerDlg, o.Branch, o.Pos.Line, w.id, w.speaker, w.branch, w.line)
+		}
+	}
+}
+
+func TestParseReader_ChainBranchDoesNotLeak(t *testing.T) {
+	// a BRANCH missing its BEGIN must not open a branch in the next block
+	input := `
+BEGIN AC#NPC
+
+CHAIN AC#NPC TALK
+@1
+BRANCH ~InParty("IMOEN2")~
+END AC#NPC NEXT
+
+CHAIN AC#NPC NEXT
+@2
+BEGIN
+== IMOEN2J @3
+EXIT
+`
+	occ, err := ParseReader(strings.NewReader(input), "branch.d")
+	if err != nil {
+		t.Fatalf("ParseReader error: %v", err)
+	}
+	if len(occ) != 3 {
+		t.Fatalf("expected 3 occurrences, got %d: %+v", len(occ), occ)
+	}
+	for i, o := range occ {
+		if o.Branch != "" {
+			t.Fatalf("occ[%d] has branch %q from the previous block", i, o.Branch)
+		}
+	}
+}
+
+func TestParseReader_StrrefReferences(t *testing.T) {
+	input := `BEGIN AC#NPC
+IF ~~ THEN BEGIN 0
+  SAY #12345
+  IF ~~ THEN REPLY #678 EXIT
+  IF ~~ THEN REPLY @1 EXIT
+END
+`
+	occ, err := ParseReader(strings.NewReader(input), "strref.d")
+	if err != nil {
+		t.Fatalf("ParseReader error: %v", err)
+	}
+	if len(occ) != 3 {
+		t.Fatalf("expected 3 occurrences, got %d: %+v", len(occ), occ)
+	}
+
+	say := occ[0]
+	if say.Ref() != RefStrref || say.TraID != nil || say.StrRef == nil || *say.StrRef != 12345 {
+		t.Fatalf("SAY #12345 mismatch: ref=%s traID=%v strref=%v", say.Ref(), say.TraID, say.StrRef)
+	}
+	if say.Pos.Line != 3 || say.Pos.Col != 7 {
+		t.Fatalf("SAY pos: got %d:%d, want 3:7", say.Pos.Line, say.Pos.Col)
+	}
+
+	reply := occ[1]
+	if reply.Ref() != RefStrref || reply.StrRef == nil || *reply.StrRef != 678 || reply.ToType != "EXIT" {
+		t.Fatalf("REPLY #678 mismatch: %+v", reply)
+	}
+
+	if occ[2].Ref() != RefTra || occ[2].TraID == nil || *occ[2].TraID != 1 || occ[2].StrRef != nil {
+		t.Fatalf("REPLY @1 mismatch: %+v", occ[2])
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -14,6 +15,9 @@ import (
 	helpers "github.com/maciejjwojcik/dlg2csv/internal/utils"
 )
 
+// optional action after a CHAIN text: DO ~...~
+const reDoSuffix = `(?:\s+DO\s*~[\s\S]*~)?`
+
 var (
 	// IF ~cond~ THEN BEGIN STATE
 	// IF [WEIGHT #n] ~cond~ THEN BEGIN <state>
@@ -30,16 +34,19 @@ var (
 		`(?is)^\s*IF(?:\s+WEIGHT\s*#-?\d+)?\s*~([\s\S]*?)~\s*(?:THEN\s*)?BEGIN\s+([A-Za-z0-9_#.\-]+)\s*$`,
 	)
 
-	// SAY @123
-	reSay = regexp.MustCompile(`(?i)^\s*SAY\s+@(\d+)\s*$`)
+	// SAY @123 (TRA reference) or SAY #12345 (game strref)
+	// m[1] = "@" or "#", m[2] = number
+	reSay = regexp.MustCompile(`(?i)^\s*SAY\s+([@#])(\d+)\s*$`)
 
 	// IF ~cond~ THEN REPLY @123 <rest>
+	// IF ~cond~ THEN REPLY #678 <rest>
 	// Matches PC reply lines inside states/extends.
 	// - Supports both explicit conditions (~ ... ~) and empty condition (~~).
 	// - <rest> may include DO ~...~ blocks and can span multiple lines.
 	// - Uses [\s\S] instead of '.' because Go's regexp does not enable DOTALL by default.
+	// m[1] = condition, m[2] = "@" or "#", m[3] = number, m[4] = rest
 	reReply = regexp.MustCompile(
-		`(?i)^\s*IF\s*(~[\s\S]*?~|~~)\s*THEN\s*REPLY\s+@(\d+)\s*([\s\S]*)$`,
+		`(?i)^\s*IF\s*(~[\s\S]*?~|~~)\s*THEN\s*REPLY\s+([@#])(\d+)\s*([\s\S]*)$`,
 	)
 
 	// Targets inside reply "rest"
@@ -85,37 +92,60 @@ var (
 		`(?i)~\s*THEN\s+([A-Za-z0-9_#.\-]+)\s+([A-Za-z0-9_#.\-]+)\s*$`,
 	)
 
-	// NPC line inside CHAIN body: @200
+	// NPC line inside CHAIN body: @200, optionally with an action: @200 DO ~...~
 	// Capturing groups: m[1] = tra id
-	reChainLine = regexp.MustCompile(`^\s*@(\d+)\s*$`)
+	reChainLine = regexp.MustCompile(`(?i)^\s*@(\d+)` + reDoSuffix + `\s*$`)
 
 	// "= @id" continuation line in one go (common WeiDU formatting)
-	reEqChainLine = regexp.MustCompile(`^\s*=\s*@(\d+)\s*$`)
+	reEqChainLine = regexp.MustCompile(`(?i)^\s*=\s*@(\d+)` + reDoSuffix + `\s*$`)
 
 	// Interjection with IF:
 	//   ==JAHEIJ IF ~InParty("JAHEIRA")~ THEN @201
+	//   ==JAHEIJ IF ~InParty("JAHEIRA")~ THEN @201 DO ~SetGlobal("X","GLOBAL",1)~
 	// Groups:
 	//   m[1] = speaker dialog (JAHEIJ)
 	//   m[2] = condition inside ~ ~ (InParty("JAHEIRA"))
 	//   m[3] = tra id (201)
 	reInterjectIf = regexp.MustCompile(
-		`(?i)^\s*==\s*([A-Za-z0-9_#.\-]+)\s+IF\s*~(.*?)~\s*THEN\s+@(\d+)\s*$`,
+		`(?i)^\s*==\s*([A-Za-z0-9_#.\-]+)\s+IF\s*~([\s\S]*?)~\s*THEN\s+@(\d+)` + reDoSuffix + `\s*$`,
 	)
 
 	// Interjection without IF:
 	//   ==AC#WOMAN @204
+	//   ==AC#WOMAN @204 DO ~SetGlobal("X","GLOBAL",1)~
 	// Groups:
 	//   m[1] = speaker dialog
 	//   m[2] = tra id
 	reInterject = regexp.MustCompile(
-		`(?i)^\s*==\s*([A-Za-z0-9_#.\-]+)\s+@(\d+)\s*$`,
+		`(?i)^\s*==\s*([A-Za-z0-9_#.\-]+)\s+@(\d+)` + reDoSuffix + `\s*$`,
+	)
+
+	// BRANCH inside CHAIN body:
+	//   BRANCH ~InParty("JAHEIRA")~ BEGIN
+	//   BRANCH ~InParty("JAHEIRA")~        (BEGIN on the next line)
+	//   BRANCH ~InParty("JAHEIRA")~ BEGIN == JAHEIJ @1 END
+	// m[1] = trigger, m[2] = "BEGIN" if present, m[3] = the rest of the line
+	reBranch = regexp.MustCompile(`(?i)^\s*BRANCH\s*~([\s\S]*?)~\s*(?:(BEGIN)\b\s*([\s\S]*?))?\s*$`)
+
+	// END closing a one-line BRANCH: m[1] = the lines before it
+	reBranchEnd = regexp.MustCompile(`(?i)^(?:([\s\S]*?)\s+)?END$`)
+
+	// CHAIN epilogues transferring control to another state:
+	//   COPY_TRANS JAHEIJ 12
+	//   COPY_TRANS_LATE SAFE JAHEIJ 12
+	//   END JAHEIJ 12          (same as EXTERN JAHEIJ 12)
+	// m[1] = keyword, m[2] = dlg, m[3] = state
+	reChainEpilogue = regexp.MustCompile(
+		`(?i)^\s*(COPY_TRANS(?:_LATE)?|END)(?:\s+SAFE)?\s+~?([A-Za-z0-9_#.\-]+)~?\s+([A-Za-z0-9_#.\-]+)\s*$`,
 	)
 
 	// EXTEND_BOTTOM ~PGOND~ 0
 	// EXTEND_TOP ~SOMEDLG~ some_state
-	// m[1] = dlg, m[2] = state
+	// EXTEND_BOTTOM FATESP 6 7 #4
+	// m[1] = keyword, m[2] = dlg (with tildes), m[3] = dlg (plain),
+	// m[4] = state list, optionally followed by #position
 	reExtend = regexp.MustCompile(
-		`(?i)^\s*EXTEND_(?:BOTTOM|TOP)\s+(?:~([^~]+)~|([A-Za-z0-9_#.\-]+))\s+([A-Za-z0-9_#.\-]+(?:\s+[A-Za-z0-9_#.\-]+)*)(?:\s+#\s*(\d+))?\s*$`,
+		`(?i)^\s*(EXTEND_(?:BOTTOM|TOP))\s+(?:~([^~]+)~|([A-Za-z0-9_#.\-]+))\s+([A-Za-z0-9_#.\-]+(?:\s+[A-Za-z0-9_#.\-]+)*)\s*$`,
 	)
 
 	// BEGIN SOMEDLG
@@ -127,17 +157,22 @@ var (
 
 	// APPEND header: APPEND <dialog>
 	//
-	// Example matched:
+	// Examples matched:
 	//   APPEND WSMITH01
+	//   APPEND_EARLY ~WSMITH01~
+	//   REPLACE IF_FILE_EXISTS WSMITH01
 	//
 	// Semantics:
 	//   Opens an append context for an existing dialog (no BEGIN required).
 	//   Subsequent IF ... THEN BEGIN <state> blocks define new states
 	//   inside this dialog until the matching END.
+	//   APPEND_EARLY does the same before CHAINs are compiled; REPLACE
+	//   replaces the existing states with the given (usually numeric) labels.
 	//
 	// Capturing groups:
-	//   m[1] = dialog (e.g. "WSMITH01")
-	reAppendHeader = regexp.MustCompile(`(?i)^\s*APPEND\s+(?:~([^~]+)~|([A-Za-z0-9_#.\-]+))\s*$`)
+	//   m[1] = keyword (APPEND, APPEND_EARLY, REPLACE)
+	//   m[2] = dialog with tildes, m[3] = dialog without (e.g. "WSMITH01")
+	reAppendHeader = regexp.MustCompile(`(?i)^\s*(APPEND|APPEND_EARLY|REPLACE)(?:\s+IF_FILE_EXISTS)?\s+(?:~([^~]+)~|([A-Za-z0-9_#.\-]+))\s*$`)
 
 	// IF <trigger> <state>  (short state header, without THEN BEGIN)
 	//
@@ -159,9 +194,25 @@ var (
 	reExitOnly   = regexp.MustCompile(`(?i)^\s*EXIT\s*$`)
 	reExternOnly = regexp.MustCompile(`(?i)^\s*EXTERN\s+(\S+)\s+(\S+)\s*$`)
 
-	// m[1]=dlg (PLAYER1), m[2]=state (33), m[3]=interjectLabel (L#2E)
+	// INTERJECT family header:
+	//   INTERJECT PLAYER1 33 L#2E
+	//   INTERJECT_COPY_TRANS ~JAHEIJ~ 12 AC#JaheiraICT
+	//   I_C_T2 SAFE PLAYER1 33 L#2E
+	//
+	// Accepted keywords: INTERJECT, INTERJECT_COPY_TRANS[2-4], I_C_T[2-4],
+	// optionally followed by SAFE / IF_FILE_EXISTS.
+	//
+	// m[1]=keyword, m[2]=dlg (PLAYER1), m[3]=state (33), m[4]=interjectLabel (L#2E)
 	reInterjectHeader = regexp.MustCompile(
-		`(?i)^\s*INTERJECT\s+([A-Za-z0-9_#.\-]+)\s+([A-Za-z0-9_#.\-]+)\s+([A-Za-z0-9_#.\-]+)\s*$`,
+		`(?i)^\s*(INTERJECT(?:_COPY_TRANS[234]?)?|I_C_T[234]?)(?:\s+(?:SAFE|IF_FILE_EXISTS))*\s+~?([A-Za-z0-9_#.\-]+)~?\s+([A-Za-z0-9_#.\-]+)\s+([A-Za-z0-9_#.\-]+)\s*$`,
+	)
+
+	// Speaker switch inside CHAIN/INTERJECT body with the text on the next line:
+	//   == JAHEIJ IF ~InParty("JAHEIRA")~ THEN
+	//   == JAHEIJ
+	// m[1] = speaker dialog, m[2] = condition (may be empty)
+	reInterjectSpeaker = regexp.MustCompile(
+		`(?i)^\s*==\s*~?([A-Za-z0-9_#.\-]+)~?(?:\s+IF\s*~([\s\S]*?)~(?:\s*THEN)?)?\s*$`,
 	)
 )
 
@@ -176,15 +227,52 @@ const (
 type TextKind string
 
 const (
-	KindNPC TextKind = "NPC"
-	KindPC  TextKind = "PC"
+	KindNPC     TextKind = "NPC"
+	KindPC      TextKind = "PC"
+	KindJournal TextKind = "JOURNAL"
+)
+
+// RefKind tells where the text of an occurrence comes from.
+type RefKind string
+
+const (
+	RefTra    RefKind = "TRA"    // @id, resolved from the mod's .tra file
+	RefStrref RefKind = "STRREF" // #strref, resolved from the game's dialog.tlk
 )
 
 type DByFile map[string][]TextOccurrence
 
+// Extension describes the target of an EXTEND_TOP / EXTEND_BOTTOM block.
+type Extension struct {
+	States   []string // all extended states; TextOccurrence.State holds the first
+	Position *int     // #position the transitions are inserted at, nil if not given
+}
+
+// Interjection describes the INTERJECT-family block an occurrence comes from.
+//
+// Semantics of the copy-trans variants (see WeiDU docs):
+//   - INTERJECT_COPY_TRANS: the target state's transitions are copied
+//     to the end of the interjection,
+//   - INTERJECT_COPY_TRANS2: as above, but actions of the copied
+//     transitions run in the interjection, not in the target state,
+//   - INTERJECT_COPY_TRANS3: each speaker block becomes a separate state,
+//     so every NPC whose condition is true gets to speak,
+//   - INTERJECT_COPY_TRANS4: combination of 2 and 3.
+type Interjection struct {
+	Keyword   string // normalized header keyword, e.g. "INTERJECT_COPY_TRANS2"
+	Dlg       string // target dialog
+	State     string // target state
+	Var       string // global variable guarding the interjection
+	CopyTrans bool   // transitions of the target state are copied after the block
+}
+
 type TextOccurrence struct {
 	TraID *int
 
+	// StrRef is set instead of TraID for vanilla strings referenced as
+	// #12345; these come from the game's dialog.tlk and need no translation.
+	StrRef *int
+
 	Kind       TextKind
 	SpeakerDlg string
 
@@ -200,9 +288,56 @@ type TextOccurrence struct {
 	Condition string // IF ~...~
 
 	Notes []string
+
+	// Pos is where the @id of this occurrence appears in the .d file.
+	Pos helpers.Pos
+
+	// Interject is set for lines inside INTERJECT / INTERJECT_COPY_TRANS* bodies.
+	Interject *Interjection
+
+	// Block is the enclosing APPEND, APPEND_EARLY, REPLACE, EXTEND_TOP or
+	// EXTEND_BOTTOM block; empty inside BEGIN, CHAIN and INTERJECT blocks.
+	Block string
+
+	// Extend is set for transitions added by EXTEND_TOP / EXTEND_BOTTOM.
+	Extend *Extension
+
+	// Branch holds the triggers of the BRANCH blocks enclosing a CHAIN line.
+	Branch string
+
+	// Patch is the patch action that introduced the string, e.g.
+	// "REPLACE_SAY" or "ALTER_TRANS"; empty for regular dialogue blocks.
+	Patch string
+}
+
+// Ref reports whether the occurrence references a .tra entry or a game strref.
+func (o TextOccurrence) Ref() RefKind {
+	if o.StrRef != nil {
+		return RefStrref
+	}
+	return RefTra
+}
+
+// Options controls parsing.
+type Options struct {
+	// Logger receives diagnostics, e.g. the parser state at a syntax
+	// error at debug level. Nil discards them.
+	Logger *slog.Logger
+}
+
+func (o Options) logger() *slog.Logger {
+	if o.Logger == nil {
+		return slog.New(slog.DiscardHandler)
+	}
+	return o.Logger
 }
 
 func ParseDir(dir string) (DByFile, error) {
+	return ParseDirWithOptions(dir, Options{})
+}
+
+// ParseDirWithOptions is ParseDir with options.
+func ParseDirWithOptions(dir string, opts Options) (DByFile, error) {
 	entries, err := os.ReadDir(dir)
 	if err != nil {
 		return nil, err
@@ -223,7 +358,7 @@ func ParseDir(dir string) (DByFile, error) {
 	out := make(DByFile, len(files))
 	for _, name := range files {
 		full := filepath.Join(dir, name)
-		m, err := ParseFile(full)
+		m, err := ParseFileWithOptions(full, opts)
 		if err != nil {
 			return nil, err
 		}
@@ -233,7 +368,38 @@ func ParseDir(dir string) (DByFile, error) {
 	return out, nil
 }
 
+// ParseFiles parses the given .d files, keyed like ParseDir by lower-case
+// base name. Two files with the same key are an error.
+func ParseFiles(paths []string) (DByFile, error) {
+	return ParseFilesWithOptions(paths, Options{})
+}
+
+// ParseFilesWithOptions is ParseFiles with options.
+func ParseFilesWithOptions(paths []string, opts Options) (DByFile, error) {
+	out := make(DByFile, len(paths))
+	seen := make(map[string]string, len(paths)) // key -> path
+	for _, path := range paths {
+		k := strings.ToLower(helpers.BaseKey(filepath.Base(path)))
+		if first, ok := seen[k]; ok {
+			return nil, fmt.Errorf("%s and %s have the same name, %s", first, path, k)
+		}
+		seen[k] = path
+
+		m, err := ParseFileWithOptions(path, opts)
+		if err != nil {
+			return nil, err
+		}
+		out[k] = m
+	}
+	return out, nil
+}
+
 func ParseFile(path string) ([]TextOccurrence, error) {
+	return ParseFileWithOptions(path, Options{})
+}
+
+// ParseFileWithOptions is ParseFile with options.
+func ParseFileWithOptions(path string, opts Options) ([]TextOccurrence, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
@@ -244,10 +410,16 @@ func ParseFile(path string) ([]TextOccurrence, error) {
 		}
 	}()
 
-	return ParseReader(f, filepath.Base(path))
+	return ParseReaderWithOptions(f, filepath.Base(path), opts)
 }
 
 func ParseReader(r io.Reader, fileName string) ([]TextOccurrence, error) {
+	return ParseReaderWithOptions(r, fileName, Options{})
+}
+
+// ParseReaderWithOptions is ParseReader with options.
+func ParseReaderWithOptions(r io.Reader, fileName string, opts Options) ([]TextOccurrence, error) {
+	log := opts.logger()
 	sc := bufio.NewScanner(r)
 	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
 
@@ -260,6 +432,8 @@ func ParseReader(r io.Reader, fileName string) ([]TextOccurrence, error) {
 		replyIndex         int
 		inState            bool
 		inExtend           bool
+		block              string
+		extend             *Extension
 		pendingNotes       []string
 		stateEntryCond     string
 		pendingChainHeader string
@@ -268,14 +442,45 @@ func ParseReader(r io.Reader, fileName string) ([]TextOccurrence, error) {
 		mode = modeNormal
 	)
 	lastChainTextIdx := -1
-	inInterject := false
+
+	// INTERJECT* block being parsed (nil inside plain CHAIN) and the
+	// condition of the current "== speaker IF ~...~ THEN" segment
+	var (
+		interject *Interjection
+		chainCond string
+
+		// open BRANCH ~trigger~ BEGIN ... END blocks inside CHAIN
+		branches      []string
+		pendingBranch string
+	)
 
 	splitter := &CommentSplitter{}
 
+	// raw lines of the statement being parsed, used to locate @ids
+	var (
+		stmtStart int
+		stmtRaw   []string
+	)
+	pos := func(id int) helpers.Pos {
+		return locateTraRef(fileName, stmtStart, stmtRaw, id)
+	}
+	refPos := func(ref string) helpers.Pos {
+		return locateRef(fileName, stmtStart, stmtRaw, ref)
+	}
+
+	// statements split off the current line, parsed as lines of their own
+	var queued []string
+
 	lineNo := 0
-	for sc.Scan() {
-		lineNo++
-		raw := sc.Text()
+	for len(queued) > 0 || sc.Scan() {
+		var raw string
+		if len(queued) > 0 {
+			raw, queued = queued[0], queued[1:]
+		} else {
+			lineNo++
+			raw = sc.Text()
+			stmtStart, stmtRaw = lineNo, []string{raw}
+		}
 
 		switch mode {
 		case modeNormal:
@@ -314,6 +519,7 @@ func ParseReader(r io.Reader, fileName string) ([]TextOccurrence, error) {
 				lineNo++ // ważne dla diagnostyki
 
 				raw2 := sc.Text()
+				stmtRaw = append(stmtRaw, raw2)
 				line2, comment2 := splitter.Split(raw2)
 
 				// komentarze z kolejnych linii też zachowaj (tak jak inline commenty)
@@ -346,6 +552,36 @@ func ParseReader(r io.Reader, fileName string) ([]TextOccurrence, error) {
 				continue
 			}
 
+			// Dialogue patch actions: REPLACE_SAY, ALTER_TRANS, ADD_TRANS_ACTION, ...
+			// They may span several lines (BEGIN ... END lists), so read until
+			// all required arguments are present.
+			if kw := patchKeyword(line); kw != "" {
+				pa, complete := parsePatchAction(line)
+				for !complete {
+					if !sc.Scan() {
+						return nil, fmt.Errorf("%s:%d: unterminated %s", fileName, lineNo, kw)
+					}
+					lineNo++
+
+					raw2 := sc.Text()
+					stmtRaw = append(stmtRaw, raw2)
+					line2, comment2 := splitter.Split(raw2)
+					if comment2 != "" {
+						pendingNotes = append(pendingNotes, comment2)
+					}
+
+					line += "\n" + line2
+					pa, complete = parsePatchAction(line)
+				}
+
+				if occ := pa.occurrences(pos); len(occ) > 0 {
+					occ[0].Notes = pendingNotes
+					pendingNotes = nil
+					out = append(out, occ...)
+				}
+				continue
+			}
+
 			// BEGIN <dialog>
 			if mm := reBeginDlg.FindStringSubmatch(line); mm != nil {
 				currentDialog = mm[1]
@@ -353,6 +589,8 @@ func ParseReader(r io.Reader, fileName string) ([]TextOccurrence, error) {
 				currentState = ""
 				inState = false
 				inExtend = false
+				block = ""
+				extend = nil
 				replyIndex = 0
 				stateEntryCond = ""
 
@@ -362,22 +600,27 @@ func ParseReader(r io.Reader, fileName string) ([]TextOccurrence, error) {
 
 			// EXTEND
 			if mm := reExtend.FindStringSubmatch(line); mm != nil {
-				dlg := strings.TrimSpace(mm[1])
+				dlg := strings.TrimSpace(mm[2])
 				if dlg == "" {
-					dlg = strings.TrimSpace(mm[2]) // no tilde
+					dlg = strings.TrimSpace(mm[3]) // no tilde
 				}
 
-				states := strings.Fields(strings.TrimSpace(mm[3]))
-				if len(states) == 0 {
+				ext, err := parseExtendTargets(mm[4])
+				if err != nil {
+					return nil, fmt.Errorf("%s:%d: %w", fileName, lineNo, err)
+				}
+				if len(ext.States) == 0 {
 					return nil, fmt.Errorf("%s:%d: extend without target state", fileName, lineNo)
 				}
 
 				currentDialog = dlg
 				currentSpeaker = dlg
-				currentState = states[0]
+				currentState = ext.States[0]
 				replyIndex = 0
 				inState = true
 				inExtend = true
+				block = strings.ToUpper(mm[1])
+				extend = ext
 				mode = modeNormal
 				continue
 			}
@@ -393,6 +636,11 @@ func ParseReader(r io.Reader, fileName string) ([]TextOccurrence, error) {
 					inState = true
 					mode = modeChain
 					lastChainTextIdx = -1
+					interject = nil
+					chainCond = ""
+					branches, pendingBranch = nil, ""
+					block = ""
+					extend = nil
 
 					pendingChainIf = false
 					continue
@@ -414,6 +662,11 @@ func ParseReader(r io.Reader, fileName string) ([]TextOccurrence, error) {
 					inState = true
 					mode = modeChain
 					lastChainTextIdx = -1
+					interject = nil
+					chainCond = ""
+					branches, pendingBranch = nil, ""
+					block = ""
+					extend = nil
 
 					pendingChainIf = false
 					continue
@@ -435,18 +688,25 @@ func ParseReader(r io.Reader, fileName string) ([]TextOccurrence, error) {
 				inState = true
 				mode = modeChain
 				lastChainTextIdx = -1
+				interject = nil
+				chainCond = ""
+				branches, pendingBranch = nil, ""
+				block = ""
+				extend = nil
 				continue
 			}
 
 			if mm := reAppendHeader.FindStringSubmatch(line); mm != nil {
-				currentDialog = mm[1]
+				currentDialog = mm[2]
 				if currentDialog == "" {
-					currentDialog = mm[2]
+					currentDialog = mm[3]
 				}
 
 				currentSpeaker = currentDialog
 				currentState = ""
 				inState = false
+				block = strings.ToUpper(mm[1])
+				extend = nil
 				mode = modeNormal
 				continue
 			}
@@ -498,7 +758,7 @@ func ParseReader(r io.Reader, fileName string) ([]TextOccurrence, error) {
 				}
 			}
 
-			// END closes EXTEND_* block only
+			// END closes EXTEND_* block (and the APPEND/REPLACE block context)
 			if strings.EqualFold(line, "END") {
 				if inExtend {
 					inExtend = false
@@ -507,25 +767,37 @@ func ParseReader(r io.Reader, fileName string) ([]TextOccurrence, error) {
 					currentSpeaker = ""
 					currentDialog = ""
 				}
-				if inInterject {
-					inInterject = false
-					continue
-				}
+				block = ""
+				extend = nil
 				continue
 			}
 
+			// INTERJECT / INTERJECT_COPY_TRANS* <dlg> <state> <var>
+			// The body is parsed like a CHAIN body attached to the target state.
 			if mm := reInterjectHeader.FindStringSubmatch(line); mm != nil {
-				dlg := mm[1]
-				st := mm[2]
+				dlg := mm[2]
+				st := mm[3]
 
 				currentDialog = dlg
 				currentSpeaker = dlg
 				currentState = st
 				replyIndex = 0
 				inState = true
-
-				inInterject = true
-
+				mode = modeChain
+				lastChainTextIdx = -1
+				chainCond = ""
+				branches, pendingBranch = nil, ""
+				block = ""
+				extend = nil
+
+				keyword := normalizeInterjectKeyword(mm[1])
+				interject = &Interjection{
+					Keyword:   keyword,
+					Dlg:       dlg,
+					State:     st,
+					Var:       mm[4],
+					CopyTrans: keyword != "INTERJECT",
+				}
 				continue
 			}
 
@@ -534,18 +806,22 @@ func ParseReader(r io.Reader, fileName string) ([]TextOccurrence, error) {
 				if currentDialog == "" || currentState == "" || !inState {
 					return nil, fmt.Errorf("%s:%d: SAY outside state", fileName, lineNo)
 				}
-				id, err := strconv.Atoi(mm[1])
+				traID, strRef, err := parseTextRef(mm[1], mm[2])
 				if err != nil {
-					return nil, fmt.Errorf("%s:%d: invalid TraID in SAY: %w", fileName, lineNo, err)
+					return nil, fmt.Errorf("%s:%d: invalid reference in SAY: %w", fileName, lineNo, err)
 				}
 
 				out = append(out, TextOccurrence{
-					TraID:      intPtr(id),
+					TraID:      traID,
+					StrRef:     strRef,
+					Pos:        refPos(mm[1] + mm[2]),
 					Kind:       KindNPC,
 					SpeakerDlg: currentSpeaker,
 					Dialog:     currentDialog,
 					State:      currentState,
 					Notes:      pendingNotes,
+					Block:      block,
+					Extend:     extend,
 				})
 				pendingNotes = nil
 				continue
@@ -554,22 +830,25 @@ func ParseReader(r io.Reader, fileName string) ([]TextOccurrence, error) {
 			// IF ... THEN REPLY @id <rest> (PC line)
 			if mm := reReply.FindStringSubmatch(line); mm != nil {
 				if currentDialog == "" || currentState == "" || !inState {
-					fmt.Printf("REPLY outside state at %s:%d\n  line=%q\n  dlg=%q speaker=%q state=%q mode=%v inState=%v pendingChainIf=%v\n",
-						fileName, lineNo, line, currentDialog, currentSpeaker, currentState, mode, inState, pendingChainIf)
+					log.Debug("REPLY outside state", "file", fileName, "line", lineNo, "text", line,
+						"dialog", currentDialog, "speaker", currentSpeaker, "state", currentState,
+						"mode", mode, "in_state", inState, "pending_chain_if", pendingChainIf)
 					return nil, fmt.Errorf("%s:%d: REPLY outside state", fileName, lineNo)
 				}
 
 				cond := normalizeCondition(strings.TrimSpace(mm[1]))
 
-				id, err := strconv.Atoi(mm[2])
+				traID, strRef, err := parseTextRef(mm[2], mm[3])
 				if err != nil {
-					return nil, fmt.Errorf("%s:%d: invalid TraID in REPLY: %w", fileName, lineNo, err)
+					return nil, fmt.Errorf("%s:%d: invalid reference in REPLY: %w", fileName, lineNo, err)
 				}
 
-				rest := strings.TrimSpace(mm[3])
+				rest := strings.TrimSpace(mm[4])
 
 				occ := TextOccurrence{
-					TraID:      intPtr(id),
+					TraID:      traID,
+					StrRef:     strRef,
+					Pos:        refPos(mm[2] + mm[3]),
 					Kind:       KindPC,
 					SpeakerDlg: "",
 					Dialog:     currentDialog,
@@ -577,6 +856,8 @@ func ParseReader(r io.Reader, fileName string) ([]TextOccurrence, error) {
 					ReplyIndex: intPtr(replyIndex),
 					Condition:  cond,
 					Notes:      pendingNotes,
+					Block:      block,
+					Extend:     extend,
 				}
 				pendingNotes = nil
 				replyIndex++
@@ -625,11 +906,31 @@ func ParseReader(r io.Reader, fileName string) ([]TextOccurrence, error) {
 				continue
 			}
 
+			// BEGIN on its own line after "BRANCH ~trigger~"
+			if pendingBranch != "" && strings.EqualFold(line, "BEGIN") {
+				branches = append(branches, pendingBranch)
+				pendingBranch = ""
+				continue
+			}
+
+			// END closes the innermost BRANCH, if any
+			if len(branches) > 0 && strings.EqualFold(line, "END") {
+				branches = branches[:len(branches)-1]
+				continue
+			}
+
 			// END ends CHAIN body; after END come REPLY lines in modeNormal for the same state
 			if strings.EqualFold(line, "END") {
+				// INTERJECT_COPY_TRANS*: the last line continues with the target's transitions
+				if interject != nil && interject.CopyTrans && lastChainTextIdx >= 0 {
+					out[lastChainTextIdx].ToType = "COPY_TRANS"
+					out[lastChainTextIdx].ToDlg = strPtr(interject.Dlg)
+					out[lastChainTextIdx].ToState = strPtr(interject.State)
+				}
 				mode = modeNormal
 				inState = true
 				lastChainTextIdx = -1
+				branches, pendingBranch = nil, ""
 				continue
 			}
 
@@ -642,6 +943,7 @@ func ParseReader(r io.Reader, fileName string) ([]TextOccurrence, error) {
 				lineNo++ // ważne dla diagnostyki
 
 				raw2 := sc.Text()
+				stmtRaw = append(stmtRaw, raw2)
 				line2, comment2 := splitter.Split(raw2)
 
 				// komentarze z kolejnych linii też zachowaj (tak jak inline commenty)
@@ -654,6 +956,25 @@ func ParseReader(r io.Reader, fileName string) ([]TextOccurrence, error) {
 
 			line = strings.TrimSpace(statement)
 
+			// BRANCH ~trigger~ BEGIN == dlg @id ... END
+			if mm := reBranch.FindStringSubmatch(line); mm != nil {
+				cond := strings.TrimSpace(mm[1])
+				if mm[2] == "" {
+					pendingBranch = cond
+					continue
+				}
+				branches = append(branches, cond)
+				// lines after BEGIN on the same line, and the END closing them
+				if rest := mm[3]; rest != "" {
+					if me := reBranchEnd.FindStringSubmatch(rest); me != nil {
+						queued = append(queued, me[1], "END")
+					} else {
+						queued = append(queued, rest)
+					}
+				}
+				continue
+			}
+
 			// Interjection with IF:
 			// ==JAHEIJ IF ~InParty("JAHEIRA")~ THEN @201
 			if mm := reInterjectIf.FindStringSubmatch(line); mm != nil {
@@ -667,15 +988,20 @@ func ParseReader(r io.Reader, fileName string) ([]TextOccurrence, error) {
 				if err != nil {
 					return nil, fmt.Errorf("%s:%d: invalid TraID in interjection IF: %w", fileName, lineNo, err)
 				}
+				currentSpeaker = speaker
+				chainCond = cond
 
 				out = append(out, TextOccurrence{
 					TraID:      intPtr(id),
+					Pos:        pos(id),
 					Kind:       KindNPC,
 					SpeakerDlg: speaker,
 					Dialog:     currentDialog,
 					State:      currentState,
 					Condition:  cond,
 					Notes:      pendingNotes,
+					Interject:  interject,
+					Branch:     strings.Join(branches, " "),
 				})
 				pendingNotes = nil
 				lastChainTextIdx = len(out) - 1
@@ -693,22 +1019,40 @@ func ParseReader(r io.Reader, fileName string) ([]TextOccurrence, error) {
 				if err != nil {
 					return nil, fmt.Errorf("%s:%d: invalid TraID in interjection: %w", fileName, lineNo, err)
 				}
+				currentSpeaker = speaker
+				chainCond = ""
 
 				out = append(out, TextOccurrence{
 					TraID:      intPtr(id),
+					Pos:        pos(id),
 					Kind:       KindNPC,
 					SpeakerDlg: speaker,
 					Dialog:     currentDialog,
 					State:      currentState,
 					Notes:      pendingNotes,
+					Interject:  interject,
+					Branch:     strings.Join(branches, " "),
 				})
 				pendingNotes = nil
 				lastChainTextIdx = len(out) - 1
 				continue
 			}
 
+			// Speaker switch with the text on the following line(s):
+			// == JAHEIJ IF ~InParty("JAHEIRA")~ THEN
+			if mm := reInterjectSpeaker.FindStringSubmatch(line); mm != nil {
+				currentSpeaker = mm[1]
+				chainCond = strings.TrimSpace(mm[2])
+				continue
+			}
+
 			// Normal NPC line inside CHAIN body: @200
-			if mm := reChainLine.FindStringSubmatch(line); mm != nil {
+			// or continuation of the current speaker: = @201
+			mm := reChainLine.FindStringSubmatch(line)
+			if mm == nil {
+				mm = reEqChainLine.FindStringSubmatch(line)
+			}
+			if mm != nil {
 				if currentDialog == "" || currentState == "" || currentSpeaker == "" {
 					return nil, fmt.Errorf("%s:%d: chain line outside dialog/state", fileName, lineNo)
 				}
@@ -719,11 +1063,15 @@ func ParseReader(r io.Reader, fileName string) ([]TextOccurrence, error) {
 
 				out = append(out, TextOccurrence{
 					TraID:      intPtr(id),
+					Pos:        pos(id),
 					Kind:       KindNPC,
 					SpeakerDlg: currentSpeaker,
 					Dialog:     currentDialog,
 					State:      currentState,
+					Condition:  chainCond,
 					Notes:      pendingNotes,
+					Interject:  interject,
+					Branch:     strings.Join(branches, " "),
 				})
 				pendingNotes = nil
 				lastChainTextIdx = len(out) - 1
@@ -738,15 +1086,17 @@ func ParseReader(r io.Reader, fileName string) ([]TextOccurrence, error) {
 
 				cond := normalizeCondition(strings.TrimSpace(mm[1]))
 
-				id, err := strconv.Atoi(mm[2])
+				traID, strRef, err := parseTextRef(mm[2], mm[3])
 				if err != nil {
-					return nil, fmt.Errorf("%s:%d: invalid TraID in REPLY: %w", fileName, lineNo, err)
+					return nil, fmt.Errorf("%s:%d: invalid reference in REPLY: %w", fileName, lineNo, err)
 				}
 
-				rest := strings.TrimSpace(mm[3])
+				rest := strings.TrimSpace(mm[4])
 
 				occ := TextOccurrence{
-					TraID:      intPtr(id),
+					TraID:      traID,
+					StrRef:     strRef,
+					Pos:        refPos(mm[2] + mm[3]),
 					Kind:       KindPC,
 					SpeakerDlg: "",
 					Dialog:     currentDialog,
@@ -779,6 +1129,27 @@ func ParseReader(r io.Reader, fileName string) ([]TextOccurrence, error) {
 				continue
 			}
 
+			// Chain epilogue: COPY_TRANS dlg state / END dlg state
+			if mm := reChainEpilogue.FindStringSubmatch(line); mm != nil {
+				keyword := strings.ToUpper(mm[1])
+				if lastChainTextIdx < 0 {
+					return nil, fmt.Errorf("%s:%d: %s in CHAIN body without preceding text", fileName, lineNo, keyword)
+				}
+				toType := "COPY_TRANS"
+				if keyword == "END" {
+					toType = "EXTERN"
+				}
+				out[lastChainTextIdx].ToType = toType
+				out[lastChainTextIdx].ToDlg = strPtr(mm[2])
+				out[lastChainTextIdx].ToState = strPtr(mm[3])
+
+				mode = modeNormal
+				inState = true
+				lastChainTextIdx = -1
+				branches, pendingBranch = nil, ""
+				continue
+			}
+
 			// Auto-transition in CHAIN body: EXTERN ... or EXIT
 			if mm := reExternOnly.FindStringSubmatch(line); mm != nil {
 				if lastChainTextIdx < 0 {
@@ -791,6 +1162,7 @@ func ParseReader(r io.Reader, fileName string) ([]TextOccurrence, error) {
 				mode = modeNormal
 				inState = true
 				lastChainTextIdx = -1
+				branches, pendingBranch = nil, ""
 				continue
 			}
 
@@ -805,6 +1177,7 @@ func ParseReader(r io.Reader, fileName string) ([]TextOccurrence, error) {
 				mode = modeNormal
 				inState = true
 				lastChainTextIdx = -1
+				branches, pendingBranch = nil, ""
 				continue
 			}
 
@@ -850,6 +1223,7 @@ func ParseReader(r io.Reader, fileName string) ([]TextOccurrence, error) {
 				lineNo++ // ważne dla diagnostyki
 
 				raw2 := sc.Text()
+				stmtRaw = append(stmtRaw, raw2)
 				line2, comment2 := splitter.Split(raw2)
 
 				// komentarze z kolejnych linii też zachowaj (tak jak inline commenty)
@@ -864,19 +1238,22 @@ func ParseReader(r io.Reader, fileName string) ([]TextOccurrence, error) {
 
 			// SAY @id
 			if mm := reSay.FindStringSubmatch(line); mm != nil {
-				id, err := strconv.Atoi(mm[1])
+				traID, strRef, err := parseTextRef(mm[1], mm[2])
 				if err != nil {
-					return nil, fmt.Errorf("%s:%d: invalid TraID in SAY: %w", fileName, lineNo, err)
+					return nil, fmt.Errorf("%s:%d: invalid reference in SAY: %w", fileName, lineNo, err)
 				}
 
 				out = append(out, TextOccurrence{
-					TraID:      intPtr(id),
+					TraID:      traID,
+					StrRef:     strRef,
+					Pos:        refPos(mm[1] + mm[2]),
 					Kind:       KindNPC,
 					SpeakerDlg: currentSpeaker,
 					Dialog:     currentDialog,
 					State:      currentState,
 					Condition:  stateEntryCond,
 					Notes:      pendingNotes,
+					Block:      block,
 				})
 				pendingNotes = nil
 				lastChainTextIdx = len(out) - 1
@@ -891,12 +1268,14 @@ func ParseReader(r io.Reader, fileName string) ([]TextOccurrence, error) {
 				}
 				out = append(out, TextOccurrence{
 					TraID:      intPtr(id),
+					Pos:        pos(id),
 					Kind:       KindNPC,
 					SpeakerDlg: currentSpeaker,
 					Dialog:     currentDialog,
 					State:      currentState,
 					Condition:  stateEntryCond,
 					Notes:      pendingNotes,
+					Block:      block,
 				})
 				pendingNotes = nil
 				lastChainTextIdx = len(out) - 1
@@ -917,12 +1296,14 @@ func ParseReader(r io.Reader, fileName string) ([]TextOccurrence, error) {
 
 				out = append(out, TextOccurrence{
 					TraID:      intPtr(id),
+					Pos:        pos(id),
 					Kind:       KindNPC,
 					SpeakerDlg: currentSpeaker,
 					Dialog:     currentDialog,
 					State:      currentState,
 					Condition:  stateEntryCond,
 					Notes:      pendingNotes,
+					Block:      block,
 				})
 				pendingNotes = nil
 				lastChainTextIdx = len(out) - 1
@@ -932,27 +1313,31 @@ func ParseReader(r io.Reader, fileName string) ([]TextOccurrence, error) {
 			// IF ... THEN REPLY @id <rest>
 			if mm := reReply.FindStringSubmatch(line); mm != nil {
 				if currentDialog == "" || currentState == "" || !inState {
-					fmt.Printf("REPLY outside state at %s:%d\n  line=%q\n  dlg=%q speaker=%q state=%q mode=%v inState=%v pendingChainIf=%v\n",
-						fileName, lineNo, line, currentDialog, currentSpeaker, currentState, mode, inState, pendingChainIf)
+					log.Debug("REPLY outside state", "file", fileName, "line", lineNo, "text", line,
+						"dialog", currentDialog, "speaker", currentSpeaker, "state", currentState,
+						"mode", mode, "in_state", inState, "pending_chain_if", pendingChainIf)
 					return nil, fmt.Errorf("%s:%d: REPLY outside state", fileName, lineNo)
 				}
 				cond := normalizeCondition(mm[1])
 
-				id, err := strconv.Atoi(mm[2])
+				traID, strRef, err := parseTextRef(mm[2], mm[3])
 				if err != nil {
-					return nil, fmt.Errorf("%s:%d: invalid TraID in REPLY: %w", fileName, lineNo, err)
+					return nil, fmt.Errorf("%s:%d: invalid reference in REPLY: %w", fileName, lineNo, err)
 				}
 
-				rest := strings.TrimSpace(mm[3])
+				rest := strings.TrimSpace(mm[4])
 
 				occ := TextOccurrence{
-					TraID:      intPtr(id),
+					TraID:      traID,
+					StrRef:     strRef,
+					Pos:        refPos(mm[2] + mm[3]),
 					Kind:       KindPC,
 					Dialog:     currentDialog,
 					State:      currentState,
 					ReplyIndex: intPtr(replyIndex),
 					Condition:  cond,
 					Notes:      pendingNotes,
+					Block:      block,
 				}
 				pendingNotes = nil
 				replyIndex++
@@ -1008,6 +1393,85 @@ func normalizeCondition(cond string) string {
 	return cond
 }
 
+// locateTraRef finds "@id" in the raw lines of a statement starting at
+// firstLine. If the reference can't be found, the statement start is returned.
+func locateTraRef(fileName string, firstLine int, rawLines []string, id int) helpers.Pos {
+	return locateRef(fileName, firstLine, rawLines, "@"+strconv.Itoa(id))
+}
+
+// locateRef is locateTraRef for an arbitrary reference such as "@12" or "#345".
+func locateRef(fileName string, firstLine int, rawLines []string, needle string) helpers.Pos {
+	for i, raw := range rawLines {
+		from := 0
+		for {
+			idx := strings.Index(raw[from:], needle)
+			if idx < 0 {
+				break
+			}
+			end := from + idx + len(needle)
+			if end == len(raw) || raw[end] < '0' || raw[end] > '9' {
+				return helpers.Pos{File: fileName, Line: firstLine + i, Col: from + idx + 1}
+			}
+			from = end
+		}
+	}
+
+	col := 1
+	if len(rawLines) > 0 {
+		col += len(rawLines[0]) - len(strings.TrimLeft(rawLines[0], " \t"))
+	}
+	return helpers.Pos{File: fileName, Line: firstLine, Col: col}
+}
+
+// parseTextRef converts a SAY/REPLY reference into a TRA id (@12) or a
+// game strref (#345); exactly one of the results is non-nil.
+func parseTextRef(sigil, num string) (traID, strRef *int, err error) {
+	n, err := strconv.Atoi(num)
+	if err != nil {
+		return nil, nil, err
+	}
+	if sigil == "#" {
+		return nil, intPtr(n), nil
+	}
+	return intPtr(n), nil, nil
+}
+
+// parseExtendTargets splits the EXTEND_* target list into states and the
+// optional #position, e.g. "6 7 #4" or "6 # 4".
+func parseExtendTargets(list string) (*Extension, error) {
+	ext := &Extension{}
+	fields := strings.Fields(list)
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		if !strings.HasPrefix(f, "#") {
+			ext.States = append(ext.States, f)
+			continue
+		}
+
+		num := strings.TrimPrefix(f, "#")
+		if num == "" && i+1 < len(fields) {
+			i++
+			num = fields[i]
+		}
+		n, err := strconv.Atoi(num)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EXTEND position %q", f)
+		}
+		ext.Position = intPtr(n)
+	}
+	return ext, nil
+}
+
+// normalizeInterjectKeyword expands the I_C_T* abbreviations,
+// e.g. "i_c_t2" -> "INTERJECT_COPY_TRANS2".
+func normalizeInterjectKeyword(kw string) string {
+	kw = strings.ToUpper(kw)
+	if rest, ok := strings.CutPrefix(kw, "I_C_T"); ok {
+		return "INTERJECT_COPY_TRANS" + rest
+	}
+	return kw
+}
+
 func intPtr(v int) *int       { return &v }
 func strPtr(v string) *string { return &v }
 
@@ -348,3 +348,35 @@ func TestLooksLikeWeiduCode_ExtraCases(t *testing.T) {
 		})
 	}
 }
+
+func TestParsePatchAction(t *testing.T) {
+	tests := []struct {
+		name         string
+		in           string
+		wantComplete bool
+		wantKeyword  string
+		wantArgs     int
+	}{
+		{"replace_say", `REPLACE_SAY JAHEIJ 12 @1`, true, "REPLACE_SAY", 3},
+		{"replace_say_missing_text", `REPLACE_SAY JAHEIJ 12`, false, "REPLACE_SAY", 2},
+		{"alter_trans_open_list", `ALTER_TRANS X BEGIN 1 END BEGIN 0 END BEGIN "REPLY"`, false, "ALTER_TRANS", 3},
+		{"alter_trans_full", "ALTER_TRANS X BEGIN 1 END BEGIN 0 END BEGIN\n\"REPLY\" ~@5~\nEND", true, "ALTER_TRANS", 4},
+		{"end_inside_literal_is_text", `ALTER_TRANS X BEGIN 1 END BEGIN 0 END BEGIN "ACTION" ~END~`, false, "ALTER_TRANS", 3},
+		{"lowercase_keyword", `replace_state_trigger X 1 ~True()~`, true, "REPLACE_STATE_TRIGGER", 3},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pa, complete := parsePatchAction(tc.in)
+			if complete != tc.wantComplete {
+				t.Fatalf("complete=%v want %v", complete, tc.wantComplete)
+			}
+			if pa.Keyword != tc.wantKeyword {
+				t.Fatalf("keyword=%q want %q", pa.Keyword, tc.wantKeyword)
+			}
+			if len(pa.Args) != tc.wantArgs {
+				t.Fatalf("args=%d want %d: %+v", len(pa.Args), tc.wantArgs, pa.Args)
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package d
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	helpers "github.com/maciejjwojcik/dlg2csv/internal/utils"
+)
+
+// patchArity lists the dialogue patch actions WeiDU accepts in .d files
+// together with the number of leading arguments they require (a
+// BEGIN ... END list counts as one argument). Optional trailing parts
+// (UNLESS, extra state lists, DO ...) are not needed to parse the action
+// and are skipped as unknown lines.
+var patchArity = map[string]int{
+	// string-bearing
+	"REPLACE_SAY": 3, // dlg state text
+	"ALTER_TRANS": 4, // dlg BEGIN states END BEGIN trans END BEGIN changes END
+
+	// no translatable strings
+	"ADD_STATE_TRIGGER":                  3, // dlg state trigger
+	"ADD_TRANS_TRIGGER":                  3, // dlg state trigger
+	"REPLACE_STATE_TRIGGER":              3, // dlg state trigger
+	"SET_WEIGHT":                         3, // dlg state #weight
+	"ADD_TRANS_ACTION":                   4, // dlg BEGIN states END BEGIN trans END action
+	"REPLACE_TRANS_ACTION":               5, // dlg BEGIN states END BEGIN trans END old new
+	"REPLACE_TRANS_TRIGGER":              5, // dlg BEGIN states END BEGIN trans END old new
+	"REPLACE_TRIGGER_TEXT":               3, // dlg old new
+	"REPLACE_TRIGGER_TEXT_REGEXP":        3,
+	"REPLACE_ACTION_TEXT":                3,
+	"REPLACE_ACTION_TEXT_REGEXP":         3,
+	"REPLACE_ACTION_TEXT_PROCESS":        3,
+	"REPLACE_ACTION_TEXT_PROCESS_REGEXP": 3,
+}
+
+// @123 as a bare word or as the content of ~@123~ / "@123"
+var rePatchTraRef = regexp.MustCompile(`^@(\d+)$`)
+
+// patchToken is a single argument of a patch action: a word, a string
+// literal (delimiters stripped) or a BEGIN ... END list.
+type patchToken struct {
+	Text   string
+	List   []string
+	IsList bool
+}
+
+// patchAction is a parsed dialogue patch action, e.g.
+//
+//	ALTER_TRANS PLAYER1 BEGIN 33 END BEGIN 0 END BEGIN "REPLY" ~@5~ END
+type patchAction struct {
+	Keyword string
+	Args    []patchToken // arguments after the keyword
+}
+
+// patchKeyword returns the upper-cased patch action keyword that starts the
+// line, or "" if the line doesn't start a patch action.
+func patchKeyword(line string) string {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return ""
+	}
+	kw := strings.ToUpper(fields[0])
+	if _, ok := patchArity[kw]; !ok {
+		return ""
+	}
+	return kw
+}
+
+// parsePatchAction tokenizes a (possibly multiline) patch action statement.
+// complete reports whether all required arguments are present; callers keep
+// appending lines until it is true.
+func parsePatchAction(stmt string) (pa patchAction, complete bool) {
+	tokens, closed := tokenizePatch(stmt)
+	if len(tokens) == 0 {
+		return patchAction{}, false
+	}
+
+	pa.Keyword = strings.ToUpper(tokens[0].Text)
+	pa.Args = tokens[1:]
+
+	return pa, closed && len(pa.Args) >= patchArity[pa.Keyword]
+}
+
+// tokenizePatch splits a patch statement into words, string literals and
+// BEGIN ... END lists. closed is false while a literal or list is still open.
+func tokenizePatch(s string) (tokens []patchToken, closed bool) {
+	var (
+		list   []string
+		inList bool
+	)
+
+	// literal marks ~...~ / "..." tokens, which are never keywords
+	emit := func(tok string, literal bool) {
+		if inList {
+			if !literal && strings.EqualFold(tok, "END") {
+				tokens = append(tokens, patchToken{List: list, IsList: true})
+				list = nil
+				inList = false
+				return
+			}
+			list = append(list, tok)
+			return
+		}
+		if !literal && strings.EqualFold(tok, "BEGIN") {
+			inList = true
+			list = []string{}
+			return
+		}
+		tokens = append(tokens, patchToken{Text: tok})
+	}
+
+	i := 0
+	for i < len(s) {
+		ch := s[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			i++
+		case ch == '~' || ch == '"':
+			end := strings.IndexByte(s[i+1:], ch)
+			if end < 0 {
+				return tokens, false
+			}
+			emit(s[i+1:i+1+end], true)
+			i += end + 2
+		default:
+			j := i
+			for j < len(s) && !strings.ContainsRune(" \t\r\n~\"", rune(s[j])) {
+				j++
+			}
+			emit(s[i:j], false)
+			i = j
+		}
+	}
+
+	return tokens, !inList
+}
+
+// traRef returns the @id referenced by a patch argument (@5, ~@5~ or "@5").
+func (t patchToken) traRef() (string, bool) {
+	if t.IsList {
+		return "", false
+	}
+	m := rePatchTraRef.FindStringSubmatch(strings.TrimSpace(t.Text))
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// occurrences returns the translatable strings introduced by the action:
+//   - REPLACE_SAY dlg state @id  -> NPC line of the patched state,
+//   - ALTER_TRANS ... "REPLY" ~@id~ -> PC reply for each patched transition,
+//   - ALTER_TRANS ... "JOURNAL" ~@id~ (and SOLVED_/UNSOLVED_) -> journal entry.
+//
+// Other actions carry only triggers/actions and yield nothing.
+func (pa patchAction) occurrences(pos func(int) helpers.Pos) []TextOccurrence {
+	switch pa.Keyword {
+	case "REPLACE_SAY":
+		dlg, state := pa.Args[0].Text, pa.Args[1].Text
+		ref, ok := pa.Args[2].traRef()
+		if !ok {
+			return nil
+		}
+		id, _ := strconv.Atoi(ref)
+		return []TextOccurrence{{
+			TraID:      intPtr(id),
+			Pos:        pos(id),
+			Kind:       KindNPC,
+			SpeakerDlg: dlg,
+			Dialog:     dlg,
+			State:      state,
+			Patch:      pa.Keyword,
+		}}
+
+	case "ALTER_TRANS":
+		dlg := pa.Args[0].Text
+		states, trans, changes := pa.Args[1].List, pa.Args[2].List, pa.Args[3].List
+
+		var out []TextOccurrence
+		for i := 0; i+1 < len(changes); i += 2 {
+			var kind TextKind
+			switch strings.ToUpper(changes[i]) {
+			case "REPLY":
+				kind = KindPC
+			case "JOURNAL", "SOLVED_JOURNAL", "UNSOLVED_JOURNAL":
+				kind = KindJournal
+			default:
+				continue
+			}
+			ref, ok := patchToken{Text: changes[i+1]}.traRef()
+			if !ok {
+				continue
+			}
+			id, _ := strconv.Atoi(ref)
+
+			for _, st := range states {
+				for _, tr := range trans {
+					occ := TextOccurrence{
+						TraID:  intPtr(id),
+						Pos:    pos(id),
+						Kind:   kind,
+						Dialog: dlg,
+						State:  st,
+						Patch:  pa.Keyword,
+					}
+					if n, err := strconv.Atoi(tr); err == nil {
+						occ.ReplyIndex = intPtr(n)
+					}
+					out = append(out, occ)
+				}
+			}
+		}
+		return out
+	}
+
+	return nil
+}
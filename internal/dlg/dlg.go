@@ -0,0 +1,245 @@
+// Package dlg reads compiled Infinity Engine dialogues (DLG V1.0).
+//
+// It is used to show translators what a vanilla state says when a mod
+// interjects into it or extends it. Game files are never bundled with
+// dlg2csv; callers point at the user's own override folder.
+package dlg
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	headerSize     = 0x30
+	stateSize      = 16
+	transitionSize = 32
+	pointerSize    = 8 // trigger/action table entry: offset u32, length u32
+
+	noIndex = 0xFFFFFFFF
+)
+
+// Transition flag bits.
+const (
+	FlagHasText       = 1 << 0
+	FlagHasTrigger    = 1 << 1
+	FlagHasAction     = 1 << 2
+	FlagTerminates    = 1 << 3
+	FlagHasJournal    = 1 << 4
+	FlagInterrupt     = 1 << 5
+	FlagUnsolvedQuest = 1 << 6
+	FlagJournalNote   = 1 << 7
+	FlagSolvedQuest   = 1 << 8
+)
+
+// File is a parsed DLG file.
+type File struct {
+	States []State
+}
+
+// State is a single NPC line with its player replies.
+type State struct {
+	Text        int    // strref of the NPC line
+	Trigger     string // state trigger, empty if none
+	Transitions []Transition
+}
+
+// Transition is a player reply (or a silent transition without text).
+type Transition struct {
+	Flags     uint32
+	Text      int // strref of the reply, -1 without FlagHasText
+	Journal   int // strref of the journal entry, -1 without FlagHasJournal
+	Trigger   string
+	NextDlg   string // empty when FlagTerminates is set
+	NextState int
+}
+
+// HasText reports whether the transition shows a player reply.
+func (t Transition) HasText() bool { return t.Flags&FlagHasText != 0 }
+
+// Terminates reports whether the transition ends the dialogue.
+func (t Transition) Terminates() bool { return t.Flags&FlagTerminates != 0 }
+
+// Open reads a DLG file from disk.
+func Open(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return f, nil
+}
+
+// Parse decodes the contents of a DLG V1.0 file.
+//
+// Layout (all offsets absolute, little endian):
+//
+//	header      "DLG V1.0" states:u32 stateOff:u32 trans:u32 transOff:u32
+//	            stTrigOff:u32 stTrigN:u32 trTrigOff:u32 trTrigN:u32
+//	            actOff:u32 actN:u32 [flags:u32]
+//	state       text:u32 firstTrans:u32 transN:u32 trigger:u32
+//	transition  flags:u32 text:u32 journal:u32 trigger:u32 action:u32
+//	            nextDlg:8 nextState:u32
+func Parse(data []byte) (*File, error) {
+	if len(data) < headerSize {
+		return nil, fmt.Errorf("dlg: file too short")
+	}
+	if string(data[0:8]) != "DLG V1.0" {
+		return nil, fmt.Errorf("dlg: unsupported signature %q", data[0:8])
+	}
+
+	le := binary.LittleEndian
+	u32 := func(off int) int { return int(le.Uint32(data[off:])) }
+
+	nStates, stateOff := u32(0x08), u32(0x0C)
+	nTrans, transOff := u32(0x10), u32(0x14)
+	stTrigOff, nStTrig := u32(0x18), u32(0x1C)
+	trTrigOff, nTrTrig := u32(0x20), u32(0x24)
+
+	if !inBounds(data, stateOff, nStates, stateSize) {
+		return nil, fmt.Errorf("dlg: state table truncated (%d states)", nStates)
+	}
+	if !inBounds(data, transOff, nTrans, transitionSize) {
+		return nil, fmt.Errorf("dlg: transition table truncated (%d transitions)", nTrans)
+	}
+	if !inBounds(data, stTrigOff, nStTrig, pointerSize) || !inBounds(data, trTrigOff, nTrTrig, pointerSize) {
+		return nil, fmt.Errorf("dlg: trigger table truncated")
+	}
+
+	// text returns entry i of a trigger table, or "" if it's out of range
+	text := func(tableOff, n, i int) string {
+		if i < 0 || i >= n {
+			return ""
+		}
+		e := tableOff + i*pointerSize
+		off, size := u32(e), u32(e+4)
+		if off < 0 || size < 0 || off+size > len(data) {
+			return ""
+		}
+		return strings.TrimSpace(string(data[off : off+size]))
+	}
+	index := func(off int) int {
+		v := le.Uint32(data[off:])
+		if v == noIndex {
+			return -1
+		}
+		return int(v)
+	}
+
+	trans := make([]Transition, nTrans)
+	for i := range trans {
+		e := transOff + i*transitionSize
+		t := Transition{
+			Flags:     le.Uint32(data[e:]),
+			Text:      -1,
+			Journal:   -1,
+			NextState: u32(e + 28),
+		}
+		if t.Flags&FlagHasText != 0 {
+			t.Text = u32(e + 4)
+		}
+		if t.Flags&FlagHasJournal != 0 {
+			t.Journal = u32(e + 8)
+		}
+		if t.Flags&FlagHasTrigger != 0 {
+			t.Trigger = text(trTrigOff, nTrTrig, index(e+12))
+		}
+		if t.Flags&FlagTerminates == 0 {
+			t.NextDlg = strings.ToUpper(strings.TrimRight(string(data[e+20:e+28]), "\x00"))
+		}
+		trans[i] = t
+	}
+
+	states := make([]State, nStates)
+	for i := range states {
+		e := stateOff + i*stateSize
+		first, n := u32(e+4), u32(e+8)
+		if first < 0 || n < 0 || first+n > len(trans) {
+			return nil, fmt.Errorf("dlg: state %d references missing transitions", i)
+		}
+		states[i] = State{
+			Text:        u32(e),
+			Trigger:     text(stTrigOff, nStTrig, index(e+12)),
+			Transitions: trans[first : first+n],
+		}
+	}
+
+	return &File{States: states}, nil
+}
+
+func inBounds(data []byte, off, n, size int) bool {
+	return off >= 0 && n >= 0 && off+n*size <= len(data)
+}
+
+// State returns state i, or false if the file has no such state.
+func (f *File) State(i int) (State, bool) {
+	if f == nil || i < 0 || i >= len(f.States) {
+		return State{}, false
+	}
+	return f.States[i], true
+}
+
+// Override gives access to the .dlg files of a game override folder.
+// Files are looked up case-insensitively and parsed on first use.
+type Override struct {
+	paths map[string]string // lower-case resref -> path
+	files map[string]*File
+}
+
+// OpenOverride indexes the .dlg files in dir.
+func OpenOverride(dir string) (*Override, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &Override{paths: map[string]string{}, files: map[string]*File{}}
+	for _, ent := range entries {
+		name := ent.Name()
+		if ent.IsDir() || !strings.EqualFold(filepath.Ext(name), ".dlg") {
+			continue
+		}
+		o.paths[strings.ToLower(strings.TrimSuffix(name, filepath.Ext(name)))] = filepath.Join(dir, name)
+	}
+	return o, nil
+}
+
+// Dialog returns the parsed resref.dlg, or nil if the folder doesn't have it.
+func (o *Override) Dialog(resref string) (*File, error) {
+	key := strings.ToLower(resref)
+	if f, ok := o.files[key]; ok {
+		return f, nil
+	}
+	path, ok := o.paths[key]
+	if !ok {
+		return nil, nil
+	}
+
+	f, err := Open(path)
+	if err != nil {
+		return nil, err
+	}
+	o.files[key] = f
+	return f, nil
+}
+
+// State returns state of dialog resref; state must be a numeric index.
+// Missing dialogs, unreadable files and named states yield false.
+func (o *Override) State(resref, state string) (State, bool) {
+	i, err := strconv.Atoi(state)
+	if err != nil {
+		return State{}, false
+	}
+	f, err := o.Dialog(resref)
+	if err != nil || f == nil {
+		return State{}, false
+	}
+	return f.State(i)
+}
@@ -0,0 +1,194 @@
+package dlg
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type testTrans struct {
+	flags     uint32
+	text      uint32
+	trigger   string
+	nextDlg   string
+	nextState uint32
+}
+
+type testState struct {
+	text    uint32
+	trigger string
+	trans   []testTrans
+}
+
+// buildDLG encodes states as a DLG V1.0 file with a 0x34 byte header.
+func buildDLG(states []testState) []byte {
+	le := binary.LittleEndian
+
+	var (
+		trans    []testTrans
+		stTrig   []string
+		trTrig   []string
+		stateBuf []byte
+	)
+	for _, st := range states {
+		e := make([]byte, stateSize)
+		le.PutUint32(e[0:], st.text)
+		le.PutUint32(e[4:], uint32(len(trans)))
+		le.PutUint32(e[8:], uint32(len(st.trans)))
+		le.PutUint32(e[12:], noIndex)
+		if st.trigger != "" {
+			le.PutUint32(e[12:], uint32(len(stTrig)))
+			stTrig = append(stTrig, st.trigger)
+		}
+		stateBuf = append(stateBuf, e...)
+		trans = append(trans, st.trans...)
+	}
+
+	const hdr = 0x34
+	stateOff := hdr
+	transOff := stateOff + len(stateBuf)
+	stTrigOff := transOff + len(trans)*transitionSize
+	trTrigOff := stTrigOff + len(stTrig)*pointerSize
+	for _, t := range trans {
+		if t.trigger != "" {
+			trTrig = append(trTrig, t.trigger)
+		}
+	}
+	textOff := trTrigOff + len(trTrig)*pointerSize
+
+	data := make([]byte, hdr)
+	copy(data, "DLG V1.0")
+	le.PutUint32(data[0x08:], uint32(len(states)))
+	le.PutUint32(data[0x0C:], uint32(stateOff))
+	le.PutUint32(data[0x10:], uint32(len(trans)))
+	le.PutUint32(data[0x14:], uint32(transOff))
+	le.PutUint32(data[0x18:], uint32(stTrigOff))
+	le.PutUint32(data[0x1C:], uint32(len(stTrig)))
+	le.PutUint32(data[0x20:], uint32(trTrigOff))
+	le.PutUint32(data[0x24:], uint32(len(trTrig)))
+	data = append(data, stateBuf...)
+
+	trIdx := 0
+	for _, t := range trans {
+		e := make([]byte, transitionSize)
+		le.PutUint32(e[0:], t.flags)
+		le.PutUint32(e[4:], t.text)
+		le.PutUint32(e[12:], noIndex)
+		if t.trigger != "" {
+			le.PutUint32(e[12:], uint32(trIdx))
+			trIdx++
+		}
+		copy(e[20:28], t.nextDlg)
+		le.PutUint32(e[28:], t.nextState)
+		data = append(data, e...)
+	}
+
+	var texts []byte
+	table := func(entries []string) {
+		for _, s := range entries {
+			e := make([]byte, pointerSize)
+			le.PutUint32(e[0:], uint32(textOff+len(texts)))
+			le.PutUint32(e[4:], uint32(len(s)))
+			texts = append(texts, s...)
+			data = append(data, e...)
+		}
+	}
+	table(stTrig)
+	table(trTrig)
+
+	return append(data, texts...)
+}
+
+func TestParse_StatesAndTransitions(t *testing.T) {
+	data := buildDLG([]testState{
+		{text: 100, trigger: `Global("X","GLOBAL",1)`, trans: []testTrans{
+			{flags: FlagHasText | FlagTerminates, text: 101},
+			{flags: FlagHasText | FlagHasTrigger, text: 102, trigger: "InParty(\"Imoen\")\r\n", nextDlg: "IMOEN2J", nextState: 7},
+		}},
+		{text: 200, trans: []testTrans{
+			{flags: 0, nextDlg: "JAHEIJ", nextState: 0},
+		}},
+	})
+
+	f, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if len(f.States) != 2 {
+		t.Fatalf("expected 2 states, got %d", len(f.States))
+	}
+
+	st, ok := f.State(0)
+	if !ok || st.Text != 100 || st.Trigger != `Global("X","GLOBAL",1)` || len(st.Transitions) != 2 {
+		t.Fatalf("state 0 mismatch: %+v", st)
+	}
+
+	t0, t1 := st.Transitions[0], st.Transitions[1]
+	if !t0.HasText() || !t0.Terminates() || t0.Text != 101 || t0.NextDlg != "" {
+		t.Fatalf("transition 0 mismatch: %+v", t0)
+	}
+	if t1.Text != 102 || t1.Trigger != `InParty("Imoen")` || t1.NextDlg != "IMOEN2J" || t1.NextState != 7 {
+		t.Fatalf("transition 1 mismatch: %+v", t1)
+	}
+
+	silent := f.States[1].Transitions[0]
+	if silent.HasText() || silent.Text != -1 || silent.NextDlg != "JAHEIJ" {
+		t.Fatalf("silent transition mismatch: %+v", silent)
+	}
+
+	if _, ok := f.State(2); ok {
+		t.Fatalf("State(2) should not exist")
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	valid := buildDLG([]testState{{text: 1}})
+
+	badSig := append([]byte(nil), valid...)
+	copy(badSig, "DLG V2.0")
+
+	badTrans := buildDLG([]testState{{text: 1, trans: []testTrans{{flags: FlagTerminates}}}})
+	binary.LittleEndian.PutUint32(badTrans[0x34+8:], 5) // state claims 5 transitions
+
+	tests := map[string][]byte{
+		"too short":           valid[:0x20],
+		"bad signature":       badSig,
+		"truncated states":    valid[:0x34+stateSize-1],
+		"missing transitions": badTrans,
+	}
+	for name, data := range tests {
+		if _, err := Parse(data); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
+
+func TestOverride_State(t *testing.T) {
+	dir := t.TempDir()
+	data := buildDLG([]testState{{text: 10}, {text: 11}})
+	if err := os.WriteFile(filepath.Join(dir, "JAHEIJ.DLG"), data, 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "readme.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	o, err := OpenOverride(dir)
+	if err != nil {
+		t.Fatalf("OpenOverride error: %v", err)
+	}
+
+	if st, ok := o.State("jaheij", "1"); !ok || st.Text != 11 {
+		t.Fatalf("State(jaheij, 1) = %+v, %v", st, ok)
+	}
+	if _, ok := o.State("JAHEIJ", "AC#Named"); ok {
+		t.Fatalf("named states can't be looked up in compiled dialogs")
+	}
+	if _, ok := o.State("IMOEN2J", "0"); ok {
+		t.Fatalf("missing dialog should not be found")
+	}
+	if f, err := o.Dialog("IMOEN2J"); f != nil || err != nil {
+		t.Fatalf("Dialog(missing) = %v, %v", f, err)
+	}
+}
@@ -0,0 +1,234 @@
+// Package dump writes what the parsers read from the .d and .tra files as
+// JSON, for scripts that want dlg2csv's parsing without reimplementing it.
+//
+// The schema is versioned by Schema; fields are only added within a
+// version. Optional fields are left out when empty. WriteJSON writes one
+// Document; WriteNDJSON writes each occurrence and each string on a line
+// of its own, so large mods can be read as a stream.
+package dump
+
+import (
+	"encoding/json"
+	"io"
+	"maps"
+	"slices"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/d"
+	"github.com/maciejjwojcik/dlg2csv/internal/tra"
+	helpers "github.com/maciejjwojcik/dlg2csv/internal/utils"
+)
+
+// Schema is the version of the output format.
+const Schema = 1
+
+// Document is the output of WriteJSON.
+type Document struct {
+	Schema  int      `json:"schema"`
+	Dialogs []Dialog `json:"dialogs"`
+	Tras    []Tra    `json:"tras"`
+}
+
+// Dialog holds the lines of one .d file.
+type Dialog struct {
+	File        string       `json:"file"` // .d key, e.g. "bdnpc"
+	Occurrences []Occurrence `json:"occurrences"`
+}
+
+// Occurrence is a line of a .d file, see d.TextOccurrence.
+type Occurrence struct {
+	Kind      string   `json:"kind"`             // NPC, PC or JOURNAL
+	TraID     *int     `json:"tra,omitempty"`    // @id
+	StrRef    *int     `json:"strref,omitempty"` // #strref of a vanilla string
+	Speaker   string   `json:"speaker,omitempty"`
+	Dialog    string   `json:"dialog"`
+	State     string   `json:"state"`
+	Reply     *int     `json:"reply,omitempty"` // index of the reply in its state
+	Goto      *Goto    `json:"goto,omitempty"`
+	Condition string   `json:"condition,omitempty"`
+	Notes     []string `json:"notes,omitempty"`
+	Pos       Pos      `json:"pos"`
+
+	Interject *Interject `json:"interject,omitempty"`
+	Block     string     `json:"block,omitempty"` // APPEND, REPLACE, EXTEND_TOP, ...
+	Extend    *Extend    `json:"extend,omitempty"`
+	Branch    string     `json:"branch,omitempty"`
+	Patch     string     `json:"patch,omitempty"` // e.g. REPLACE_SAY
+}
+
+// Goto is the transition of a line.
+type Goto struct {
+	Type   string `json:"type"` // EXIT, GOTO, EXTERN or COPY_TRANS
+	Dialog string `json:"dialog,omitempty"`
+	State  string `json:"state,omitempty"`
+}
+
+// Interject is the INTERJECT-family block of a line.
+type Interject struct {
+	Keyword   string `json:"keyword"`
+	Dialog    string `json:"dialog"`
+	State     string `json:"state"`
+	Var       string `json:"var,omitempty"`
+	CopyTrans bool   `json:"copyTrans"`
+}
+
+// Extend is the target of an EXTEND_TOP / EXTEND_BOTTOM block.
+type Extend struct {
+	States   []string `json:"states"`
+	Position *int     `json:"position,omitempty"`
+}
+
+// Pos is a position in a source file; Line and Col are 1-based and left
+// out when unknown.
+type Pos struct {
+	File string `json:"file,omitempty"`
+	Line int    `json:"line,omitempty"`
+	Col  int    `json:"col,omitempty"`
+}
+
+// Tra holds the strings of one .tra file.
+type Tra struct {
+	File       string      `json:"file"` // .tra key, e.g. "bdnpc"
+	Encoding   string      `json:"encoding,omitempty"`
+	Strings    []String    `json:"strings"`
+	Duplicates []Duplicate `json:"duplicates,omitempty"`
+}
+
+// String is a .tra string.
+type String struct {
+	ID     string `json:"id"` // without the @
+	Text   string `json:"text"`
+	Female string `json:"female,omitempty"` // female variant, if it differs
+	Pos    Pos    `json:"pos"`
+}
+
+// Duplicate is an @id defined twice in a .tra file; the second definition
+// is the one in Strings.
+type Duplicate struct {
+	ID        string `json:"id"`
+	First     Pos    `json:"first"`
+	FirstText string `json:"firstText"`
+	Second    Pos    `json:"second"`
+}
+
+// Lines of WriteNDJSON. Each has a "type": the first line is a "schema"
+// header, then come the "occurrence"s of each .d file, with the .d key in
+// "d", and the "string"s of each .tra file, with the .tra key in "tra".
+type (
+	schemaRecord struct {
+		Type   string `json:"type"`
+		Schema int    `json:"schema"`
+	}
+	occurrenceRecord struct {
+		Type string `json:"type"`
+		D    string `json:"d"`
+		Occurrence
+	}
+	stringRecord struct {
+		Type string `json:"type"`
+		Tra  string `json:"tra"`
+		String
+	}
+)
+
+// Build converts the parser output to the JSON model, sorted by file.
+func Build(dialogs d.DByFile, tras tra.TraByFile) Document {
+	doc := Document{Schema: Schema, Dialogs: []Dialog{}, Tras: []Tra{}}
+	for _, k := range slices.Sorted(maps.Keys(dialogs)) {
+		dlg := Dialog{File: k, Occurrences: []Occurrence{}}
+		for _, o := range dialogs[k] {
+			dlg.Occurrences = append(dlg.Occurrences, occurrenceOf(o))
+		}
+		doc.Dialogs = append(doc.Dialogs, dlg)
+	}
+	for _, k := range slices.Sorted(maps.Keys(tras)) {
+		doc.Tras = append(doc.Tras, traOf(k, tras[k]))
+	}
+	return doc
+}
+
+func occurrenceOf(o d.TextOccurrence) Occurrence {
+	out := Occurrence{
+		Kind:      string(o.Kind),
+		TraID:     o.TraID,
+		StrRef:    o.StrRef,
+		Speaker:   o.SpeakerDlg,
+		Dialog:    o.Dialog,
+		State:     o.State,
+		Reply:     o.ReplyIndex,
+		Condition: o.Condition,
+		Notes:     o.Notes,
+		Pos:       posOf(o.Pos),
+		Block:     o.Block,
+		Branch:    o.Branch,
+		Patch:     o.Patch,
+	}
+	if o.ToType != "" {
+		out.Goto = &Goto{Type: o.ToType}
+		if o.ToDlg != nil {
+			out.Goto.Dialog = *o.ToDlg
+		}
+		if o.ToState != nil {
+			out.Goto.State = *o.ToState
+		}
+	}
+	if ij := o.Interject; ij != nil {
+		out.Interject = &Interject{Keyword: ij.Keyword, Dialog: ij.Dlg, State: ij.State, Var: ij.Var, CopyTrans: ij.CopyTrans}
+	}
+	if o.Extend != nil {
+		out.Extend = &Extend{States: o.Extend.States, Position: o.Extend.Position}
+	}
+	return out
+}
+
+func traOf(k string, t tra.Tra) Tra {
+	out := Tra{File: k, Encoding: t.Encoding, Strings: []String{}}
+	for _, id := range t.IDs() {
+		s := String{ID: id, Text: t.Texts[id], Pos: posOf(t.Pos[id])}
+		if t.HasFemale(id) {
+			s.Female = t.Female[id]
+		}
+		out.Strings = append(out.Strings, s)
+	}
+	for _, dup := range t.Duplicates {
+		out.Duplicates = append(out.Duplicates, Duplicate{ID: dup.ID, First: posOf(dup.First), FirstText: dup.FirstText, Second: posOf(dup.Second)})
+	}
+	return out
+}
+
+func posOf(p helpers.Pos) Pos {
+	return Pos{File: p.File, Line: p.Line, Col: p.Col}
+}
+
+// WriteJSON writes the parser output as one indented JSON Document.
+func WriteJSON(w io.Writer, dialogs d.DByFile, tras tra.TraByFile) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(Build(dialogs, tras))
+}
+
+// WriteNDJSON writes the parser output as newline-delimited JSON: a
+// schema header, then a line per occurrence of each .d file, then one per
+// string of each .tra file.
+func WriteNDJSON(w io.Writer, dialogs d.DByFile, tras tra.TraByFile) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(schemaRecord{Type: "schema", Schema: Schema}); err != nil {
+		return err
+	}
+	for _, k := range slices.Sorted(maps.Keys(dialogs)) {
+		for _, o := range dialogs[k] {
+			if err := enc.Encode(occurrenceRecord{Type: "occurrence", D: k, Occurrence: occurrenceOf(o)}); err != nil {
+				return err
+			}
+		}
+	}
+	for _, k := range slices.Sorted(maps.Keys(tras)) {
+		for _, s := range traOf(k, tras[k]).Strings {
+			if err := enc.Encode(stringRecord{Type: "string", Tra: k, String: s}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package dump
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/d"
+	"github.com/maciejjwojcik/dlg2csv/internal/tra"
+)
+
+const (
+	dSrc = `BEGIN BDNPC
+IF ~Global("Met","LOCALS",0)~ THEN BEGIN hello
+  SAY @1 /* greeting */
+  IF ~~ THEN REPLY @2 EXIT
+  IF ~~ THEN REPLY #100 EXTERN BDOTHER 3
+END
+`
+	traSrc = "@1 = ~Hello, <CHARNAME>.~ ~Hello, my lady.~\n@2 = ~Bye.~\n"
+)
+
+func parse(t *testing.T) (d.DByFile, tra.TraByFile) {
+	t.Helper()
+	occ, err := d.ParseReader(strings.NewReader(dSrc), "bdnpc.d")
+	if err != nil {
+		t.Fatalf("d.ParseReader: %v", err)
+	}
+	tr, err := tra.ParseReader(strings.NewReader(traSrc), "bdnpc.tra")
+	if err != nil {
+		t.Fatalf("tra.ParseReader: %v", err)
+	}
+	return d.DByFile{"bdnpc": occ}, tra.TraByFile{"bdnpc": *tr}
+}
+
+func TestWriteJSON(t *testing.T) {
+	dialogs, tras := parse(t)
+
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, dialogs, tras); err != nil {
+		t.Fatalf("WriteJSON: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`"schema": 1,`,
+		`"condition": "Global(\"Met\",\"LOCALS\",0)"`,
+		`"text": "Hello, <CHARNAME>."`,
+		`"female": "Hello, my lady."`,
+		`"strref": 100,`,
+		`"goto": {
+            "type": "EXTERN",
+            "dialog": "BDOTHER",
+            "state": "3"
+          }`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("output lacks %s:\n%s", want, out)
+		}
+	}
+
+	var got Document
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if want := Build(dialogs, tras); !reflect.DeepEqual(got, want) {
+		t.Fatalf("decoded document differs:\ngot  %+v\nwant %+v", got, want)
+	}
+	if len(got.Dialogs) != 1 || len(got.Dialogs[0].Occurrences) != 3 || len(got.Tras[0].Strings) != 2 {
+		t.Fatalf("unexpected document: %+v", got)
+	}
+}
+
+func TestWriteNDJSON(t *testing.T) {
+	dialogs, tras := parse(t)
+
+	var buf bytes.Buffer
+	if err := WriteNDJSON(&buf, dialogs, tras); err != nil {
+		t.Fatalf("WriteNDJSON: %v", err)
+	}
+
+	var types []string
+	sc := bufio.NewScanner(&buf)
+	for sc.Scan() {
+		var rec map[string]any
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			t.Fatalf("line %q: %v", sc.Text(), err)
+		}
+		types = append(types, rec["type"].(string))
+		switch rec["type"] {
+		case "occurrence":
+			if rec["d"] != "bdnpc" || rec["pos"] == nil {
+				t.Fatalf("occurrence line: %s", sc.Text())
+			}
+		case "string":
+			if rec["tra"] != "bdnpc" || rec["text"] == nil {
+				t.Fatalf("string line: %s", sc.Text())
+			}
+		}
+	}
+	want := []string{"schema", "occurrence", "occurrence", "occurrence", "string", "string"}
+	if !reflect.DeepEqual(types, want) {
+		t.Fatalf("line types = %v, want %v", types, want)
+	}
+}
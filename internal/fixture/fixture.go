@@ -0,0 +1,52 @@
+// Package fixture reads the test fixture shared by the tests of the
+// exchange formats (TMX, XLIFF, PO): testdata/bilingual, a dialog with
+// its English .tra file and a Polish translation.
+package fixture
+
+import (
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/d"
+	"github.com/maciejjwojcik/dlg2csv/internal/tra"
+)
+
+// Dir returns the path of testdata/bilingual.
+func Dir() string {
+	_, thisFile, _, _ := runtime.Caller(0)
+	// internal/fixture -> internal -> repo root
+	return filepath.Join(filepath.Dir(thisFile), "..", "..", "testdata", "bilingual")
+}
+
+// Dialogs returns the occurrences of the dialog, bdnpc.d.
+func Dialogs(t testing.TB) d.DByFile {
+	t.Helper()
+	dialogs, err := d.ParseDir(filepath.Join(Dir(), "dlg"))
+	if err != nil {
+		t.Fatalf("d.ParseDir: %v", err)
+	}
+	return dialogs
+}
+
+// Tra returns the .tra files of a language folder, english or polish.
+func Tra(t testing.TB, lang string) tra.TraByFile {
+	t.Helper()
+	tras, err := tra.ParseDir(filepath.Join(Dir(), "language", lang))
+	if err != nil {
+		t.Fatalf("tra.ParseDir: %v", err)
+	}
+	return tras
+}
+
+// ParseTra parses src as bdnpc.tra, e.g. a changed version of the
+// English one.
+func ParseTra(t testing.TB, src string) tra.TraByFile {
+	t.Helper()
+	tr, err := tra.ParseReader(strings.NewReader(src), "bdnpc.tra")
+	if err != nil {
+		t.Fatalf("tra.ParseReader: %v", err)
+	}
+	return tra.TraByFile{"bdnpc": *tr}
+}
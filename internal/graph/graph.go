@@ -0,0 +1,194 @@
+// Package graph renders the states of a dialogue and the replies between
+// them as a Mermaid flowchart or a Graphviz DOT graph.
+package graph
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/d"
+)
+
+// Exit is the ID of the node transitions ending the dialogue point to.
+const Exit = "EXIT"
+
+// maxLabel is the length labels are cut to, in runes.
+const maxLabel = 40
+
+// Node is a dialogue state.
+type Node struct {
+	ID    string // "DIALOG:state"
+	Label string // the NPC line, if the state is defined by the .d file
+
+	// External is set for states of other dialogs or the base game,
+	// reached by EXTERN or COPY_TRANS but not defined here.
+	External bool
+}
+
+// Edge is a transition, labelled with its reply.
+type Edge struct {
+	From, To string
+	Label    string
+}
+
+// Graph is the state graph of one or more dialogues.
+type Graph struct {
+	Nodes []Node
+	Edges []Edge
+}
+
+// Build creates the graph of the given occurrences, usually those of one
+// .d file. text returns the text shown for a line, e.g. its .tra string.
+// Transitions without a reply text aren't part of the parser output and
+// are missing from the graph.
+func Build(occ []d.TextOccurrence, text func(d.TextOccurrence) string) Graph {
+	var g Graph
+	index := map[string]int{}
+
+	node := func(id string) int {
+		if i, ok := index[id]; ok {
+			return i
+		}
+		index[id] = len(g.Nodes)
+		g.Nodes = append(g.Nodes, Node{ID: id, External: true})
+		return index[id]
+	}
+
+	for _, o := range occ {
+		from := stateID(o.Dialog, o.State)
+		if o.Kind == d.KindNPC {
+			n := &g.Nodes[node(from)]
+			if n.External {
+				n.External = false
+				n.Label = text(o)
+			}
+		}
+
+		to := ""
+		switch strings.ToUpper(o.ToType) {
+		case "EXIT":
+			to = Exit
+		case "GOTO", "EXTERN", "COPY_TRANS":
+			if o.ToState == nil {
+				continue
+			}
+			dlg := o.Dialog
+			if o.ToDlg != nil {
+				dlg = *o.ToDlg
+			}
+			to = stateID(dlg, *o.ToState)
+		default:
+			continue
+		}
+		node(from)
+		if to != Exit {
+			node(to)
+		}
+
+		label := ""
+		if o.Kind == d.KindPC {
+			label = text(o)
+		}
+		if strings.EqualFold(o.ToType, "COPY_TRANS") {
+			label = strings.TrimSpace("COPY_TRANS " + label)
+		}
+		g.Edges = append(g.Edges, Edge{From: from, To: to, Label: label})
+	}
+	return g
+}
+
+func stateID(dlg, state string) string {
+	return strings.ToUpper(dlg) + ":" + state
+}
+
+// Mermaid writes the graph as a Mermaid flowchart.
+func (g Graph) Mermaid(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("flowchart TD\n")
+
+	ids := map[string]string{Exit: "exit"}
+	for i, n := range g.Nodes {
+		ids[n.ID] = fmt.Sprintf("n%d", i)
+		label := n.ID
+		if n.Label != "" {
+			label += "<br/>" + mermaidEscape(shorten(n.Label))
+		}
+		if n.External {
+			fmt.Fprintf(&b, "  %s[/\"%s\"/]\n", ids[n.ID], label)
+		} else {
+			fmt.Fprintf(&b, "  %s[\"%s\"]\n", ids[n.ID], label)
+		}
+	}
+	if g.hasExit() {
+		b.WriteString("  exit((EXIT))\n")
+	}
+	for _, e := range g.Edges {
+		if e.Label == "" {
+			fmt.Fprintf(&b, "  %s --> %s\n", ids[e.From], ids[e.To])
+			continue
+		}
+		fmt.Fprintf(&b, "  %s -->|\"%s\"| %s\n", ids[e.From], mermaidEscape(shorten(e.Label)), ids[e.To])
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// DOT writes the graph in the Graphviz DOT language.
+func (g Graph) DOT(w io.Writer, name string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "digraph %s {\n  node [shape=box];\n", dotQuote(name))
+	for _, n := range g.Nodes {
+		label := n.ID
+		if n.Label != "" {
+			label += "\n" + shorten(n.Label)
+		}
+		attrs := ""
+		if n.External {
+			attrs = ", style=dashed"
+		}
+		fmt.Fprintf(&b, "  %s [label=%s%s];\n", dotQuote(n.ID), dotQuote(label), attrs)
+	}
+	if g.hasExit() {
+		fmt.Fprintf(&b, "  %s [shape=doublecircle];\n", dotQuote(Exit))
+	}
+	for _, e := range g.Edges {
+		fmt.Fprintf(&b, "  %s -> %s", dotQuote(e.From), dotQuote(e.To))
+		if e.Label != "" {
+			fmt.Fprintf(&b, " [label=%s]", dotQuote(shorten(e.Label)))
+		}
+		b.WriteString(";\n")
+	}
+	b.WriteString("}\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (g Graph) hasExit() bool {
+	for _, e := range g.Edges {
+		if e.To == Exit {
+			return true
+		}
+	}
+	return false
+}
+
+// shorten cuts s to maxLabel runes on a single line.
+func shorten(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > maxLabel {
+		return string(r[:maxLabel-1]) + "…"
+	}
+	return s
+}
+
+// mermaidEscape replaces characters that end a quoted Mermaid label.
+func mermaidEscape(s string) string {
+	return strings.NewReplacer(`"`, "#quot;", "<", "#lt;", ">", "#gt;").Replace(s)
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
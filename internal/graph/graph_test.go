@@ -0,0 +1,95 @@
+package graph
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/d"
+)
+
+const testD = `BEGIN BDNPC
+
+IF ~~ hello
+  SAY @1
+  IF ~~ THEN REPLY @2 GOTO bye
+  IF ~~ THEN REPLY @3 EXTERN JAHEIJ 12
+END
+
+IF ~~ bye
+  SAY @4
+  IF ~~ THEN REPLY @5 EXIT
+END
+`
+
+func build(t *testing.T) Graph {
+	t.Helper()
+	occ, err := d.ParseReader(strings.NewReader(testD), "bdnpc.d")
+	if err != nil {
+		t.Fatalf("ParseReader: %v", err)
+	}
+	texts := map[int]string{1: "Hello \"stranger\".", 2: "Goodbye.", 3: "Ask Jaheira.", 4: "Farewell.", 5: "Leave."}
+	return Build(occ, func(o d.TextOccurrence) string { return texts[*o.TraID] })
+}
+
+func TestBuild(t *testing.T) {
+	g := build(t)
+
+	if len(g.Nodes) != 3 || len(g.Edges) != 3 {
+		t.Fatalf("unexpected graph: %+v", g)
+	}
+	if g.Nodes[0].ID != "BDNPC:hello" || g.Nodes[0].Label != `Hello "stranger".` || g.Nodes[0].External {
+		t.Fatalf("unexpected first node: %+v", g.Nodes[0])
+	}
+	if n := g.Nodes[2]; n.ID != "JAHEIJ:12" || !n.External {
+		t.Fatalf("EXTERN target should be external: %+v", n)
+	}
+	if e := g.Edges[2]; e.From != "BDNPC:bye" || e.To != Exit || e.Label != "Leave." {
+		t.Fatalf("unexpected exit edge: %+v", e)
+	}
+}
+
+func TestMermaid(t *testing.T) {
+	var b strings.Builder
+	if err := build(t).Mermaid(&b); err != nil {
+		t.Fatalf("Mermaid: %v", err)
+	}
+	want := `flowchart TD
+  n0["BDNPC:hello<br/>Hello #quot;stranger#quot;."]
+  n1["BDNPC:bye<br/>Farewell."]
+  n2[/"JAHEIJ:12"/]
+  exit((EXIT))
+  n0 -->|"Goodbye."| n1
+  n0 -->|"Ask Jaheira."| n2
+  n1 -->|"Leave."| exit
+`
+	if b.String() != want {
+		t.Fatalf("Mermaid:\n got:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestDOT(t *testing.T) {
+	var b strings.Builder
+	if err := build(t).DOT(&b, "bdnpc"); err != nil {
+		t.Fatalf("DOT: %v", err)
+	}
+	for _, want := range []string{
+		`digraph "bdnpc" {`,
+		`"BDNPC:hello" [label="BDNPC:hello\nHello \"stranger\"."];`,
+		`"JAHEIJ:12" [label="JAHEIJ:12", style=dashed];`,
+		`"BDNPC:bye" -> "EXIT" [label="Leave."];`,
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Fatalf("DOT output lacks %q:\n%s", want, b.String())
+		}
+	}
+}
+
+func TestShorten(t *testing.T) {
+	long := strings.Repeat("word ", 20)
+	if got := []rune(shorten(long)); len(got) != maxLabel || got[len(got)-1] != '…' {
+		t.Fatalf("shorten: %q", string(got))
+	}
+	if got := shorten("two\nlines"); got != "two lines" {
+		t.Fatalf("shorten: %q", got)
+	}
+}
@@ -0,0 +1,128 @@
+// Package mapping pairs .d files with the .tra files they use when their
+// base names don't match, e.g. bdnpc.d using bdnpc_dlg.tra or a shared
+// dialogs.tra.
+package mapping
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	helpers "github.com/maciejjwojcik/dlg2csv/internal/utils"
+)
+
+// Map maps a .d file to the .tra files it uses, in load order (later ones
+// override earlier ones). Keys and values are lower-case base names, as
+// used by d.ParseDir and tra.ParseDir.
+type Map map[string][]string
+
+// Key normalizes a file name or path to a map key: "dlg/BDNPC.d" -> "bdnpc".
+func Key(name string) string {
+	return strings.ToLower(helpers.BaseKey(filepath.Base(filepath.ToSlash(name))))
+}
+
+// ParseFile reads a mapping file.
+func ParseFile(path string) (Map, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			return
+		}
+	}()
+
+	return ParseReader(f, filepath.Base(path))
+}
+
+// ParseReader reads a mapping file, one .d file per line:
+//
+//	# .d file   .tra files
+//	bdnpc.d   = bdnpc_dlg.tra
+//	bdnpcj.d  = dialogs.tra, bdnpcj.tra
+//
+// .tra files may be separated by commas or spaces. # starts a comment at
+// the start of a line or after whitespace, so names like ac#npc.d work.
+func ParseReader(r io.Reader, fileName string) (Map, error) {
+	m := Map{}
+	sc := bufio.NewScanner(r)
+	lineNo := 0
+	for sc.Scan() {
+		lineNo++
+		line := stripComment(sc.Text())
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if err := m.Set(line); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", fileName, lineNo, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// stripComment cuts line at the first # that starts it or follows
+// whitespace.
+func stripComment(line string) string {
+	for i := 0; i < len(line); i++ {
+		if line[i] == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t') {
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// Set adds an entry "file.d=a.tra,b.tra". Together with String it makes Map
+// a flag.Value, so -map can be repeated.
+func (m Map) Set(entry string) error {
+	dPart, traPart, ok := strings.Cut(entry, "=")
+	dKey := Key(strings.TrimSpace(dPart))
+	if !ok || dKey == "" {
+		return fmt.Errorf("invalid mapping %q, want file.d=file.tra[,file.tra...]", strings.TrimSpace(entry))
+	}
+
+	var tras []string
+	for _, t := range strings.FieldsFunc(traPart, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+		tras = append(tras, Key(t))
+	}
+	if len(tras) == 0 {
+		return fmt.Errorf("mapping for %s lists no .tra files", dKey)
+	}
+
+	m[dKey] = tras
+	return nil
+}
+
+// String renders the map in the flag syntax, sorted by .d file.
+func (m Map) String() string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	entries := make([]string, 0, len(keys))
+	for _, k := range keys {
+		entries = append(entries, k+"="+strings.Join(m[k], ","))
+	}
+	return strings.Join(entries, " ")
+}
+
+// Merge returns the entries of m overridden by those of over.
+func (m Map) Merge(over Map) Map {
+	out := make(Map, len(m)+len(over))
+	for k, v := range m {
+		out[k] = v
+	}
+	for k, v := range over {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,67 @@
+package mapping
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseReader(t *testing.T) {
+	input := `# .d file   .tra files
+bdnpc.d   = bdnpc_dlg.tra
+BDNPCJ.D  = dialogs.tra, bdnpcj.tra   # shared + own
+
+dlg/banter.d=lang/english/dialogs.tra
+ac#npc.d = ac#npc.tra, ac#shared.tra	# prefixed names
+`
+	m, err := ParseReader(strings.NewReader(input), "map.txt")
+	if err != nil {
+		t.Fatalf("ParseReader error: %v", err)
+	}
+
+	want := Map{
+		"bdnpc":  {"bdnpc_dlg"},
+		"bdnpcj": {"dialogs", "bdnpcj"},
+		"banter": {"dialogs"},
+		"ac#npc": {"ac#npc", "ac#shared"},
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Fatalf("got %v, want %v", m, want)
+	}
+}
+
+func TestParseReader_Errors(t *testing.T) {
+	tests := map[string]string{
+		"missing =":    "bdnpc.d bdnpc.tra\n",
+		"no tra files": "bdnpc.d =\n",
+		"no d file":    "= bdnpc.tra\n",
+	}
+	for name, input := range tests {
+		_, err := ParseReader(strings.NewReader(input), "map.txt")
+		if err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+		if !strings.HasPrefix(err.Error(), "map.txt:1:") {
+			t.Fatalf("%s: error should carry the position, got %v", name, err)
+		}
+	}
+}
+
+func TestMap_SetStringMerge(t *testing.T) {
+	m := Map{}
+	if err := m.Set("b.d=x.tra,y.tra"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := m.Set("a.d=a_dlg.tra"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if got := m.String(); got != "a=a_dlg b=x,y" {
+		t.Fatalf("String() = %q", got)
+	}
+
+	merged := Map{"a": {"old"}, "c": {"c"}}.Merge(m)
+	want := Map{"a": {"a_dlg"}, "b": {"x", "y"}, "c": {"c"}}
+	if !reflect.DeepEqual(merged, want) {
+		t.Fatalf("Merge: got %v, want %v", merged, want)
+	}
+}
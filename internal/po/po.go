@@ -0,0 +1,354 @@
+// Package po reads and writes gettext PO and POT files, for translation
+// platforms such as Weblate or Pootle. Each variant of a .tra string is
+// an entry with msgctxt "file:@id" (with "/female" added for female
+// variants); extracted comments give the speaker and state of the lines
+// using it and references point at those lines in the .d files.
+package po
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/csv"
+	"github.com/maciejjwojcik/dlg2csv/internal/d"
+	"github.com/maciejjwojcik/dlg2csv/internal/tra"
+)
+
+const femaleSuffix = "/female"
+
+// bom may start a PO file saved by Windows editors.
+const bom = "\ufeff"
+
+// Entry is a message of a PO file.
+type Entry struct {
+	Comments   []string // extracted comments, "#. ..."
+	References []string // "#: file:line"
+	Flags      []string // "#, fuzzy"
+
+	Context string // msgctxt, e.g. "bdnpc.tra:@12"
+	ID      string // msgid, the source text
+	Str     string // msgstr, empty if not translated
+
+	// Line is where the entry starts in a file read by Read.
+	Line int
+}
+
+// Fuzzy reports whether the translation is marked as needing review;
+// gettext doesn't use fuzzy translations.
+func (e Entry) Fuzzy() bool {
+	return slices.Contains(e.Flags, "fuzzy")
+}
+
+// File is the content of a PO or POT file.
+type File struct {
+	Language string // e.g. "pl"; empty for a template
+	Entries  []Entry
+}
+
+// Context returns the msgctxt of a variant of a .tra string,
+// e.g. "bdnpc.tra:@12" or "bdnpc.tra:@12/female".
+func Context(file, id string, female bool) string {
+	ctx := file + ":@" + id
+	if female {
+		ctx += femaleSuffix
+	}
+	return ctx
+}
+
+// parseContext splits a msgctxt made by Context into the .tra file base
+// name, as in tra.TraByFile, the @id and the variant.
+func parseContext(ctx string) (key, id string, female, ok bool) {
+	ctx, female = strings.CutSuffix(ctx, femaleSuffix)
+	i := strings.LastIndex(ctx, ":@")
+	if i < 0 {
+		return "", "", false, false
+	}
+	file, id := ctx[:i], ctx[i+2:]
+	key = strings.ToLower(strings.TrimSuffix(file, filepath.Ext(file)))
+	return key, id, female, file != "" && id != ""
+}
+
+// Entries returns an entry for each string of source, and one for its
+// female variant if it has one in the source or the translation, with
+// comments on the lines using it as returned by csv.Usages. The msgstrs
+// are filled from translations, which may be nil for a template.
+func Entries(source tra.TraByFile, usages map[string]map[string][]d.TextOccurrence, translations csv.Translations) []Entry {
+	var entries []Entry
+	for _, k := range slices.Sorted(maps.Keys(source)) {
+		s := source[k]
+		for _, id := range s.IDs() {
+			male := Entry{Context: Context(k+".tra", id, false), ID: s.Texts[id]}
+			for _, o := range usages[k][id] {
+				male.Comments = append(male.Comments, commentOf(o))
+				if o.Pos.File != "" {
+					male.References = append(male.References, o.Pos.String())
+				}
+			}
+
+			t := translations[k][id]
+			male.Str = t.Male
+			entries = append(entries, male)
+			if s.HasFemale(id) || t.Female != "" {
+				female := male
+				female.Context = Context(k+".tra", id, true)
+				female.ID = s.FemaleSource(id)
+				female.Str = t.Female
+				entries = append(entries, female)
+			}
+		}
+	}
+	return entries
+}
+
+// commentOf describes a line using a string, e.g. "BDNPC:hello, PC reply,
+// goes to EXIT".
+func commentOf(o d.TextOccurrence) string {
+	parts := []string{o.Dialog + ":" + o.State}
+	switch o.Kind {
+	case d.KindPC:
+		parts = append(parts, "PC reply")
+	case d.KindJournal:
+		parts = append(parts, "journal entry")
+	default:
+		if o.SpeakerDlg != "" {
+			parts = append(parts, "spoken by "+o.SpeakerDlg)
+		}
+	}
+	if o.Condition != "" {
+		parts = append(parts, "if "+o.Condition)
+	}
+	if to := csv.FormatGoto(o); to != "" {
+		parts = append(parts, "goes to "+to)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Translations returns the translated entries with a msgctxt naming a
+// string that still has the same source text in source, e.g. to write
+// translated .tra files, and the number of translated entries skipped as
+// their string changed or is gone, or as they are a female variant
+// without the male one. Fuzzy entries are not translated. The positions
+// of the translations are lines of the PO file, name.
+func (f File) Translations(source tra.TraByFile, name string) (csv.Translations, int) {
+	out := csv.Translations{}
+	skipped := 0
+	for _, e := range f.Entries {
+		if e.Str == "" || e.Fuzzy() {
+			continue
+		}
+		k, id, female, ok := parseContext(e.Context)
+		if !ok {
+			continue
+		}
+		s, ok := source[k]
+		want, defined := s.Texts[id]
+		if female {
+			want = s.FemaleSource(id)
+		}
+		if !ok || !defined || want != e.ID {
+			skipped++
+			continue
+		}
+
+		if out[k] == nil {
+			out[k] = map[string]csv.Translation{}
+		}
+		t := out[k][id]
+		if female {
+			t.Female = e.Str
+		} else {
+			t.Male = e.Str
+			t.Pos.File, t.Pos.Line = name, e.Line
+		}
+		out[k][id] = t
+	}
+	return out, skipped + out.DropFemaleOnly()
+}
+
+// Write writes f as a PO file, or a POT template if f has no language.
+func Write(w io.Writer, f File) error {
+	bw := bufio.NewWriter(w)
+
+	header := "Project-Id-Version: \n" +
+		"Language: " + f.Language + "\n" +
+		"MIME-Version: 1.0\n" +
+		"Content-Type: text/plain; charset=UTF-8\n" +
+		"Content-Transfer-Encoding: 8bit\n" +
+		"X-Generator: dlg2csv\n"
+	writeString(bw, "msgid", "")
+	writeString(bw, "msgstr", header)
+
+	for _, e := range f.Entries {
+		bw.WriteString("\n")
+		for _, c := range e.Comments {
+			fmt.Fprintf(bw, "#. %s\n", c)
+		}
+		for _, r := range e.References {
+			fmt.Fprintf(bw, "#: %s\n", r)
+		}
+		if len(e.Flags) > 0 {
+			fmt.Fprintf(bw, "#, %s\n", strings.Join(e.Flags, ", "))
+		}
+		writeString(bw, "msgctxt", e.Context)
+		writeString(bw, "msgid", e.ID)
+		writeString(bw, "msgstr", e.Str)
+	}
+	return bw.Flush()
+}
+
+// writeString writes a keyword and its quoted string, splitting strings
+// with line breaks into one quoted line each, as gettext tools do.
+func writeString(w *bufio.Writer, keyword, s string) {
+	if !strings.Contains(s, "\n") || s == "\n" {
+		fmt.Fprintf(w, "%s %s\n", keyword, quote(s))
+		return
+	}
+	fmt.Fprintf(w, "%s \"\"\n", keyword)
+	for _, line := range strings.SplitAfter(s, "\n") {
+		if line != "" {
+			fmt.Fprintf(w, "%s\n", quote(line))
+		}
+	}
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`)
+
+func quote(s string) string {
+	return `"` + escaper.Replace(s) + `"`
+}
+
+// Read reads a PO or POT file. Obsolete entries ("#~") and the header are
+// skipped; of plural forms only msgstr[0] is kept.
+func Read(r io.Reader) (File, error) {
+	var (
+		f       File
+		cur     Entry
+		field   *string // the string continued by quoted lines
+		unused  string  // msgid_plural and msgstr[n] for n > 0
+		started bool    // cur has a comment or keyword
+		hasStr  bool    // cur has its msgstr, the next keyword starts a new entry
+		line    int
+	)
+	flush := func() {
+		if started && cur.ID == "" && cur.Context == "" {
+			f.Language = headerField(cur.Str, "Language")
+		} else if started {
+			f.Entries = append(f.Entries, cur)
+		}
+		cur, field, started, hasStr = Entry{}, nil, false, false
+	}
+	next := func() {
+		if hasStr {
+			flush()
+		}
+		if !started {
+			cur.Line, started = line, true
+		}
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line++
+		text := strings.TrimSpace(sc.Text())
+		if line == 1 {
+			text = strings.TrimPrefix(text, bom)
+		}
+
+		switch {
+		case text == "" || strings.HasPrefix(text, "#~"):
+			// blank line or obsolete entry
+		case strings.HasPrefix(text, "#"):
+			next()
+			switch {
+			case strings.HasPrefix(text, "#."):
+				cur.Comments = append(cur.Comments, strings.TrimSpace(text[2:]))
+			case strings.HasPrefix(text, "#:"):
+				cur.References = append(cur.References, strings.Fields(text[2:])...)
+			case strings.HasPrefix(text, "#,"):
+				for _, flag := range strings.Split(text[2:], ",") {
+					cur.Flags = append(cur.Flags, strings.TrimSpace(flag))
+				}
+			}
+		case strings.HasPrefix(text, `"`):
+			if field == nil {
+				return File{}, fmt.Errorf("read PO: line %d: string outside of an entry", line)
+			}
+			s, err := unquote(text)
+			if err != nil {
+				return File{}, fmt.Errorf("read PO: line %d: %w", line, err)
+			}
+			*field += s
+		default:
+			keyword, rest, _ := strings.Cut(text, " ")
+			s, err := unquote(strings.TrimSpace(rest))
+			if err != nil {
+				return File{}, fmt.Errorf("read PO: line %d: %w", line, err)
+			}
+			switch {
+			case keyword == "msgctxt":
+				next()
+				cur.Context, field = s, &cur.Context
+			case keyword == "msgid":
+				next()
+				cur.ID, field = s, &cur.ID
+			case keyword == "msgstr" || keyword == "msgstr[0]":
+				cur.Str, field, hasStr = s, &cur.Str, true
+			case keyword == "msgid_plural" || strings.HasPrefix(keyword, "msgstr["):
+				unused, field = s, &unused
+			default:
+				return File{}, fmt.Errorf("read PO: line %d: unknown keyword %q", line, keyword)
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return File{}, fmt.Errorf("read PO: %w", err)
+	}
+	flush()
+	return f, nil
+}
+
+// unquote decodes a quoted PO string.
+func unquote(s string) (string, error) {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return "", fmt.Errorf("expected a quoted string, got %s", s)
+	}
+	s = s[1 : len(s)-1]
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteByte('\n')
+		case 't':
+			b.WriteByte('\t')
+		case 'r':
+			b.WriteByte('\r')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// headerField returns a field of the header entry, e.g. "Language".
+func headerField(header, name string) string {
+	for _, line := range strings.Split(header, "\n") {
+		if k, v, ok := strings.Cut(line, ":"); ok && strings.EqualFold(strings.TrimSpace(k), name) {
+			return strings.TrimSpace(v)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,176 @@
+package po
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/csv"
+	"github.com/maciejjwojcik/dlg2csv/internal/fixture"
+	helpers "github.com/maciejjwojcik/dlg2csv/internal/utils"
+)
+
+func TestWriteRead_RoundTrip(t *testing.T) {
+	source := fixture.Tra(t, "english")
+	translations := csv.TraTranslations(source, fixture.Tra(t, "polish"))
+
+	f := File{Language: "pl", Entries: Entries(source, csv.Usages(fixture.Dialogs(t), source, nil), translations)}
+	if len(f.Entries) != 4 {
+		t.Fatalf("expected 4 entries (@1 has a female variant), got %+v", f.Entries)
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, f); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`"Language: pl\n"`,
+		"#. BDNPC:hello, spoken by BDNPC, if Global(\"Met\",\"LOCALS\",0)\n#: bdnpc.d:4\nmsgctxt \"bdnpc.tra:@1\"\nmsgid \"Ready, <CHARNAME>?\"\nmsgstr \"Gotowy, <CHARNAME>?\"\n",
+		"msgctxt \"bdnpc.tra:@1/female\"\nmsgid \"Ready, my lady?\"\nmsgstr \"Gotowa, <CHARNAME>?\"\n",
+		"#. BDNPC:hello, PC reply, goes to hello\n",
+		"msgctxt \"bdnpc.tra:@3\"\nmsgid \"Sword\"\nmsgstr \"\"\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("output lacks %q:\n%s", want, out)
+		}
+	}
+
+	got, err := Read(strings.NewReader(out))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	for i := range got.Entries {
+		got.Entries[i].Line = 0
+	}
+	if !reflect.DeepEqual(got, f) {
+		t.Fatalf("round trip:\ngot  %+v\nwant %+v", got, f)
+	}
+}
+
+func TestWrite_Escapes(t *testing.T) {
+	f := File{Entries: []Entry{
+		{Context: "bdnpc.tra:@1", ID: "He said \"go\".\nC:\\mod\tnow.\n", Str: "Powiedział \"idź\".\nC:\\mod\tteraz.\n"},
+		{Context: "bdnpc.tra:@2", ID: "\n"},
+	}}
+	var buf bytes.Buffer
+	if err := Write(&buf, f); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		// a line per line break, as gettext tools write them
+		"msgid \"\"\n\"He said \\\"go\\\".\\n\"\n\"C:\\\\mod\\tnow.\\n\"\n",
+		"msgstr \"\"\n\"Powiedział \\\"idź\\\".\\n\"\n\"C:\\\\mod\\tteraz.\\n\"\n",
+		"msgid \"\\n\"\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("output lacks %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestRead_MultiLine(t *testing.T) {
+	const src = `msgctxt "bdnpc.tra:@1"
+msgid ""
+"He said \"go\".\n"
+"Now."
+msgstr "Powiedział "
+"\"idź\".\n"
+""
+"Teraz\t\\o/"
+
+msgctxt "bdnpc.tra:@2"
+msgid "Bye."
+msgstr[0] "Pa."
+msgstr[1] "Pa pa."
+`
+	f, err := Read(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	want := []Entry{
+		{Context: "bdnpc.tra:@1", ID: "He said \"go\".\nNow.", Str: "Powiedział \"idź\".\nTeraz\t\\o/", Line: 1},
+		{Context: "bdnpc.tra:@2", ID: "Bye.", Str: "Pa.", Line: 10},
+	}
+	if !reflect.DeepEqual(f.Entries, want) {
+		t.Fatalf("Read:\ngot  %+v\nwant %+v", f.Entries, want)
+	}
+}
+
+func TestFile_Translations(t *testing.T) {
+	source := fixture.ParseTra(t, "@1 = ~Ready?~ ~Ready, my lady?~\n@2 = ~Bye.~\n@3 = ~Sword~\n@4 = ~Shield~\n")
+	const src = `# translator comment
+msgid ""
+msgstr ""
+"Language: pl\n"
+
+#: bdnpc.d:3
+msgctxt "bdnpc.tra:@1"
+msgid "Ready?"
+msgstr "Gotowy?"
+
+msgctxt "bdnpc.tra:@1/female"
+msgid "Ready, my lady?"
+msgstr "Gotowa?"
+
+#, fuzzy
+msgctxt "bdnpc.tra:@2"
+msgid "Bye."
+msgstr "Pa."
+
+msgctxt "BDNPC.TRA:@3"
+msgid "Old sword"
+msgstr "Stary miecz"
+msgctxt "bdnpc.tra:@4"
+msgid "Shield"
+msgstr ""
+"Tar"
+"cza"
+
+#~ msgctxt "bdnpc.tra:@9"
+#~ msgid "Gone"
+#~ msgstr "Nie ma"
+`
+	f, err := Read(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if f.Language != "pl" || len(f.Entries) != 5 {
+		t.Fatalf("Read = %+v", f)
+	}
+
+	got, stale := f.Translations(source, "pl.po")
+	if stale != 1 {
+		t.Fatalf("stale = %d, want 1 (@3 changed)", stale)
+	}
+	want := csv.Translations{"bdnpc": {
+		"1": {Male: "Gotowy?", Female: "Gotowa?", Pos: helpers.Pos{File: "pl.po", Line: 6}},
+		"4": {Male: "Tarcza", Pos: helpers.Pos{File: "pl.po", Line: 23}},
+	}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Translations:\ngot  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestFile_Translations_FemaleOnly(t *testing.T) {
+	f := File{Entries: []Entry{{Context: "bdnpc.tra:@1/female", ID: "Ready, my lady?", Str: "Gotowa?"}}}
+
+	got, skipped := f.Translations(fixture.Tra(t, "english"), "pl.po")
+	if len(got) != 0 || skipped != 1 {
+		t.Fatalf("a female variant alone should be skipped, got %+v (skipped %d)", got, skipped)
+	}
+}
+
+func TestRead_Errors(t *testing.T) {
+	for _, src := range []string{
+		"\"stray\"\n",
+		"msgid \"a\"\nmsgstr \"b\n",
+		"msgfoo \"a\"\n",
+	} {
+		if _, err := Read(strings.NewReader(src)); err == nil {
+			t.Errorf("Read(%q): expected an error", src)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+// Package script renders dialogues as a screenplay for proofreading, in
+// Markdown or HTML: the NPC lines of each state under its heading, then
+// the replies as numbered options linking to the states they lead to.
+package script
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/d"
+)
+
+// Target is where a line or reply leads.
+type Target struct {
+	Type   string // EXIT, GOTO, EXTERN or COPY_TRANS; empty if none
+	Dialog string
+	State  string
+}
+
+// Line is an NPC line, or a journal entry.
+type Line struct {
+	Speaker   string // speaking dialog; JOURNAL for journal entries
+	Text      string
+	Condition string
+
+	// Interject describes the INTERJECT block of the line, e.g.
+	// "INTERJECT_COPY_TRANS BDTARGET:5".
+	Interject string
+	Target    Target // set for CHAIN and INTERJECT lines ending the block
+}
+
+// Reply is a PC reply.
+type Reply struct {
+	Text      string
+	Condition string
+	Target    Target
+	Journal   []string // journal entries added by the reply
+}
+
+// State is a dialogue state, with its lines in file order.
+type State struct {
+	Dialog  string
+	Name    string
+	Lines   []Line
+	Replies []Reply
+}
+
+// Dialog is the states of a dialog, in order of appearance.
+type Dialog struct {
+	Name   string
+	States []*State
+}
+
+// Script is one or more dialogues.
+type Script struct {
+	Dialogs []*Dialog
+}
+
+// Build groups the occurrences, usually those of one .d file, by dialog
+// and state. text returns the text shown for a line, e.g. its .tra string.
+func Build(occ []d.TextOccurrence, text func(d.TextOccurrence) string) Script {
+	var s Script
+	dialogs := map[string]*Dialog{}
+	states := map[string]*State{}
+
+	for _, o := range occ {
+		dlgName := strings.ToUpper(o.Dialog)
+		dlg, ok := dialogs[dlgName]
+		if !ok {
+			dlg = &Dialog{Name: o.Dialog}
+			dialogs[dlgName] = dlg
+			s.Dialogs = append(s.Dialogs, dlg)
+		}
+		id := stateID(o.Dialog, o.State)
+		st, ok := states[id]
+		if !ok {
+			st = &State{Dialog: o.Dialog, Name: o.State}
+			states[id] = st
+			dlg.States = append(dlg.States, st)
+		}
+
+		switch o.Kind {
+		case d.KindPC:
+			st.Replies = append(st.Replies, Reply{Text: text(o), Condition: o.Condition, Target: targetOf(o)})
+		case d.KindJournal:
+			// journal entries follow the reply adding them
+			if n := len(st.Replies); n > 0 {
+				st.Replies[n-1].Journal = append(st.Replies[n-1].Journal, text(o))
+			} else {
+				st.Lines = append(st.Lines, Line{Speaker: "JOURNAL", Text: text(o)})
+			}
+		default:
+			l := Line{Speaker: o.SpeakerDlg, Text: text(o), Condition: o.Condition, Target: targetOf(o)}
+			if ij := o.Interject; ij != nil {
+				l.Interject = fmt.Sprintf("%s %s:%s", ij.Keyword, ij.Dlg, ij.State)
+			}
+			st.Lines = append(st.Lines, l)
+		}
+	}
+	return s
+}
+
+func targetOf(o d.TextOccurrence) Target {
+	t := Target{Type: strings.ToUpper(o.ToType), Dialog: o.Dialog}
+	if o.ToDlg != nil {
+		t.Dialog = *o.ToDlg
+	}
+	if o.ToState != nil {
+		t.State = *o.ToState
+	}
+	return t
+}
+
+func stateID(dlg, state string) string {
+	return strings.ToUpper(dlg) + ":" + state
+}
+
+// anchor is the HTML id of a state, e.g. "bdnpc-hello".
+func anchor(dlg, state string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(dlg + "-" + state) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('-')
+		}
+	}
+	return b.String()
+}
+
+// has reports whether the script has the state, i.e. whether it can be
+// linked to.
+func (s Script) has(dlg, state string) bool {
+	for _, dl := range s.Dialogs {
+		if !strings.EqualFold(dl.Name, dlg) {
+			continue
+		}
+		for _, st := range dl.States {
+			if st.Name == state {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// format holds the escaping and markup of an output format.
+type format struct {
+	escape func(string) string
+	code   func(string) string // a condition
+	link   func(text, anchor string) string
+}
+
+// describe renders where t, of a line or reply of state from, leads,
+// linking to another state the script has; empty if t leads nowhere.
+func (s Script) describe(t Target, from *State, f format) string {
+	state := func() string {
+		label := t.Dialog + " " + t.State
+		self := strings.EqualFold(t.Dialog, from.Dialog) && t.State == from.Name
+		if !self && s.has(t.Dialog, t.State) {
+			return f.link(label, anchor(t.Dialog, t.State))
+		}
+		return f.escape(label)
+	}
+	switch t.Type {
+	case "EXIT":
+		return "EXIT"
+	case "GOTO", "EXTERN":
+		if t.State == "" {
+			return t.Type
+		}
+		return state()
+	case "COPY_TRANS":
+		if t.State == "" {
+			return t.Type
+		}
+		return "replies of " + state()
+	default:
+		return ""
+	}
+}
+
+var markdown = format{
+	escape: func(s string) string {
+		s = mdEscaper.Replace(s)
+		return strings.ReplaceAll(s, "\n", "<br>")
+	},
+	code: func(s string) string {
+		return "`" + strings.Join(strings.Fields(s), " ") + "`"
+	},
+	link: func(text, anchor string) string {
+		return fmt.Sprintf("[%s](#%s)", mdEscaper.Replace(text), anchor)
+	},
+}
+
+var mdEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`, "<", `&lt;`, ">", `&gt;`, "#", `\#`, "|", `\|`)
+
+// Markdown writes the script as Markdown, titled title.
+func (s Script) Markdown(w io.Writer, title string) error {
+	f := markdown
+	blocks := []string{"# " + f.escape(title)}
+	for _, dlg := range s.Dialogs {
+		blocks = append(blocks, "## "+f.escape(dlg.Name))
+		for _, st := range dlg.States {
+			blocks = append(blocks,
+				fmt.Sprintf("<a id=\"%s\"></a>", anchor(st.Dialog, st.Name)),
+				fmt.Sprintf("### %s %s", f.escape(st.Dialog), f.escape(st.Name)))
+
+			for _, l := range st.Lines {
+				var b strings.Builder
+				if l.Interject != "" {
+					fmt.Fprintf(&b, "_%s_  \n", f.escape(l.Interject))
+				}
+				if l.Condition != "" {
+					fmt.Fprintf(&b, "_if_ %s  \n", f.code(l.Condition))
+				}
+				fmt.Fprintf(&b, "**%s:** %s", f.escape(l.Speaker), f.escape(l.Text))
+				if to := s.describe(l.Target, st, f); to != "" {
+					fmt.Fprintf(&b, " → %s", to)
+				}
+				blocks = append(blocks, b.String())
+			}
+
+			var replies []string
+			for i, r := range st.Replies {
+				item := fmt.Sprintf("%d. %s", i+1, f.escape(r.Text))
+				if r.Condition != "" {
+					item += " _if_ " + f.code(r.Condition)
+				}
+				if to := s.describe(r.Target, st, f); to != "" {
+					item += " → " + to
+				}
+				for _, j := range r.Journal {
+					item += "<br>_journal:_ " + f.escape(j)
+				}
+				replies = append(replies, item)
+			}
+			if len(replies) > 0 {
+				blocks = append(blocks, strings.Join(replies, "\n"))
+			}
+		}
+	}
+	_, err := io.WriteString(w, strings.Join(blocks, "\n\n")+"\n")
+	return err
+}
+
+var htmlFormat = format{
+	escape: func(s string) string {
+		return strings.ReplaceAll(html.EscapeString(s), "\n", "<br>")
+	},
+	code: func(s string) string {
+		return `<code>` + html.EscapeString(strings.Join(strings.Fields(s), " ")) + `</code>`
+	},
+	link: func(text, anchor string) string {
+		return fmt.Sprintf(`<a href="#%s">%s</a>`, anchor, html.EscapeString(text))
+	},
+}
+
+const htmlStyle = `body { font-family: Georgia, serif; max-width: 48em; margin: 2em auto; line-height: 1.5; }
+h3 { margin-bottom: 0.3em; }
+.speaker { font-variant: small-caps; font-weight: bold; }
+.muted { color: #888; font-size: 0.9em; }
+.muted code { color: inherit; }
+`
+
+// HTML writes the script as a standalone HTML page, titled title.
+func (s Script) HTML(w io.Writer, title string) error {
+	f := htmlFormat
+	var b strings.Builder
+	fmt.Fprintf(&b, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n<style>\n%s</style>\n</head>\n<body>\n", f.escape(title), htmlStyle)
+	fmt.Fprintf(&b, "<h1>%s</h1>\n", f.escape(title))
+	for _, dlg := range s.Dialogs {
+		fmt.Fprintf(&b, "<h2>%s</h2>\n", f.escape(dlg.Name))
+		for _, st := range dlg.States {
+			fmt.Fprintf(&b, "<section id=\"%s\">\n<h3>%s %s</h3>\n", anchor(st.Dialog, st.Name), f.escape(st.Dialog), f.escape(st.Name))
+			for _, l := range st.Lines {
+				b.WriteString("<p>")
+				if l.Interject != "" {
+					fmt.Fprintf(&b, "<span class=\"muted\">%s</span><br>", f.escape(l.Interject))
+				}
+				if l.Condition != "" {
+					fmt.Fprintf(&b, "<span class=\"muted\">if %s</span><br>", f.code(l.Condition))
+				}
+				fmt.Fprintf(&b, "<span class=\"speaker\">%s:</span> %s", f.escape(l.Speaker), f.escape(l.Text))
+				if to := s.describe(l.Target, st, f); to != "" {
+					fmt.Fprintf(&b, " → %s", to)
+				}
+				b.WriteString("</p>\n")
+			}
+			if len(st.Replies) > 0 {
+				b.WriteString("<ol>\n")
+				for _, r := range st.Replies {
+					fmt.Fprintf(&b, "<li>%s", f.escape(r.Text))
+					if r.Condition != "" {
+						fmt.Fprintf(&b, " <span class=\"muted\">if %s</span>", f.code(r.Condition))
+					}
+					if to := s.describe(r.Target, st, f); to != "" {
+						fmt.Fprintf(&b, " → %s", to)
+					}
+					for _, j := range r.Journal {
+						fmt.Fprintf(&b, "<br><span class=\"muted\">journal:</span> %s", f.escape(j))
+					}
+					b.WriteString("</li>\n")
+				}
+				b.WriteString("</ol>\n")
+			}
+			b.WriteString("</section>\n")
+		}
+	}
+	b.WriteString("</body>\n</html>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
@@ -0,0 +1,93 @@
+package script
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/d"
+)
+
+const testD = `BEGIN BDNPC
+
+IF ~Global("Met","LOCALS",0)~ hello
+  SAY @1
+  IF ~~ THEN REPLY @2 GOTO bye
+  IF ~Class(Player1,MAGE)~ THEN REPLY @3 EXTERN JAHEIJ 12
+END
+
+IF ~~ bye
+  SAY @4
+  IF ~~ THEN REPLY @5 EXIT
+END
+
+INTERJECT_COPY_TRANS JAHEIJ 12 BDJ
+== BDNPC IF ~InParty("BDNPC")~ THEN @6
+END
+`
+
+func build(t *testing.T) Script {
+	t.Helper()
+	occ, err := d.ParseReader(strings.NewReader(testD), "bdnpc.d")
+	if err != nil {
+		t.Fatalf("ParseReader: %v", err)
+	}
+	texts := map[int]string{1: "Hello *stranger*.", 2: "Goodbye.", 3: "Ask <Jaheira>.", 4: "Farewell.\nTake care.", 5: "Leave.", 6: "Not so fast."}
+	return Build(occ, func(o d.TextOccurrence) string { return texts[*o.TraID] })
+}
+
+func TestBuild(t *testing.T) {
+	s := build(t)
+	if len(s.Dialogs) != 2 || s.Dialogs[0].Name != "BDNPC" || s.Dialogs[1].Name != "JAHEIJ" {
+		t.Fatalf("dialogs: %+v", s.Dialogs)
+	}
+	hello := s.Dialogs[0].States[0]
+	if hello.Name != "hello" || len(hello.Lines) != 1 || len(hello.Replies) != 2 {
+		t.Fatalf("hello: %+v", hello)
+	}
+	if r := hello.Replies[1]; r.Condition != "Class(Player1,MAGE)" || r.Target != (Target{"EXTERN", "JAHEIJ", "12"}) {
+		t.Fatalf("second reply: %+v", r)
+	}
+	ij := s.Dialogs[1].States[0].Lines[0]
+	if ij.Speaker != "BDNPC" || ij.Interject != "INTERJECT_COPY_TRANS JAHEIJ:12" {
+		t.Fatalf("interjection: %+v", ij)
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	var buf bytes.Buffer
+	if err := build(t).Markdown(&buf, "bdnpc.d"); err != nil {
+		t.Fatalf("Markdown: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"# bdnpc.d\n\n## BDNPC\n\n<a id=\"bdnpc-hello\"></a>\n\n### BDNPC hello\n\n",
+		"_if_ `Global(\"Met\",\"LOCALS\",0)`  \n**BDNPC:** Hello \\*stranger\\*.\n\n",
+		"1. Goodbye. → [BDNPC bye](#bdnpc-bye)\n2. Ask &lt;Jaheira&gt;. _if_ `Class(Player1,MAGE)` → [JAHEIJ 12](#jaheij-12)\n",
+		"**BDNPC:** Farewell.<br>Take care.\n\n1. Leave. → EXIT\n",
+		"_INTERJECT\\_COPY\\_TRANS JAHEIJ:12_  \n_if_ `InParty(\"BDNPC\")`  \n**BDNPC:** Not so fast. → replies of JAHEIJ 12\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("output lacks %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestHTML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := build(t).HTML(&buf, "bdnpc.d"); err != nil {
+		t.Fatalf("HTML: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"<title>bdnpc.d</title>",
+		`<section id="bdnpc-bye">`,
+		`<li>Ask &lt;Jaheira&gt;. <span class="muted">if <code>Class(Player1,MAGE)</code></span> → <a href="#jaheij-12">JAHEIJ 12</a></li>`,
+		`<span class="speaker">BDNPC:</span> Farewell.<br>Take care.</p>`,
+		"</body>\n</html>\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("output lacks %q:\n%s", want, out)
+		}
+	}
+}
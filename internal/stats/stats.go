@@ -0,0 +1,139 @@
+// Package stats counts what there is to translate in a mod and, given the
+// translated CSV files, how much of it is done.
+package stats
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/d"
+	"github.com/maciejjwojcik/dlg2csv/internal/tra"
+)
+
+// Dialog counts the lines of one .d file.
+type Dialog struct {
+	File    string // .d key, e.g. "bdnpc"
+	States  int    // states with an NPC line
+	NPC     int    // SAY lines, including CHAIN and INTERJECT lines
+	PC      int    // REPLY lines
+	Journal int    // JOURNAL, SOLVED_JOURNAL and UNSOLVED_JOURNAL entries
+	Vanilla int    // lines reusing #strrefs of the base game
+}
+
+// Tra counts the strings of one .tra file.
+type Tra struct {
+	File       string // .tra key, e.g. "bdnpc"
+	Strings    int
+	Words      int
+	Unused     int // strings no .d file uses (they may be used by setup code)
+	Translated int // only counted with Options.Translated
+}
+
+// Report holds the counts per file.
+type Report struct {
+	Dialogs []Dialog
+	Tras    []Tra
+}
+
+// Options configures Compute.
+type Options struct {
+	// Tras maps .d files to their .tra files, as csv.Options.Tras.
+	Tras map[string][]string
+
+	// Translated reports whether @id of a .tra file has a translation.
+	Translated func(traKey, id string) bool
+}
+
+// Compute counts the lines of each .d file and the strings of each .tra.
+func Compute(dialogs d.DByFile, tras tra.TraByFile, opts Options) Report {
+	var r Report
+	used := map[string]map[string]bool{}
+
+	dKeys := make([]string, 0, len(dialogs))
+	for k := range dialogs {
+		dKeys = append(dKeys, k)
+	}
+	sort.Strings(dKeys)
+
+	for _, k := range dKeys {
+		keys := opts.Tras[k]
+		if len(keys) == 0 {
+			keys = []string{k}
+		}
+
+		s := Dialog{File: k}
+		states := map[string]bool{}
+		for _, o := range dialogs[k] {
+			switch o.Kind {
+			case d.KindNPC:
+				s.NPC++
+				states[o.Dialog+":"+o.State] = true
+			case d.KindPC:
+				s.PC++
+			case d.KindJournal:
+				s.Journal++
+			}
+			if o.StrRef != nil {
+				s.Vanilla++
+			}
+			if o.TraID == nil {
+				continue
+			}
+			id := strconv.Itoa(*o.TraID)
+			for i := len(keys) - 1; i >= 0; i-- {
+				if _, ok := tras[keys[i]].Texts[id]; ok {
+					if used[keys[i]] == nil {
+						used[keys[i]] = map[string]bool{}
+					}
+					used[keys[i]][id] = true
+					break
+				}
+			}
+		}
+		s.States = len(states)
+		r.Dialogs = append(r.Dialogs, s)
+	}
+
+	traKeys := make([]string, 0, len(tras))
+	for k := range tras {
+		traKeys = append(traKeys, k)
+	}
+	sort.Strings(traKeys)
+
+	for _, k := range traKeys {
+		s := Tra{File: k}
+		for id, text := range tras[k].Texts {
+			s.Strings++
+			s.Words += len(strings.Fields(text))
+			if !used[k][id] {
+				s.Unused++
+			}
+			if opts.Translated != nil && opts.Translated(k, id) {
+				s.Translated++
+			}
+		}
+		r.Tras = append(r.Tras, s)
+	}
+	return r
+}
+
+// Total sums the counts of all .tra files.
+func (r Report) Total() Tra {
+	t := Tra{File: "total"}
+	for _, s := range r.Tras {
+		t.Strings += s.Strings
+		t.Words += s.Words
+		t.Unused += s.Unused
+		t.Translated += s.Translated
+	}
+	return t
+}
+
+// Progress is the translated share of the strings, in percent.
+func (s Tra) Progress() float64 {
+	if s.Strings == 0 {
+		return 100
+	}
+	return 100 * float64(s.Translated) / float64(s.Strings)
+}
@@ -0,0 +1,57 @@
+package stats
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/d"
+	"github.com/maciejjwojcik/dlg2csv/internal/tra"
+)
+
+func TestCompute(t *testing.T) {
+	input := `BEGIN BDNPC
+
+IF ~~ hello
+  SAY @1
+  IF ~~ THEN REPLY @2 GOTO bye
+  IF ~~ THEN REPLY #100 EXIT
+END
+
+IF ~~ bye
+  SAY @3
+  IF ~~ THEN REPLY @2 EXIT
+END
+`
+	occ, err := d.ParseReader(strings.NewReader(input), "bdnpc.d")
+	if err != nil {
+		t.Fatalf("ParseReader: %v", err)
+	}
+	dialogs := d.DByFile{"bdnpc": occ}
+	tras := tra.TraByFile{
+		"shared": tra.NewTra(map[string]string{"1": "Hello there, friend."}),
+		"bdnpc":  tra.NewTra(map[string]string{"1": "Overridden", "2": "Bye.", "3": "See you.", "4": "Spare line"}),
+	}
+
+	r := Compute(dialogs, tras, Options{
+		Tras:       map[string][]string{"bdnpc": {"bdnpc", "shared"}},
+		Translated: func(traKey, id string) bool { return traKey == "bdnpc" && id != "4" },
+	})
+
+	wantDialogs := []Dialog{{File: "bdnpc", States: 2, NPC: 2, PC: 3, Vanilla: 1}}
+	if !reflect.DeepEqual(r.Dialogs, wantDialogs) {
+		t.Fatalf("Dialogs:\n got: %+v\nwant: %+v", r.Dialogs, wantDialogs)
+	}
+	wantTras := []Tra{
+		{File: "bdnpc", Strings: 4, Words: 6, Unused: 2, Translated: 3}, // @1 comes from shared
+		{File: "shared", Strings: 1, Words: 3},
+	}
+	if !reflect.DeepEqual(r.Tras, wantTras) {
+		t.Fatalf("Tras:\n got: %+v\nwant: %+v", r.Tras, wantTras)
+	}
+
+	total := r.Total()
+	if total.Strings != 5 || total.Translated != 3 || total.Progress() != 60 {
+		t.Fatalf("Total: %+v (%.0f%%)", total, total.Progress())
+	}
+}
@@ -0,0 +1,201 @@
+// Package tlk reads Infinity Engine talk tables (dialog.tlk / dialogf.tlk,
+// V1 format).
+//
+// Game files are never bundled with dlg2csv; callers point at the user's
+// own installation.
+//
+// Enhanced Edition talk tables are UTF-8; the original games store the
+// strings in the code page of the game language (cp1252, cp1250, ...).
+// Strings are decoded to UTF-8 when the table is read.
+package tlk
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/charset"
+)
+
+const (
+	headerSize = 18
+	entrySize  = 26
+
+	flagText = 0x0001 // entry has text
+)
+
+// File is a parsed talk table.
+type File struct {
+	Lang     uint16
+	Encoding string // encoding the strings were decoded from
+	texts    []string
+}
+
+// Options configure reading a talk table.
+type Options struct {
+	// Encoding of the strings; empty means charset.Auto: UTF-8 if all
+	// strings are valid UTF-8, else the code page of the language folder
+	// in the path, else cp1252.
+	Encoding string
+}
+
+// Open reads a TLK file from disk.
+func Open(path string) (*File, error) {
+	return OpenWithOptions(path, Options{})
+}
+
+// OpenWithOptions is Open with options.
+func OpenWithOptions(path string, opts Options) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := ParseWithOptions(data, path, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return f, nil
+}
+
+// Parse decodes the contents of a TLK V1 file, detecting the encoding of
+// its strings.
+func Parse(data []byte) (*File, error) {
+	return ParseWithOptions(data, "", Options{})
+}
+
+// ParseWithOptions decodes the contents of a TLK V1 file; path is a hint
+// for the language folder of the file.
+//
+// Layout:
+//
+//	header  "TLK V1  " lang:u16 count:u32 stringsOffset:u32
+//	entries count * (flags:u16 sound:8 volume:u32 pitch:u32 offset:u32 length:u32)
+//	strings at stringsOffset + entry offset
+func ParseWithOptions(data []byte, path string, opts Options) (*File, error) {
+	enc, err := charset.Normalize(opts.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < headerSize {
+		return nil, fmt.Errorf("tlk: file too short")
+	}
+	if string(data[0:8]) != "TLK V1  " {
+		return nil, fmt.Errorf("tlk: unsupported signature %q", data[0:8])
+	}
+
+	le := binary.LittleEndian
+	lang := le.Uint16(data[8:10])
+	count := int(le.Uint32(data[10:14]))
+	strOff := int(le.Uint32(data[14:18]))
+
+	if count < 0 || headerSize+count*entrySize > len(data) {
+		return nil, fmt.Errorf("tlk: entry table truncated (%d entries)", count)
+	}
+
+	raw := make([][]byte, count)
+	valid := true
+	for i := 0; i < count; i++ {
+		e := data[headerSize+i*entrySize:]
+		flags := le.Uint16(e[0:2])
+		off := int(le.Uint32(e[18:22]))
+		n := int(le.Uint32(e[22:26]))
+
+		if flags&flagText == 0 || n == 0 {
+			continue
+		}
+		start := strOff + off
+		if start < 0 || n < 0 || start+n > len(data) {
+			return nil, fmt.Errorf("tlk: strref %d points outside the file", i)
+		}
+		raw[i] = data[start : start+n]
+		valid = valid && utf8.Valid(raw[i])
+	}
+
+	if enc == charset.Auto {
+		switch {
+		case valid:
+			enc = charset.UTF8
+		case charset.ForLanguage(path) != "":
+			enc = charset.ForLanguage(path)
+		default:
+			enc = "cp1252"
+		}
+	}
+	texts := make([]string, count)
+	for i, b := range raw {
+		if len(b) == 0 {
+			continue
+		}
+		text, _, err := charset.Decode(b, enc, "")
+		if err != nil {
+			return nil, fmt.Errorf("tlk: strref %d: %w", i, err)
+		}
+		texts[i] = string(text)
+	}
+
+	return &File{Lang: lang, Encoding: enc, texts: texts}, nil
+}
+
+// Len returns the number of entries in the table.
+func (f *File) Len() int {
+	return len(f.texts)
+}
+
+// Lookup returns the text of strref. ok is false for out-of-range strrefs.
+func (f *File) Lookup(strref int) (text string, ok bool) {
+	if f == nil || strref < 0 || strref >= len(f.texts) {
+		return "", false
+	}
+	return f.texts[strref], true
+}
+
+// Talk is the talk table pair of a game language: dialog.tlk and, for
+// languages with gendered text, dialogf.tlk.
+type Talk struct {
+	Male   *File
+	Female *File // nil when the language has no dialogf.tlk
+}
+
+// OpenTalk loads dialog.tlk and the optional dialogf.tlk next to it. path
+// is either the dialog.tlk file or the language folder containing it.
+func OpenTalk(path string) (*Talk, error) {
+	return OpenTalkWithOptions(path, Options{})
+}
+
+// OpenTalkWithOptions is OpenTalk with options.
+func OpenTalkWithOptions(path string, opts Options) (*Talk, error) {
+	dir, name := path, "dialog.tlk"
+	if st, err := os.Stat(path); err == nil && !st.IsDir() {
+		dir, name = filepath.Split(path)
+	}
+
+	male, err := OpenWithOptions(filepath.Join(dir, name), opts)
+	if err != nil {
+		return nil, err
+	}
+	t := &Talk{Male: male}
+
+	femalePath := filepath.Join(dir, "dialogf.tlk")
+	if _, err := os.Stat(femalePath); err == nil {
+		if t.Female, err = OpenWithOptions(femalePath, opts); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// Lookup returns the dialog.tlk text of strref.
+func (t *Talk) Lookup(strref int) (string, bool) {
+	return t.Male.Lookup(strref)
+}
+
+// LookupFemale returns the dialogf.tlk text of strref. ok is false when
+// there is no dialogf.tlk or the strref is out of its range.
+func (t *Talk) LookupFemale(strref int) (string, bool) {
+	if t.Female == nil {
+		return "", false
+	}
+	return t.Female.Lookup(strref)
+}
@@ -0,0 +1,155 @@
+package tlk
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// buildTLK encodes texts as a TLK V1 file; empty strings get no text flag.
+func buildTLK(lang uint16, texts []string) []byte {
+	le := binary.LittleEndian
+	strOff := headerSize + len(texts)*entrySize
+
+	data := make([]byte, strOff)
+	copy(data, "TLK V1  ")
+	le.PutUint16(data[8:], lang)
+	le.PutUint32(data[10:], uint32(len(texts)))
+	le.PutUint32(data[14:], uint32(strOff))
+
+	var strs []byte
+	for i, s := range texts {
+		e := data[headerSize+i*entrySize:]
+		if s != "" {
+			le.PutUint16(e[0:], flagText)
+		}
+		le.PutUint32(e[18:], uint32(len(strs)))
+		le.PutUint32(e[22:], uint32(len(s)))
+		strs = append(strs, s...)
+	}
+	return append(data, strs...)
+}
+
+func TestParse_Lookup(t *testing.T) {
+	f, err := Parse(buildTLK(3, []string{"<NO TEXT>", "", "Farewell."}))
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+
+	if f.Lang != 3 {
+		t.Fatalf("Lang: got %d, want 3", f.Lang)
+	}
+	if f.Len() != 3 {
+		t.Fatalf("Len: got %d, want 3", f.Len())
+	}
+
+	tests := []struct {
+		strref int
+		want   string
+		ok     bool
+	}{
+		{0, "<NO TEXT>", true},
+		{1, "", true},
+		{2, "Farewell.", true},
+		{3, "", false},
+		{-1, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := f.Lookup(tt.strref)
+		if got != tt.want || ok != tt.ok {
+			t.Fatalf("Lookup(%d) = %q, %v; want %q, %v", tt.strref, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParseWithOptions_Encoding(t *testing.T) {
+	zolw := string([]byte{0xBF, 0xF3, 0xB3, 0x77}) // "żółw" in cp1250
+
+	tests := []struct {
+		name, text, path, enc string
+		want, wantEnc         string
+	}{
+		{"language folder", zolw, "polish/dialog.tlk", "", "żółw", "cp1250"},
+		{"explicit", zolw, "dialog.tlk", "windows-1250", "żółw", "cp1250"},
+		{"fallback cp1252", zolw, "dialog.tlk", "", "¿ó³w", "cp1252"},
+		{"enhanced edition", "żółw", "lang/pl_PL/dialog.tlk", "", "żółw", "utf-8"},
+	}
+	for _, tt := range tests {
+		f, err := ParseWithOptions(buildTLK(0, []string{"Hello.", tt.text}), tt.path, Options{Encoding: tt.enc})
+		if err != nil {
+			t.Fatalf("%s: ParseWithOptions error: %v", tt.name, err)
+		}
+		if got, _ := f.Lookup(1); got != tt.want || f.Encoding != tt.wantEnc {
+			t.Fatalf("%s: Lookup(1) = %q (%s), want %q (%s)", tt.name, got, f.Encoding, tt.want, tt.wantEnc)
+		}
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	valid := buildTLK(0, []string{"abc"})
+
+	truncatedString := append([]byte(nil), valid...)
+	truncatedString = truncatedString[:len(truncatedString)-1]
+
+	badSig := append([]byte(nil), valid...)
+	copy(badSig, "TLK V3  ")
+
+	tests := map[string][]byte{
+		"too short":         valid[:10],
+		"bad signature":     badSig,
+		"truncated entries": valid[:headerSize+entrySize-1],
+		"truncated string":  truncatedString,
+	}
+	for name, data := range tests {
+		if _, err := Parse(data); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+	}
+}
+
+func TestOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dialog.tlk")
+	if err := os.WriteFile(path, buildTLK(0, []string{"Hello."}), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	f, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open error: %v", err)
+	}
+	if got, _ := f.Lookup(0); got != "Hello." {
+		t.Fatalf("Lookup(0) = %q", got)
+	}
+}
+
+func TestOpenTalk(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "dialog.tlk"), buildTLK(0, []string{"Welcome, friend."}), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	// without dialogf.tlk, from the language folder
+	talk, err := OpenTalk(dir)
+	if err != nil {
+		t.Fatalf("OpenTalk(dir) error: %v", err)
+	}
+	if got, ok := talk.Lookup(0); !ok || got != "Welcome, friend." {
+		t.Fatalf("Lookup(0) = %q, %v", got, ok)
+	}
+	if _, ok := talk.LookupFemale(0); ok {
+		t.Fatalf("LookupFemale without dialogf.tlk should fail")
+	}
+
+	// with dialogf.tlk, from the dialog.tlk path
+	if err := os.WriteFile(filepath.Join(dir, "dialogf.tlk"), buildTLK(0, []string{"Welcome, lady."}), 0o644); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	talk, err = OpenTalk(filepath.Join(dir, "dialog.tlk"))
+	if err != nil {
+		t.Fatalf("OpenTalk(file) error: %v", err)
+	}
+	if got, ok := talk.LookupFemale(0); !ok || got != "Welcome, lady." {
+		t.Fatalf("LookupFemale(0) = %q, %v", got, ok)
+	}
+}
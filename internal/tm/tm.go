@@ -0,0 +1,178 @@
+// Package tm is a translation memory: translations of earlier strings,
+// looked up by their source text to prefill the sheets of new ones. Large
+// mods repeat short lines ("Farewell.", "What do you want?") across
+// dialogs, and new releases mostly repeat the strings of the last one.
+package tm
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/tra"
+	helpers "github.com/maciejjwojcik/dlg2csv/internal/utils"
+)
+
+// DefaultMinScore is the lowest similarity of a fuzzy match returned by
+// Lookup unless Memory.MinScore says otherwise.
+const DefaultMinScore = 0.7
+
+// Match is a translation found for a source text.
+type Match struct {
+	Source string // source text the translation was made for
+	Male   string
+	Female string // empty without a female variant
+
+	// Score is the similarity of Source and the looked up text, from 0 to
+	// 1 for an exact match.
+	Score float64
+}
+
+// Exact reports whether the match is for the same source text.
+func (m Match) Exact() bool {
+	return m.Score == 1
+}
+
+// Memory stores translations by source text. The zero value is not
+// usable; use New.
+type Memory struct {
+	// MinScore is the lowest similarity of a fuzzy match Lookup returns.
+	MinScore float64
+
+	units []unit
+	exact map[string]int // normalized source -> index in units
+	byLen map[int][]int  // length in runes -> indexes in units
+	long  int            // length of the longest source
+}
+
+type unit struct {
+	runes []rune // normalized source
+	match Match
+}
+
+func New() *Memory {
+	return &Memory{MinScore: DefaultMinScore, exact: map[string]int{}, byLen: map[int][]int{}}
+}
+
+// Len returns the number of stored source texts.
+func (mem *Memory) Len() int {
+	return len(mem.units)
+}
+
+// Add stores the translation of source. Texts differing only in
+// whitespace are the same; the first translation added for a text wins.
+func (mem *Memory) Add(source, male, female string) {
+	key := normalize(source)
+	if key == "" || male == "" {
+		return
+	}
+	if _, ok := mem.exact[key]; ok {
+		return
+	}
+	runes := []rune(key)
+	mem.exact[key] = len(mem.units)
+	mem.byLen[len(runes)] = append(mem.byLen[len(runes)], len(mem.units))
+	mem.long = max(mem.long, len(runes))
+	mem.units = append(mem.units, unit{
+		runes: runes,
+		match: Match{Source: source, Male: male, Female: female, Score: 1},
+	})
+}
+
+// AddTras stores the strings of target as translations of the strings of
+// source with the same file and @id, e.g. the language/polish and
+// language/english folders of a mod. Strings left in the source language
+// are skipped.
+func (mem *Memory) AddTras(source, target tra.TraByFile) {
+	keys := make([]string, 0, len(target))
+	for k := range target {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		t := target[k]
+		for _, id := range t.IDs() {
+			src, ok := source[k].Texts[id]
+			if !ok || t.Texts[id] == src {
+				continue
+			}
+			female := ""
+			if t.HasFemale(id) {
+				female = t.Female[id]
+			}
+			mem.Add(src, t.Texts[id], female)
+		}
+	}
+}
+
+// Lookup returns the translation of source, or else the one of the most
+// similar source text scoring at least MinScore, the first one added on a
+// tie.
+func (mem *Memory) Lookup(source string) (Match, bool) {
+	key := normalize(source)
+	if key == "" {
+		return Match{}, false
+	}
+	if i, ok := mem.exact[key]; ok {
+		return mem.units[i].match, true
+	}
+
+	// The distance is at least the difference in length, so only texts
+	// of a length between n*MinScore and n/MinScore can score enough.
+	runes := []rune(key)
+	n := len(runes)
+	lo, hi := int(float64(n)*mem.MinScore), mem.long
+	if mem.MinScore > 0 {
+		hi = min(hi, int(math.Ceil(float64(n)/mem.MinScore)))
+	}
+	best, bestScore := -1, mem.MinScore
+	for l := lo; l <= hi; l++ {
+		longer := max(n, l)
+		if float64(abs(n-l)) > (1-bestScore)*float64(longer) {
+			continue
+		}
+		for _, i := range mem.byLen[l] {
+			s, ok := similarity(runes, mem.units[i].runes, bestScore)
+			if ok && (best < 0 || s > bestScore || s == bestScore && i < best) {
+				best, bestScore = i, s
+			}
+		}
+	}
+	if best < 0 {
+		return Match{}, false
+	}
+	m := mem.units[best].match
+	m.Score = bestScore
+	return m, true
+}
+
+// normalize collapses runs of whitespace, so that line breaks and
+// indentation of multi-line strings don't matter.
+func normalize(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// similarity is 1 minus the edit distance of a and b relative to the
+// longer one. It reports false if that is below atLeast, without
+// computing the whole distance.
+func similarity(a, b []rune, atLeast float64) (float64, bool) {
+	longer := max(len(a), len(b))
+	if longer == 0 {
+		return 1, true
+	}
+	limit := int(math.Floor((1-atLeast)*float64(longer) + 1e-9)) // rounding of atLeast
+	d, ok := helpers.EditDistanceAtMost(a, b, limit)
+	if !ok {
+		return 0, false
+	}
+	s := 1 - float64(d)/float64(longer)
+	return s, s >= atLeast
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,101 @@
+package tm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/tra"
+)
+
+func TestMemory_Lookup(t *testing.T) {
+	mem := New()
+	mem.Add("Farewell.", "Żegnaj.", "")
+	mem.Add("What do you want?", "Czego chcesz?", "")
+	mem.Add("Farewell.", "Bywaj.", "") // the first translation wins
+	mem.Add("Untranslated", "", "")
+
+	if mem.Len() != 2 {
+		t.Fatalf("Len = %d, want 2", mem.Len())
+	}
+
+	m, ok := mem.Lookup("  Farewell.\n")
+	if !ok || !m.Exact() || m.Male != "Żegnaj." {
+		t.Fatalf("exact lookup = %+v, %v", m, ok)
+	}
+
+	m, ok = mem.Lookup("What do you want now?")
+	if !ok || m.Exact() || m.Source != "What do you want?" || m.Male != "Czego chcesz?" {
+		t.Fatalf("fuzzy lookup = %+v, %v", m, ok)
+	}
+	if m.Score < 0.8 || m.Score >= 1 {
+		t.Fatalf("fuzzy score = %v", m.Score)
+	}
+
+	if m, ok := mem.Lookup("Something else entirely."); ok {
+		t.Fatalf("expected no match, got %+v", m)
+	}
+
+	mem.MinScore = 0.9
+	if m, ok := mem.Lookup("What do you want now?"); ok {
+		t.Fatalf("expected no match above %v, got %+v", mem.MinScore, m)
+	}
+}
+
+func TestMemory_Lookup_Ties(t *testing.T) {
+	mem := New()
+	mem.Add("Farewell, friends.", "Żegnajcie, przyjaciele.", "")
+	mem.Add("Farewell, fiend.", "Żegnaj, potworze.", "")
+	mem.Add("Farewell, friend.", "Żegnaj, przyjacielu.", "")
+
+	// one edit from the first and the last, of different lengths
+	m, ok := mem.Lookup("Farewell, friendd.")
+	if !ok || m.Male != "Żegnajcie, przyjaciele." {
+		t.Fatalf("Lookup = %+v, %v, want the first added of the best", m, ok)
+	}
+
+	mem.MinScore = 0
+	if m, ok := mem.Lookup("Hi."); !ok || m.Score <= 0 {
+		t.Fatalf("Lookup with MinScore 0 = %+v, %v", m, ok)
+	}
+}
+
+func TestMemory_AddTras(t *testing.T) {
+	parse := func(src string) tra.Tra {
+		t.Helper()
+		tr, err := tra.ParseReader(strings.NewReader(src), "x.tra")
+		if err != nil {
+			t.Fatalf("ParseReader: %v", err)
+		}
+		return *tr
+	}
+	source := tra.TraByFile{"bdnpc": parse("@1 = ~Ready, sir.~ ~Ready, madam.~\n@2 = ~Bye.~\n@3 = ~Sword~\n")}
+	target := tra.TraByFile{"bdnpc": parse("@1 = ~Gotowy.~ ~Gotowa.~\n@3 = ~Sword~\n@4 = ~Extra~\n")}
+
+	mem := New()
+	mem.AddTras(source, target)
+
+	if mem.Len() != 1 {
+		t.Fatalf("only @1 is translated, got %d entries", mem.Len())
+	}
+	m, ok := mem.Lookup("Ready, sir.")
+	if !ok || m.Male != "Gotowy." || m.Female != "Gotowa." {
+		t.Fatalf("Lookup = %+v, %v", m, ok)
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want float64
+	}{
+		{"abcd", "abcd", 1},
+		{"abcd", "abce", 0.75},
+		{"", "", 1},
+		{"ab", "", 0},
+	}
+	for _, tt := range tests {
+		if got, _ := similarity([]rune(tt.a), []rune(tt.b), 0); got != tt.want {
+			t.Errorf("similarity(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
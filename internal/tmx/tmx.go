@@ -0,0 +1,344 @@
+// Package tmx reads and writes translation memories in TMX 1.4, the
+// format CAT tools exchange them in. Each variant of a string is a <tu>;
+// the .tra file, @id and variant are kept as <prop>s, so a memory written
+// here can be turned back into .tra files.
+package tmx
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/csv"
+	"github.com/maciejjwojcik/dlg2csv/internal/tm"
+	"github.com/maciejjwojcik/dlg2csv/internal/tra"
+)
+
+// Properties holding the origin of a unit.
+const (
+	propFile    = "x-file"    // .tra file, e.g. bdnpc.tra
+	propID      = "x-id"      // @id without the @
+	propVariant = "x-variant" // male or female
+)
+
+const (
+	variantMale   = "male"
+	variantFemale = "female"
+)
+
+// Unit is one variant of a translated string.
+type Unit struct {
+	// File and ID locate the string, e.g. "bdnpc.tra" and "12". They are
+	// empty for memories written by other tools.
+	File string
+	ID   string
+
+	Female bool // the female variant
+	Source string
+	Target string
+}
+
+// key is the .tra file base name, as in tra.TraByFile.
+func (u Unit) key() string {
+	return strings.ToLower(strings.TrimSuffix(u.File, filepath.Ext(u.File)))
+}
+
+// Memory is the content of a TMX file.
+type Memory struct {
+	SourceLang string // e.g. "en"
+	TargetLang string // e.g. "pl"
+	Units      []Unit
+}
+
+// FromTranslations pairs the strings of source with their translations,
+// read back from the CSV sheets or paired by csv.TraTranslations.
+func FromTranslations(source tra.TraByFile, translations csv.Translations) []Unit {
+	var units []Unit
+	for _, k := range slices.Sorted(maps.Keys(translations)) {
+		ids := make([]string, 0, len(translations[k]))
+		for id := range translations[k] {
+			ids = append(ids, id)
+		}
+		tra.SortIDs(ids)
+
+		for _, id := range ids {
+			src, ok := source[k].Texts[id]
+			if !ok {
+				continue
+			}
+			t := translations[k][id]
+			units = append(units, Unit{File: k + ".tra", ID: id, Source: src, Target: t.Male})
+			if t.Female != "" {
+				units = append(units, Unit{File: k + ".tra", ID: id, Female: true, Source: source[k].FemaleSource(id), Target: t.Female})
+			}
+		}
+	}
+	return units
+}
+
+// AddTo adds the units to a translation memory, pairing the female variant
+// of a string with its male one.
+func (m Memory) AddTo(mem *tm.Memory) {
+	female := map[[2]string]string{}
+	for _, u := range m.Units {
+		if u.Female && u.ID != "" {
+			female[[2]string{u.key(), u.ID}] = u.Target
+		}
+	}
+	for _, u := range m.Units {
+		switch {
+		case !u.Female:
+			mem.Add(u.Source, u.Target, female[[2]string{u.key(), u.ID}])
+		case u.ID == "":
+			mem.Add(u.Source, u.Target, "")
+		}
+	}
+}
+
+// Translations returns the units with a file and @id still holding the
+// same source text in source, e.g. to write translated .tra files, and
+// the number of units skipped as their string changed or is gone, or as
+// they are a female variant without the male one. The positions of the
+// translations name the TMX file, name.
+func (m Memory) Translations(source tra.TraByFile, name string) (csv.Translations, int) {
+	out := csv.Translations{}
+	skipped := 0
+	for _, u := range m.Units {
+		if u.ID == "" {
+			continue
+		}
+		k := u.key()
+		s, ok := source[k]
+		if !ok {
+			skipped++
+			continue
+		}
+		want, ok := s.Texts[u.ID]
+		if u.Female {
+			want = s.FemaleSource(u.ID)
+		}
+		if !ok || want != u.Source {
+			skipped++
+			continue
+		}
+
+		if out[k] == nil {
+			out[k] = map[string]csv.Translation{}
+		}
+		t := out[k][u.ID]
+		if u.Female {
+			t.Female = u.Target
+		} else {
+			t.Male = u.Target
+		}
+		t.Pos.File = name
+		out[k][u.ID] = t
+	}
+	return out, skipped + out.DropFemaleOnly()
+}
+
+// TMX 1.4 document structure.
+type document struct {
+	XMLName xml.Name `xml:"tmx"`
+	Version string   `xml:"version,attr"`
+	Header  header   `xml:"header"`
+	Units   []tu     `xml:"body>tu"`
+}
+
+type header struct {
+	CreationTool        string `xml:"creationtool,attr"`
+	CreationToolVersion string `xml:"creationtoolversion,attr"`
+	SegType             string `xml:"segtype,attr"`
+	OTMF                string `xml:"o-tmf,attr"`
+	AdminLang           string `xml:"adminlang,attr"`
+	SrcLang             string `xml:"srclang,attr"`
+	DataType            string `xml:"datatype,attr"`
+}
+
+type tu struct {
+	TUID  string `xml:"tuid,attr,omitempty"`
+	Props []prop `xml:"prop"`
+	TUVs  []tuv  `xml:"tuv"`
+}
+
+type prop struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+type tuv struct {
+	Lang string `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	Seg  seg    `xml:"seg"`
+}
+
+// seg is the text of a segment. Reading it keeps the text of inline
+// elements, e.g. <ph>&lt;CHARNAME&gt;</ph> written by CAT tools.
+type seg string
+
+func (s *seg) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var b strings.Builder
+	for depth := 1; depth > 0; {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.CharData:
+			b.Write(t)
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+	}
+	*s = seg(b.String())
+	return nil
+}
+
+// Write writes m as a TMX 1.4 document.
+func Write(w io.Writer, m Memory) error {
+	doc := document{
+		Version: "1.4",
+		Header: header{
+			CreationTool:        "dlg2csv",
+			CreationToolVersion: "1",
+			SegType:             "block",
+			OTMF:                "WeiDU .tra",
+			AdminLang:           "en",
+			SrcLang:             m.SourceLang,
+			DataType:            "plaintext",
+		},
+	}
+	for _, u := range m.Units {
+		t := tu{TUVs: []tuv{
+			{Lang: m.SourceLang, Seg: seg(u.Source)},
+			{Lang: m.TargetLang, Seg: seg(u.Target)},
+		}}
+		if u.ID != "" {
+			variant := variantMale
+			if u.Female {
+				variant = variantFemale
+			}
+			t.TUID = fmt.Sprintf("%s@%s/%s", u.File, u.ID, variant)
+			t.Props = []prop{{propFile, u.File}, {propID, u.ID}, {propVariant, variant}}
+		}
+		doc.Units = append(doc.Units, t)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// Read reads a TMX document. The source language is the one of the
+// header; the target is targetLang, or the first other language found
+// when it is empty. Languages match by prefix, so "pl" matches "pl-PL".
+func Read(r io.Reader, targetLang string) (Memory, error) {
+	var doc document
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return Memory{}, fmt.Errorf("read TMX: %w", err)
+	}
+	m := Memory{SourceLang: doc.Header.SrcLang, TargetLang: targetLang}
+	if m.SourceLang == "" || m.SourceLang == "*all*" {
+		return Memory{}, fmt.Errorf("read TMX: the header has no source language")
+	}
+
+	for _, t := range doc.Units {
+		u := Unit{}
+		for _, p := range t.Props {
+			switch p.Type {
+			case propFile:
+				u.File = p.Value
+			case propID:
+				u.ID = strings.TrimPrefix(p.Value, "@")
+			case propVariant:
+				u.Female = p.Value == variantFemale
+			}
+		}
+
+		var hasSource, hasTarget bool
+		for _, v := range t.TUVs {
+			switch {
+			case langMatches(v.Lang, m.SourceLang):
+				u.Source, hasSource = string(v.Seg), true
+			case m.TargetLang == "":
+				m.TargetLang = v.Lang
+				fallthrough
+			case langMatches(v.Lang, m.TargetLang):
+				u.Target, hasTarget = string(v.Seg), true
+			}
+		}
+		if hasSource && hasTarget && u.Target != "" {
+			m.Units = append(m.Units, u)
+		}
+	}
+	return m, nil
+}
+
+// langMatches reports whether lang is want or one of its regions.
+func langMatches(lang, want string) bool {
+	lang, want = strings.ToLower(lang), strings.ToLower(want)
+	return lang == want || strings.HasPrefix(lang, want+"-") || strings.HasPrefix(lang, want+"_")
+}
+
+// languageCodes maps the language folders of the games and mods to
+// language codes.
+var languageCodes = map[string]string{
+	"english":    "en",
+	"american":   "en",
+	"polish":     "pl",
+	"russian":    "ru",
+	"german":     "de",
+	"french":     "fr",
+	"italian":    "it",
+	"spanish":    "es",
+	"czech":      "cs",
+	"portuguese": "pt",
+	"brazilian":  "pt-BR",
+	"ukrainian":  "uk",
+	"chinese":    "zh",
+	"schinese":   "zh-CN",
+	"tchinese":   "zh-TW",
+	"japanese":   "ja",
+	"korean":     "ko",
+	"turkish":    "tr",
+	"hungarian":  "hu",
+	"swedish":    "sv",
+}
+
+// LangCode guesses the language code from a language folder found
+// anywhere in path: "lang/polish" -> "pl", "lang/en_US" -> "en-US". It
+// returns "" if no element of the path is a known language.
+func LangCode(path string) string {
+	parts := strings.Split(filepath.ToSlash(path), "/")
+	for i := len(parts) - 1; i >= 0; i-- {
+		p := strings.ToLower(parts[i])
+		if code, ok := languageCodes[p]; ok {
+			return code
+		}
+		if len(p) == 5 && p[2] == '_' && isLetters(p[:2]) && isLetters(p[3:]) {
+			return p[:2] + "-" + strings.ToUpper(p[3:])
+		}
+	}
+	return ""
+}
+
+func isLetters(s string) bool {
+	for _, c := range s {
+		if c < 'a' || c > 'z' {
+			return false
+		}
+	}
+	return true
+}
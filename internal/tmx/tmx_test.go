@@ -0,0 +1,154 @@
+package tmx
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/csv"
+	"github.com/maciejjwojcik/dlg2csv/internal/fixture"
+	"github.com/maciejjwojcik/dlg2csv/internal/tm"
+)
+
+func TestWriteRead_RoundTrip(t *testing.T) {
+	source, target := fixture.Tra(t, "english"), fixture.Tra(t, "polish")
+
+	m := Memory{SourceLang: "en", TargetLang: "pl", Units: FromTranslations(source, csv.TraTranslations(source, target))}
+	if len(m.Units) != 3 {
+		t.Fatalf("expected 3 units (@3 is untranslated), got %+v", m.Units)
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, m); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`<tmx version="1.4">`,
+		`srclang="en"`,
+		`<tu tuid="bdnpc.tra@1/female">`,
+		`<prop type="x-variant">female</prop>`,
+		`<tuv xml:lang="pl">`,
+		`<seg>  Bye &amp; farewell.</seg>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("output lacks %s:\n%s", want, out)
+		}
+	}
+
+	got, err := Read(strings.NewReader(out), "")
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if !reflect.DeepEqual(got, m) {
+		t.Fatalf("round trip:\ngot  %+v\nwant %+v", got, m)
+	}
+
+	translations, stale := got.Translations(source, "pl.tmx")
+	if stale != 0 {
+		t.Fatalf("stale = %d", stale)
+	}
+	tr := translations["bdnpc"]["1"]
+	if tr.Male != "Gotowy, <CHARNAME>?" || tr.Female != "Gotowa, <CHARNAME>?" || tr.Pos.File != "pl.tmx" {
+		t.Fatalf("translation of @1: %+v", tr)
+	}
+
+	// the string changed since the memory was written
+	changed := fixture.ParseTra(t, "@1 = ~Ready?~\n@2 = ~  Bye & farewell.~\n")
+	translations, stale = got.Translations(changed, "pl.tmx")
+	if stale != 2 || len(translations["bdnpc"]) != 1 {
+		t.Fatalf("expected only @2, got %+v (stale %d)", translations, stale)
+	}
+}
+
+func TestMemory_Translations_FemaleOnly(t *testing.T) {
+	m := Memory{Units: []Unit{{File: "bdnpc.tra", ID: "1", Female: true, Source: "Ready, my lady?", Target: "Gotowa?"}}}
+
+	translations, skipped := m.Translations(fixture.Tra(t, "english"), "pl.tmx")
+	if len(translations) != 0 || skipped != 1 {
+		t.Fatalf("a female variant alone should be skipped, got %+v (skipped %d)", translations, skipped)
+	}
+}
+
+func TestRead_OtherTools(t *testing.T) {
+	input := `<?xml version="1.0" encoding="UTF-8"?>
+<tmx version="1.4">
+  <header creationtool="SomeCAT" srclang="en-US" segtype="sentence" datatype="plaintext" o-tmf="x" adminlang="en" creationtoolversion="2"/>
+  <body>
+    <tu>
+      <tuv xml:lang="en-US"><seg>Hello <ph>&lt;CHARNAME&gt;</ph>.</seg></tuv>
+      <tuv xml:lang="de-DE"><seg>Hallo <ph>&lt;CHARNAME&gt;</ph>.</seg></tuv>
+      <tuv xml:lang="pl-PL"><seg>Witaj <ph>&lt;CHARNAME&gt;</ph>.</seg></tuv>
+    </tu>
+    <tu>
+      <tuv xml:lang="en-US"><seg>Untranslated</seg></tuv>
+    </tu>
+  </body>
+</tmx>`
+
+	m, err := Read(strings.NewReader(input), "pl")
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	want := []Unit{{Source: "Hello <CHARNAME>.", Target: "Witaj <CHARNAME>."}}
+	if !reflect.DeepEqual(m.Units, want) {
+		t.Fatalf("units: %+v", m.Units)
+	}
+
+	mem := tm.New()
+	m.AddTo(mem)
+	if match, ok := mem.Lookup("Hello <CHARNAME>."); !ok || match.Male != "Witaj <CHARNAME>." {
+		t.Fatalf("Lookup = %+v, %v", match, ok)
+	}
+
+	if _, err := Read(strings.NewReader(`<tmx version="1.4"><header/><body/></tmx>`), ""); err == nil {
+		t.Fatal("expected an error without a source language")
+	}
+}
+
+func TestRead_InlineCodes(t *testing.T) {
+	// a memory written by dlg2csv and saved again by a CAT tool, which
+	// wraps the tokens and tags in inline codes
+	input := `<?xml version="1.0" encoding="UTF-8"?>
+<tmx version="1.4">
+  <header creationtool="SomeCAT" srclang="en" segtype="block" datatype="plaintext" o-tmf="x" adminlang="en" creationtoolversion="2"/>
+  <body>
+    <tu tuid="bdnpc.tra@1/male">
+      <prop type="x-file">bdnpc.tra</prop>
+      <prop type="x-id">1</prop>
+      <prop type="x-variant">male</prop>
+      <tuv xml:lang="en"><seg>Ready, <ph x="1" type="x-token">&lt;CHARNAME&gt;</ph>?</seg></tuv>
+      <tuv xml:lang="pl"><seg><bpt i="1" x="2">&lt;i&gt;</bpt>Gotowy<ept i="1">&lt;/i&gt;</ept>, <ph x="1" type="x-token">&lt;CHARNAME&gt;</ph>?</seg></tuv>
+    </tu>
+  </body>
+</tmx>`
+
+	m, err := Read(strings.NewReader(input), "pl")
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	want := []Unit{{File: "bdnpc.tra", ID: "1", Source: "Ready, <CHARNAME>?", Target: "<i>Gotowy</i>, <CHARNAME>?"}}
+	if !reflect.DeepEqual(m.Units, want) {
+		t.Fatalf("units: %+v", m.Units)
+	}
+	translations, stale := m.Translations(fixture.Tra(t, "english"), "pl.tmx")
+	if stale != 0 || translations["bdnpc"]["1"].Male != "<i>Gotowy</i>, <CHARNAME>?" {
+		t.Fatalf("Translations = %+v (stale %d)", translations, stale)
+	}
+}
+
+func TestLangCode(t *testing.T) {
+	for path, want := range map[string]string{
+		"mymod/tra/polish": "pl",
+		"lang/en_US":       "en-US",
+		"lang/pt_BR/":      "pt-BR",
+		"brazilian":        "pt-BR",
+		"dlg_x":            "",
+		"tra":              "",
+	} {
+		if got := LangCode(path); got != want {
+			t.Errorf("LangCode(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
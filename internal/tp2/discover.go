@@ -0,0 +1,318 @@
+package tp2
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	helpers "github.com/maciejjwojcik/dlg2csv/internal/utils"
+)
+
+// Layout is the translation layout a mod declares in its .tp2: the
+// languages it offers and which .tra files each COMPILE uses.
+type Layout struct {
+	TP2       string // path of the .tp2 file
+	ModFolder string // value of %MOD_FOLDER%
+	Languages []Language
+	Compiles  []Compile
+	AutoTra   []string // AUTO_TRA paths, with %s for the language directory
+}
+
+// Language is a LANGUAGE directive:
+//
+//	LANGUAGE ~English~ ~english~ ~mymod/tra/english/setup.tra~
+type Language struct {
+	Name string   // display name, e.g. "English"
+	Dir  string   // language directory, substituted for %s and %LANGUAGE%
+	Tras []string // .tra files loaded for every component
+}
+
+// Compile is a COMPILE action with the .tra files in its scope.
+//
+//	COMPILE EVALUATE_BUFFER ~mymod/dlg/a.d~ ~mymod/dlg~ USING ~mymod/tra/%s/a.tra~
+type Compile struct {
+	Pos   helpers.Pos
+	D     []string // .d files or folders of .d files
+	Using []string // USING .tra files
+	Tras  []string // LOAD_TRA files of the enclosing component
+}
+
+// Files are the source files of one language, resolved on disk.
+type Files struct {
+	D   []string // .d files
+	Tra []string // .tra files
+
+	// Tras maps each .d (base name) to the .tra files (base names) it
+	// uses, in load order; suitable for csv.Options.Tras.
+	Tras map[string][]string
+
+	// Missing lists referenced paths that don't exist, e.g. because they
+	// use variables the scanner can't evaluate.
+	Missing []string
+}
+
+// Discover finds the .tp2 of the mod in root (or takes root itself if it
+// is a .tp2 file) and reads its layout.
+func Discover(root string) (*Layout, error) {
+	path := root
+	if st, err := os.Stat(root); err != nil {
+		return nil, err
+	} else if st.IsDir() {
+		matches, err := filepath.Glob(filepath.Join(root, "*.[tT][pP]2"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(matches)
+		switch len(matches) {
+		case 0:
+			return nil, fmt.Errorf("no .tp2 file in %s", root)
+		case 1:
+			path = matches[0]
+		default:
+			return nil, fmt.Errorf("several .tp2 files in %s, pass one of them: %s", root, strings.Join(matches, ", "))
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			return
+		}
+	}()
+
+	l, err := ParseLayout(f, filepath.Base(path))
+	if err != nil {
+		return nil, err
+	}
+	l.TP2 = path
+	return l, nil
+}
+
+// ParseLayout reads the LANGUAGE, AUTO_TRA, LOAD_TRA and COMPILE
+// directives of a .tp2. fileName is used for positions and to derive
+// %MOD_FOLDER% (setup-mymod.tp2 and mymod.tp2 both give "mymod").
+func ParseLayout(r io.Reader, fileName string) (*Layout, error) {
+	tokens, err := tokenize(r)
+	if err != nil {
+		return nil, err
+	}
+
+	mod := strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(fileName))
+	mod = strings.TrimPrefix(strings.TrimPrefix(mod, "setup-"), "SETUP-")
+	l := &Layout{ModFolder: mod}
+
+	var (
+		compTras []string
+		lastLn   int
+	)
+
+	// literals returns the string literals starting at tokens[i]
+	literals := func(i int) []string {
+		var out []string
+		for ; i < len(tokens) && tokens[i].Str; i++ {
+			out = append(out, tokens[i].Text)
+		}
+		return out
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		lineStart := tok.Line != lastLn
+		lastLn = tok.Line
+		if tok.Str {
+			continue
+		}
+
+		switch {
+		case isComponentBegin(tokens, i, lineStart):
+			compTras = nil
+
+		case tok.Text == "LANGUAGE":
+			args := literals(i + 1)
+			if len(args) < 2 {
+				return nil, fmt.Errorf("%s:%d: LANGUAGE needs a name and a directory", fileName, tok.Line)
+			}
+			l.Languages = append(l.Languages, Language{Name: args[0], Dir: args[1], Tras: args[2:]})
+			i += len(args)
+
+		case tok.Text == "AUTO_TRA":
+			args := literals(i + 1)
+			if len(args) == 0 && i+1 < len(tokens) {
+				args = []string{tokens[i+1].Text}
+			}
+			l.AutoTra = append(l.AutoTra, args...)
+			i += len(args)
+
+		case tok.Text == "LOAD_TRA":
+			args := literals(i + 1)
+			compTras = append(compTras, args...)
+			i += len(args)
+
+		case tok.Text == "COMPILE":
+			c := Compile{
+				Pos:  helpers.Pos{File: fileName, Line: tok.Line, Col: tok.Col},
+				Tras: append([]string(nil), compTras...),
+			}
+			j := i + 1
+		args:
+			for ; j < len(tokens); j++ {
+				t := tokens[j]
+				switch {
+				case t.Str && c.Using == nil:
+					c.D = append(c.D, t.Text)
+				case !t.Str && t.Text == "EVALUATE_BUFFER":
+				case !t.Str && t.Text == "USING":
+					c.Using = literals(j + 1)
+					j += len(c.Using)
+				default:
+					break args
+				}
+			}
+			l.Compiles = append(l.Compiles, c)
+			i = j - 1
+		}
+	}
+
+	return l, nil
+}
+
+// Language returns the language with directory (or name) dir,
+// case-insensitively; an empty dir selects the first one.
+func (l *Layout) Language(dir string) (Language, bool) {
+	for _, lang := range l.Languages {
+		if dir == "" || strings.EqualFold(lang.Dir, dir) || strings.EqualFold(lang.Name, dir) {
+			return lang, true
+		}
+	}
+	return Language{}, false
+}
+
+// Files resolves the .d and .tra files used with the given language.
+// Paths in the .tp2 are relative to the game folder, which is taken to be
+// the folder of the .tp2 or its parent, whichever contains the file.
+func (l *Layout) Files(lang Language) Files {
+	base := filepath.Dir(l.TP2)
+	roots := []string{base, filepath.Dir(base)}
+
+	vars := strings.NewReplacer(
+		"%s", lang.Dir,
+		"%LANGUAGE%", lang.Dir,
+		"%MOD_FOLDER%", l.ModFolder,
+	)
+
+	out := Files{Tras: map[string][]string{}}
+	seenTra := map[string]bool{}
+	seenD := map[string]bool{}
+
+	find := func(p string) (string, bool) {
+		p = filepath.FromSlash(strings.ReplaceAll(vars.Replace(p), `\`, "/"))
+		for _, r := range roots {
+			full := filepath.Join(r, p)
+			if _, err := os.Stat(full); err == nil {
+				return full, true
+			}
+		}
+		return p, false
+	}
+	resolve := func(p string) (string, bool) {
+		full, ok := find(p)
+		if !ok {
+			out.Missing = append(out.Missing, full)
+		}
+		return full, ok
+	}
+	// addTras resolves tra paths and returns their keys; optional files
+	// that don't exist are skipped silently
+	addTras := func(paths []string, optional bool) []string {
+		var keys []string
+		for _, p := range paths {
+			full, ok := find(p)
+			if !ok {
+				if !optional {
+					out.Missing = append(out.Missing, full)
+				}
+				continue
+			}
+			if !seenTra[full] {
+				seenTra[full] = true
+				out.Tra = append(out.Tra, full)
+			}
+			keys = append(keys, fileKey(full))
+		}
+		return keys
+	}
+
+	langKeys := addTras(lang.Tras, false)
+
+	for _, c := range l.Compiles {
+		scope := append(append([]string(nil), langKeys...), addTras(c.Tras, false)...)
+		using := addTras(c.Using, false)
+
+		for _, p := range c.D {
+			full, ok := resolve(p)
+			if !ok {
+				continue
+			}
+			for _, dFile := range dFiles(full) {
+				key := fileKey(dFile)
+				if !seenD[dFile] {
+					seenD[dFile] = true
+					out.D = append(out.D, dFile)
+				}
+
+				keys := append(append([]string(nil), scope...), using...)
+				if len(c.Using) == 0 {
+					for _, auto := range l.AutoTra {
+						// AUTO_TRA ~mymod/%s~ loads mymod/<lang>/<d name>.tra
+						keys = append(keys, addTras([]string{auto + "/" + key + ".tra"}, true)...)
+					}
+				}
+				out.Tras[key] = appendLast(out.Tras[key], keys...)
+			}
+		}
+	}
+
+	return out
+}
+
+// dFiles expands a COMPILE argument: a .d file or a folder of .d files.
+func dFiles(path string) []string {
+	st, err := os.Stat(path)
+	if err != nil || !st.IsDir() {
+		return []string{path}
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil
+	}
+	var out []string
+	for _, ent := range entries {
+		if !ent.IsDir() && strings.EqualFold(filepath.Ext(ent.Name()), ".d") {
+			out = append(out, filepath.Join(path, ent.Name()))
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// fileKey is the lower-case base name used as key by d.ParseDir and tra.ParseDir.
+func fileKey(path string) string {
+	return strings.ToLower(helpers.BaseKey(filepath.Base(path)))
+}
+
+// appendLast appends keys, moving ones already present to the end so the
+// last load of a .tra keeps overriding the earlier ones.
+func appendLast(dst []string, keys ...string) []string {
+	for _, k := range keys {
+		dst = slices.DeleteFunc(dst, func(v string) bool { return v == k })
+		dst = append(dst, k)
+	}
+	return dst
+}
@@ -0,0 +1,156 @@
+package tp2
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseLayout(t *testing.T) {
+	input := `BACKUP ~mymod/backup~
+AUTHOR ~someone~
+AUTO_TRA ~mymod/tra/%s~
+
+LANGUAGE ~English~ ~english~ ~mymod/tra/english/setup.tra~
+LANGUAGE ~Polski~ ~polish~ ~mymod/tra/polish/setup.tra~
+
+BEGIN @1
+LOAD_TRA ~mymod/tra/%LANGUAGE%/shared.tra~
+COMPILE EVALUATE_BUFFER ~mymod/dlg/bdnpc.d~ ~mymod/dlg/bdnpcj.d~
+  USING ~mymod/tra/%s/bdnpc_dlg.tra~ ~mymod/tra/%s/dialogs.tra~
+COPY ~mymod/itm~ ~override~
+
+BEGIN @2
+COMPILE ~mymod/dlg/other~
+`
+	l, err := ParseLayout(strings.NewReader(input), "setup-mymod.tp2")
+	if err != nil {
+		t.Fatalf("ParseLayout error: %v", err)
+	}
+
+	if l.ModFolder != "mymod" {
+		t.Fatalf("ModFolder: got %q", l.ModFolder)
+	}
+	if !reflect.DeepEqual(l.AutoTra, []string{"mymod/tra/%s"}) {
+		t.Fatalf("AutoTra: got %v", l.AutoTra)
+	}
+
+	wantLangs := []Language{
+		{Name: "English", Dir: "english", Tras: []string{"mymod/tra/english/setup.tra"}},
+		{Name: "Polski", Dir: "polish", Tras: []string{"mymod/tra/polish/setup.tra"}},
+	}
+	if !reflect.DeepEqual(l.Languages, wantLangs) {
+		t.Fatalf("Languages:\n got: %+v\nwant: %+v", l.Languages, wantLangs)
+	}
+
+	if len(l.Compiles) != 2 {
+		t.Fatalf("expected 2 COMPILEs, got %+v", l.Compiles)
+	}
+	c := l.Compiles[0]
+	if !reflect.DeepEqual(c.D, []string{"mymod/dlg/bdnpc.d", "mymod/dlg/bdnpcj.d"}) ||
+		!reflect.DeepEqual(c.Using, []string{"mymod/tra/%s/bdnpc_dlg.tra", "mymod/tra/%s/dialogs.tra"}) ||
+		!reflect.DeepEqual(c.Tras, []string{"mymod/tra/%LANGUAGE%/shared.tra"}) ||
+		c.Pos.Line != 10 {
+		t.Fatalf("first COMPILE mismatch: %+v", c)
+	}
+	if c2 := l.Compiles[1]; len(c2.Tras) != 0 || len(c2.Using) != 0 || !reflect.DeepEqual(c2.D, []string{"mymod/dlg/other"}) {
+		t.Fatalf("second COMPILE mismatch: %+v", c2)
+	}
+
+	if lang, ok := l.Language("POLISH"); !ok || lang.Name != "Polski" {
+		t.Fatalf("Language(POLISH) = %+v, %v", lang, ok)
+	}
+	if lang, ok := l.Language(""); !ok || lang.Dir != "english" {
+		t.Fatalf("Language(\"\") should pick the first language, got %+v", lang)
+	}
+	if _, ok := l.Language("german"); ok {
+		t.Fatalf("Language(german) should not exist")
+	}
+}
+
+func TestDiscover_Files(t *testing.T) {
+	game := t.TempDir()
+	files := map[string]string{
+		"setup-mymod.tp2": `AUTO_TRA ~%MOD_FOLDER%/tra/%s~
+LANGUAGE ~English~ ~english~ ~mymod/tra/english/setup.tra~
+BEGIN @1
+COMPILE ~mymod/dlg/bdnpc.d~ USING ~mymod/tra/%s/bdnpc_dlg.tra~
+COMPILE ~mymod/dlg/banter~
+COMPILE ~mymod/dlg/%tutu_var%missing.d~
+`,
+		"mymod/tra/english/setup.tra":     "@1 = ~Component~\n",
+		"mymod/tra/english/bdnpc_dlg.tra": "@1 = ~Hi~\n",
+		"mymod/tra/english/bdnpcb.tra":    "@1 = ~Banter~\n",
+		"mymod/dlg/bdnpc.d":               "BEGIN BDNPC\n",
+		"mymod/dlg/banter/bdnpcb.d":       "BEGIN BDNPCB\n",
+		"mymod/dlg/banter/bdnpcx.d":       "BEGIN BDNPCX\n",
+	}
+	for name, content := range files {
+		full := filepath.Join(game, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	l, err := Discover(game)
+	if err != nil {
+		t.Fatalf("Discover error: %v", err)
+	}
+	lang, _ := l.Language("")
+	got := l.Files(lang)
+
+	rel := func(paths []string) []string {
+		var out []string
+		for _, p := range paths {
+			r, _ := filepath.Rel(game, p)
+			out = append(out, filepath.ToSlash(r))
+		}
+		return out
+	}
+
+	wantD := []string{"mymod/dlg/bdnpc.d", "mymod/dlg/banter/bdnpcb.d", "mymod/dlg/banter/bdnpcx.d"}
+	if !reflect.DeepEqual(rel(got.D), wantD) {
+		t.Fatalf("D:\n got: %v\nwant: %v", rel(got.D), wantD)
+	}
+	wantTra := []string{"mymod/tra/english/setup.tra", "mymod/tra/english/bdnpc_dlg.tra", "mymod/tra/english/bdnpcb.tra"}
+	if !reflect.DeepEqual(rel(got.Tra), wantTra) {
+		t.Fatalf("Tra:\n got: %v\nwant: %v", rel(got.Tra), wantTra)
+	}
+
+	wantTras := map[string][]string{
+		"bdnpc":  {"setup", "bdnpc_dlg"},
+		"bdnpcb": {"setup", "bdnpcb"}, // AUTO_TRA
+		"bdnpcx": {"setup"},           // no AUTO_TRA file, not reported missing
+	}
+	if !reflect.DeepEqual(got.Tras, wantTras) {
+		t.Fatalf("Tras:\n got: %v\nwant: %v", got.Tras, wantTras)
+	}
+
+	if len(got.Missing) != 1 || !strings.Contains(got.Missing[0], "%tutu_var%") {
+		t.Fatalf("expected only the unresolvable COMPILE to be missing, got %v", got.Missing)
+	}
+}
+
+func TestDiscover_Errors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := Discover(dir); err == nil {
+		t.Fatalf("expected error for a folder without .tp2")
+	}
+
+	for _, name := range []string{"a.tp2", "setup-b.tp2"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if _, err := Discover(dir); err == nil {
+		t.Fatalf("expected error for several .tp2 files")
+	}
+	if _, err := Discover(filepath.Join(dir, "a.tp2")); err != nil {
+		t.Fatalf("explicit .tp2 path: %v", err)
+	}
+}
@@ -0,0 +1,364 @@
+// Package tp2 is a lightweight scanner for WeiDU setup code (.tp2, .tpa,
+// .tph). It does not interpret the language; it only records where @id
+// references are used, so strings that never appear in a .d file can be
+// shown with some context in the export.
+package tp2
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	helpers "github.com/maciejjwojcik/dlg2csv/internal/utils"
+)
+
+// Usage is a single @id reference in setup code.
+type Usage struct {
+	TraID int
+	Pos   helpers.Pos
+
+	// Component is the number of the enclosing BEGIN component (honouring
+	// DESIGNATED), or -1 outside components, e.g. in .tpa/.tph libraries.
+	Component int
+
+	// Action is the string-consuming action, e.g. "SAY DESC", "STRING_SET"
+	// or "BEGIN" for component names; empty if it isn't recognised.
+	Action string
+
+	// Target is the file patched by SAY actions, e.g. "AC#SWRD.ITM".
+	Target string
+
+	// Tras lists the base names of the .tra files in scope (LANGUAGE and
+	// LOAD_TRA). Empty when the scope is unknown.
+	Tras []string
+}
+
+// actions that take a string (@id) argument
+var stringActions = map[string]bool{
+	"SUBCOMPONENT":        true,
+	"GROUP":               true,
+	"SAY":                 true,
+	"SAY_EVALUATED":       true,
+	"STRING_SET":          true,
+	"STRING_SET_EVALUATE": true,
+	"STRING_SET_RANGE":    true,
+	"ADD_JOURNAL":         true,
+	"RESOLVE_STR_REF":     true,
+	"PRINT":               true,
+	"PATCH_PRINT":         true,
+	"WARN":                true,
+	"PATCH_WARN":          true,
+	"FAIL":                true,
+	"PATCH_FAIL":          true,
+	"REQUIRE_PREDICATE":   true,
+	"REQUIRE_COMPONENT":   true,
+	"FORBID_COMPONENT":    true,
+	"REQUIRE_FILE":        true,
+	"FORBID_FILE":         true,
+	"SPRINT":              true,
+	"TEXT_SPRINT":         true,
+	"OUTER_SPRINT":        true,
+	"OUTER_TEXT_SPRINT":   true,
+	"ADD_SECTYPE":         true,
+}
+
+var copyActions = map[string]bool{
+	"COPY":                 true,
+	"COPY_EXISTING":        true,
+	"COPY_LARGE":           true,
+	"COPY_EXISTING_REGEXP": true,
+}
+
+var (
+	reTraRef  = regexp.MustCompile(`^@(\d+)$`)
+	reKeyword = regexp.MustCompile(`^[A-Z_][A-Z0-9_]*$`)
+)
+
+// token is a word or string literal (delimiters stripped) of setup code.
+type token struct {
+	Text string
+	Str  bool
+	Line int
+	Col  int
+}
+
+// ParseDir scans all .tp2/.tpa/.tph files below dir. Positions use paths
+// relative to dir.
+func ParseDir(dir string) ([]Usage, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, ent os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ent.IsDir() {
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".tp2", ".tpa", ".tph":
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	var out []Usage
+	for _, path := range files {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			rel = path
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		usages, err := ParseReader(f, filepath.ToSlash(rel))
+		_ = f.Close()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, usages...)
+	}
+	return out, nil
+}
+
+// ParseReader scans one setup file.
+func ParseReader(r io.Reader, fileName string) ([]Usage, error) {
+	tokens, err := tokenize(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		out []Usage
+
+		component      = -1
+		componentStart = 0 // index in out of the first usage of the component
+		nextComponent  = 0
+
+		langTras []string
+		compTras []string
+
+		action string
+		target string
+		lastLn int
+	)
+
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		lineStart := tok.Line != lastLn
+		lastLn = tok.Line
+
+		if tok.Str {
+			continue
+		}
+		word := tok.Text
+
+		if m := reTraRef.FindStringSubmatch(word); m != nil {
+			id, _ := strconv.Atoi(m[1])
+			u := Usage{
+				TraID:     id,
+				Pos:       helpers.Pos{File: fileName, Line: tok.Line, Col: tok.Col},
+				Component: component,
+				Action:    action,
+				Tras:      append(append([]string(nil), langTras...), compTras...),
+			}
+			if strings.HasPrefix(action, "SAY") {
+				u.Target = target
+			}
+			out = append(out, u)
+			continue
+		}
+
+		if !reKeyword.MatchString(word) {
+			continue
+		}
+
+		switch {
+		case isComponentBegin(tokens, i, lineStart):
+			component = nextComponent
+			nextComponent++
+			componentStart = len(out)
+			compTras = nil
+			target = ""
+			action = "BEGIN"
+
+		case word == "DESIGNATED" && i+1 < len(tokens):
+			if n, err := strconv.Atoi(tokens[i+1].Text); err == nil && component >= 0 {
+				component = n
+				nextComponent = n + 1
+				for j := componentStart; j < len(out); j++ {
+					out[j].Component = n
+				}
+				i++
+			}
+
+		case word == "LANGUAGE":
+			langTras = appendTras(langTras, tokens[i+1:])
+
+		case word == "LOAD_TRA":
+			compTras = appendTras(compTras, tokens[i+1:])
+
+		case copyActions[word]:
+			if i+1 < len(tokens) {
+				target = strings.ToUpper(baseName(tokens[i+1].Text))
+			}
+			action = ""
+
+		case word == "SAY" || word == "SAY_EVALUATED":
+			action = word
+			if i+1 < len(tokens) && tokens[i+1].Line == tok.Line {
+				action += " " + tokens[i+1].Text
+				i++
+			}
+
+		case stringActions[word]:
+			action = word
+
+		case lineStart:
+			// another statement; don't attribute following refs to the
+			// previous action
+			action = ""
+		}
+	}
+
+	return out, nil
+}
+
+// isComponentBegin reports whether tokens[i] starts a component:
+// BEGIN @1 / BEGIN ~name~ at the start of a line, unlike ACTION_IF ... BEGIN.
+func isComponentBegin(tokens []token, i int, lineStart bool) bool {
+	if tokens[i].Str || tokens[i].Text != "BEGIN" || !lineStart || i+1 >= len(tokens) {
+		return false
+	}
+	next := tokens[i+1]
+	return next.Line == tokens[i].Line && (next.Str || reTraRef.MatchString(next.Text))
+}
+
+// appendTras adds the base names of the .tra paths among the string
+// literals following a LANGUAGE / LOAD_TRA keyword.
+func appendTras(dst []string, rest []token) []string {
+	for _, t := range rest {
+		if !t.Str {
+			break
+		}
+		if strings.EqualFold(filepath.Ext(t.Text), ".tra") {
+			key := strings.ToLower(helpers.BaseKey(filepath.Base(filepath.ToSlash(t.Text))))
+			if !slices.Contains(dst, key) {
+				dst = append(dst, key)
+			}
+		}
+	}
+	return dst
+}
+
+// baseName returns the last element of a WeiDU path, which may use either
+// slash, e.g. "%MOD_FOLDER%/itm/ac#swrd.itm" -> "ac#swrd.itm".
+func baseName(p string) string {
+	return path.Base(strings.ReplaceAll(p, `\`, "/"))
+}
+
+// tokenize splits setup code into words and string literals, dropping
+// // and /* */ comments. Strings may be delimited by ~, " or ~~~~~.
+func tokenize(r io.Reader) ([]token, error) {
+	data, err := io.ReadAll(bufio.NewReader(r))
+	if err != nil {
+		return nil, err
+	}
+	s := string(data)
+
+	var (
+		out       []token
+		line, col = 1, 1
+	)
+	advance := func(n int) {
+		for _, ch := range s[:n] {
+			if ch == '\n' {
+				line++
+				col = 1
+			} else {
+				col++
+			}
+		}
+		s = s[n:]
+	}
+
+	for len(s) > 0 {
+		switch {
+		case s[0] == ' ' || s[0] == '\t' || s[0] == '\r' || s[0] == '\n' ||
+			s[0] == '(' || s[0] == ')' || s[0] == '=' || s[0] == ',':
+			advance(1)
+
+		case strings.HasPrefix(s, "//"):
+			end := strings.IndexByte(s, '\n')
+			if end < 0 {
+				end = len(s)
+			}
+			advance(end)
+
+		case strings.HasPrefix(s, "/*"):
+			end := strings.Index(s[2:], "*/")
+			if end < 0 {
+				return out, nil
+			}
+			advance(end + 4)
+
+		case strings.HasPrefix(s, "~~~~~") || s[0] == '~' || s[0] == '"':
+			delim := s[:1]
+			if strings.HasPrefix(s, "~~~~~") {
+				delim = "~~~~~"
+			}
+			end := strings.Index(s[len(delim):], delim)
+			if end < 0 {
+				return out, nil // unterminated string, nothing more to scan
+			}
+			out = append(out, token{Text: s[len(delim) : len(delim)+end], Str: true, Line: line, Col: col})
+			advance(end + 2*len(delim))
+
+		default:
+			end := strings.IndexAny(s, " \t\r\n()=,~\"")
+			if end < 0 {
+				end = len(s)
+			}
+			out = append(out, token{Text: s[:end], Line: line, Col: col})
+			advance(end)
+		}
+	}
+	return out, nil
+}
+
+// Index looks up setup-code usages by .tra file and @id.
+type Index struct {
+	byID map[int][]Usage
+}
+
+// NewIndex groups usages by @id.
+func NewIndex(usages []Usage) *Index {
+	ix := &Index{byID: map[int][]Usage{}}
+	for _, u := range usages {
+		ix.byID[u.TraID] = append(ix.byID[u.TraID], u)
+	}
+	return ix
+}
+
+// Usages returns where @id of the .tra file traKey (base name, e.g.
+// "setup") is used. Usages whose .tra scope is unknown match every file.
+func (ix *Index) Usages(traKey string, id int) []Usage {
+	var out []Usage
+	for _, u := range ix.byID[id] {
+		if len(u.Tras) == 0 || slices.Contains(u.Tras, strings.ToLower(traKey)) {
+			out = append(out, u)
+		}
+	}
+	return out
+}
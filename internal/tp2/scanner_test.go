@@ -0,0 +1,131 @@
+package tp2
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseReader_RecordsUsageContext(t *testing.T) {
+	input := `BACKUP ~mymod/backup~
+AUTHOR ~someone~
+
+LANGUAGE ~English~ ~english~ ~mymod/tra/english/setup.tra~
+
+BEGIN @1 // component name
+LOAD_TRA ~mymod/tra/%LANGUAGE%/items.tra~
+COPY ~mymod/itm/ac#swrd.itm~ ~override~
+  SAY NAME1 @10
+  SAY DESC @11 /* @999 in a comment */
+STRING_SET 12345 @12
+ADD_JOURNAL @13
+  @14 USING ~mymod/tra/%LANGUAGE%/journal.tra~
+
+BEGIN ~Second~ DESIGNATED 100
+OUTER_SET strref = RESOLVE_STR_REF(@20)
+PRINT ~@998 is just text~
+ACTION_IF FILE_EXISTS_IN_GAME ~x.itm~ BEGIN
+  OUTER_SET y = 1 + @21
+END
+`
+	usages, err := ParseReader(strings.NewReader(input), "setup-mymod.tp2")
+	if err != nil {
+		t.Fatalf("ParseReader error: %v", err)
+	}
+
+	type want struct {
+		id, line, component int
+		action, target      string
+	}
+	wants := []want{
+		{1, 6, 0, "BEGIN", ""},
+		{10, 9, 0, "SAY NAME1", "AC#SWRD.ITM"},
+		{11, 10, 0, "SAY DESC", "AC#SWRD.ITM"},
+		{12, 11, 0, "STRING_SET", ""},
+		{13, 12, 0, "ADD_JOURNAL", ""},
+		{14, 13, 0, "ADD_JOURNAL", ""},
+		{20, 16, 100, "RESOLVE_STR_REF", ""},
+		{21, 19, 100, "", ""},
+	}
+	if len(usages) != len(wants) {
+		t.Fatalf("expected %d usages, got %d: %+v", len(wants), len(usages), usages)
+	}
+	for i, w := range wants {
+		u := usages[i]
+		if u.TraID != w.id || u.Pos.Line != w.line || u.Component != w.component || u.Action != w.action || u.Target != w.target {
+			t.Fatalf("usage[%d] mismatch:\n got: @%d line=%d comp=%d action=%q target=%q\nwant: %+v",
+				i, u.TraID, u.Pos.Line, u.Component, u.Action, u.Target, w)
+		}
+	}
+
+	if !reflect.DeepEqual(usages[1].Tras, []string{"setup", "items"}) {
+		t.Fatalf("expected LANGUAGE + LOAD_TRA scope, got %v", usages[1].Tras)
+	}
+	if !reflect.DeepEqual(usages[6].Tras, []string{"setup"}) {
+		t.Fatalf("LOAD_TRA scope should end with the component, got %v", usages[6].Tras)
+	}
+	if usages[1].Pos.File != "setup-mymod.tp2" || usages[1].Pos.Col != 13 {
+		t.Fatalf("unexpected position: %+v", usages[1].Pos)
+	}
+}
+
+func TestParseReader_FiveTildeStrings(t *testing.T) {
+	input := "PRINT ~~~~~text with ~tildes~ and @5~~~~~\nPRINT @6\n"
+
+	usages, err := ParseReader(strings.NewReader(input), "lib.tpa")
+	if err != nil {
+		t.Fatalf("ParseReader error: %v", err)
+	}
+	if len(usages) != 1 || usages[0].TraID != 6 || usages[0].Pos.Line != 2 {
+		t.Fatalf("unexpected usages: %+v", usages)
+	}
+	if usages[0].Component != -1 || usages[0].Tras != nil {
+		t.Fatalf("library usage should have no component or tra scope: %+v", usages[0])
+	}
+}
+
+func TestParseDir_WalksLibraries(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "lib"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	files := map[string]string{
+		"setup-mymod.tp2": "BEGIN @1\n",
+		"lib/items.tpa":   "SAY DESC @2\n",
+		"lib/notes.txt":   "@3\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	usages, err := ParseDir(dir)
+	if err != nil {
+		t.Fatalf("ParseDir error: %v", err)
+	}
+	if len(usages) != 2 {
+		t.Fatalf("expected 2 usages, got %+v", usages)
+	}
+	if usages[0].Pos.File != "lib/items.tpa" || usages[1].Pos.File != "setup-mymod.tp2" {
+		t.Fatalf("unexpected files: %q, %q", usages[0].Pos.File, usages[1].Pos.File)
+	}
+}
+
+func TestIndex_Usages(t *testing.T) {
+	ix := NewIndex([]Usage{
+		{TraID: 1, Tras: []string{"setup"}},
+		{TraID: 1, Tras: []string{"items"}},
+		{TraID: 1}, // unknown scope
+		{TraID: 2, Tras: []string{"setup"}},
+	})
+
+	if got := ix.Usages("SETUP", 1); len(got) != 2 {
+		t.Fatalf("expected setup + unknown scope usages, got %+v", got)
+	}
+	if got := ix.Usages("dialogs", 2); len(got) != 0 {
+		t.Fatalf("expected no usages, got %+v", got)
+	}
+}
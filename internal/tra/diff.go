@@ -0,0 +1,68 @@
+package tra
+
+import "sort"
+
+// ChangeKind tells how a string differs between two versions of a .tra.
+type ChangeKind string
+
+const (
+	Added   ChangeKind = "ADDED"
+	Removed ChangeKind = "REMOVED"
+	Changed ChangeKind = "CHANGED"
+)
+
+// Change is a string that differs between two versions of a mod.
+type Change struct {
+	Kind ChangeKind
+	File string // .tra key, e.g. "bdnpc"
+	ID   string
+	Old  string // empty for Added
+	New  string // empty for Removed
+}
+
+// Diff compares two sets of .tra files, e.g. the English sources of two
+// releases of a mod, ordered by file and id.
+func Diff(old, new TraByFile) []Change {
+	keys := map[string]bool{}
+	for k := range old {
+		keys[k] = true
+	}
+	for k := range new {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	var out []Change
+	for _, k := range sorted {
+		o, n := old[k].Texts, new[k].Texts
+
+		ids := make([]string, 0, len(o)+len(n))
+		for id := range o {
+			ids = append(ids, id)
+		}
+		for id := range n {
+			if _, ok := o[id]; !ok {
+				ids = append(ids, id)
+			}
+		}
+		SortIDs(ids)
+
+		for _, id := range ids {
+			ot, inOld := o[id]
+			nt, inNew := n[id]
+			switch {
+			case !inOld:
+				out = append(out, Change{Kind: Added, File: k, ID: id, New: nt})
+			case !inNew:
+				out = append(out, Change{Kind: Removed, File: k, ID: id, Old: ot})
+			case ot != nt:
+				out = append(out, Change{Kind: Changed, File: k, ID: id, Old: ot, New: nt})
+			}
+		}
+	}
+	return out
+}
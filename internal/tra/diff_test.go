@@ -0,0 +1,36 @@
+package tra
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	old := TraByFile{
+		"bdnpc": NewTra(map[string]string{"1": "Hello.", "2": "Bye.", "10": "Old"}),
+		"items": NewTra(map[string]string{"1": "Sword"}),
+	}
+	new := TraByFile{
+		"bdnpc":  NewTra(map[string]string{"1": "Hello.", "2": "Goodbye.", "3": "New line"}),
+		"banter": NewTra(map[string]string{"1": "Hi"}),
+	}
+
+	want := []Change{
+		{Kind: Added, File: "banter", ID: "1", New: "Hi"},
+		{Kind: Changed, File: "bdnpc", ID: "2", Old: "Bye.", New: "Goodbye."},
+		{Kind: Added, File: "bdnpc", ID: "3", New: "New line"},
+		{Kind: Removed, File: "bdnpc", ID: "10", Old: "Old"},
+		{Kind: Removed, File: "items", ID: "1", Old: "Sword"},
+	}
+	if got := Diff(old, new); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Diff:\n got: %+v\nwant: %+v", got, want)
+	}
+}
+
+func TestSortIDs(t *testing.T) {
+	ids := []string{"10", "b", "2", "a", "1"}
+	SortIDs(ids)
+	if want := []string{"1", "2", "10", "a", "b"}; !reflect.DeepEqual(ids, want) {
+		t.Fatalf("SortIDs: got %v, want %v", ids, want)
+	}
+}
@@ -2,13 +2,18 @@ package tra
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
+	"github.com/maciejjwojcik/dlg2csv/internal/charset"
 	helpers "github.com/maciejjwojcik/dlg2csv/internal/utils"
 )
 
@@ -16,6 +21,36 @@ type TraByFile map[string]Tra
 
 type Tra struct {
 	Texts map[string]string
+
+	// Female holds the female variants ("@1 = ~male~ ~female~") by @id.
+	// Texts holds the male (or only) variant.
+	Female map[string]string
+
+	// Pos holds where each @id is defined in the .tra file.
+	// It is nil for Tra values built with NewTra.
+	Pos map[string]helpers.Pos
+
+	// Encoding is the encoding the file was read in (see package charset),
+	// i.e. the one to write translations back in. Empty for ParseReader.
+	Encoding string
+
+	// Duplicates lists the @ids defined more than once, in file order.
+	// Texts and Pos hold the last definition, as WeiDU uses it.
+	Duplicates []Duplicate
+}
+
+// Duplicate is an @id defined a second time in the same file.
+type Duplicate struct {
+	ID            string
+	First, Second helpers.Pos
+	// FirstText and SecondText are the texts of the two definitions.
+	FirstText, SecondText string
+}
+
+// Differs reports whether the two definitions have different texts, i.e.
+// whether taking the last one changes what the game shows.
+func (d Duplicate) Differs() bool {
+	return d.FirstText != d.SecondText
 }
 
 func NewTra(texts map[string]string) Tra {
@@ -32,7 +67,32 @@ func (e *ParseError) Error() string {
 	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Msg)
 }
 
+// Options controls parsing.
+type Options struct {
+	// Encoding of the files (see package charset); empty or charset.Auto
+	// detects it per file.
+	Encoding string
+
+	// Logger receives warnings such as duplicate ids. Nil discards them.
+	Logger *slog.Logger
+
+	// Strict fails on duplicate ids instead of taking the last definition.
+	Strict bool
+}
+
+func (o Options) logger() *slog.Logger {
+	if o.Logger == nil {
+		return slog.New(slog.DiscardHandler)
+	}
+	return o.Logger
+}
+
 func ParseDir(dir string) (TraByFile, error) {
+	return ParseDirWithOptions(dir, Options{})
+}
+
+// ParseDirWithOptions is ParseDir with options.
+func ParseDirWithOptions(dir string, opts Options) (TraByFile, error) {
 	entries, err := os.ReadDir(dir)
 	if err != nil {
 		return nil, err
@@ -53,7 +113,7 @@ func ParseDir(dir string) (TraByFile, error) {
 	out := make(TraByFile, len(files))
 	for _, name := range files {
 		full := filepath.Join(dir, name)
-		tra, err := ParseFile(full)
+		tra, err := ParseFileWithOptions(full, opts)
 		if err != nil {
 			return nil, err
 		}
@@ -63,25 +123,72 @@ func ParseDir(dir string) (TraByFile, error) {
 	return out, nil
 }
 
+// ParseFiles parses the given .tra files, keyed like ParseDir by
+// lower-case base name. Two files with the same key are an error.
+func ParseFiles(paths []string) (TraByFile, error) {
+	return ParseFilesWithOptions(paths, Options{})
+}
+
+// ParseFilesWithOptions is ParseFiles with options.
+func ParseFilesWithOptions(paths []string, opts Options) (TraByFile, error) {
+	out := make(TraByFile, len(paths))
+	seen := make(map[string]string, len(paths)) // key -> path
+	for _, path := range paths {
+		k := strings.ToLower(helpers.BaseKey(filepath.Base(path)))
+		if first, ok := seen[k]; ok {
+			return nil, fmt.Errorf("%s and %s have the same name, %s", first, path, k)
+		}
+		seen[k] = path
+
+		tra, err := ParseFileWithOptions(path, opts)
+		if err != nil {
+			return nil, err
+		}
+		out[k] = *tra
+	}
+	return out, nil
+}
+
 func ParseFile(path string) (*Tra, error) {
-	f, err := os.Open(path)
+	return ParseFileWithOptions(path, Options{})
+}
+
+// ParseFileWithOptions parses a .tra file in opts.Encoding, converting it
+// to UTF-8. With charset.Auto the encoding is detected from the BOM, the
+// content and the language folder in path (e.g. "polish" -> cp1250).
+func ParseFileWithOptions(path string, opts Options) (*Tra, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
-	defer func() {
-		if err := f.Close(); err != nil {
-			return
-		}
-	}()
 
-	return ParseReader(f, filepath.Base(path))
+	text, used, err := charset.Decode(data, opts.Encoding, path)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	tra, err := ParseReaderWithOptions(bytes.NewReader(text), filepath.Base(path), opts)
+	if err != nil {
+		return nil, err
+	}
+	tra.Encoding = used
+	return tra, nil
 }
 
 func ParseReader(r io.Reader, fileName string) (*Tra, error) {
+	return ParseReaderWithOptions(r, fileName, Options{})
+}
+
+// ParseReaderWithOptions is ParseReader with options; opts.Encoding is
+// ignored, r must be UTF-8.
+func ParseReaderWithOptions(r io.Reader, fileName string, opts Options) (*Tra, error) {
+	log := opts.logger()
 	sc := bufio.NewScanner(r)
 	sc.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
 
 	out := make(map[string]string)
+	female := make(map[string]string)
+	positions := make(map[string]helpers.Pos)
 
 	type mode int
 	const (
@@ -89,27 +196,88 @@ func ParseReader(r io.Reader, fileName string) (*Tra, error) {
 		modeReadMale
 		modeReadMaleQuote
 		modeReadMaleTilde
+		modeReadFemaleQuote
+		modeReadFemaleTilde
+		modeAwaitFemale // male text read, the female may start on a later line
 	)
 
 	var (
-		m      = modeNormal
-		curID  string
-		b      strings.Builder
-		lineNo int
+		m          = modeNormal
+		curID      string
+		curPos     helpers.Pos
+		b          strings.Builder
+		lineNo     int
+		duplicates []Duplicate
 	)
 
 	flushMale := func() error {
-		if _, exists := out[curID]; exists {
-			fmt.Printf("duplicate string id @%s in file", curID)
-		}
-		out[curID] = b.String()
+		text := b.String()
 		b.Reset()
+		if prev, exists := out[curID]; exists {
+			dup := Duplicate{ID: curID, First: positions[curID], Second: curPos, FirstText: prev, SecondText: text}
+			if opts.Strict {
+				return &ParseError{File: fileName, Line: curPos.Line, Msg: fmt.Sprintf("@%s already defined at line %d", curID, dup.First.Line)}
+			}
+			log.Warn("duplicate string id, the last definition wins", "file", fileName, "line", curPos.Line,
+				"id", "@"+curID, "first_line", dup.First.Line, "differs", dup.Differs())
+			duplicates = append(duplicates, dup)
+		}
+		out[curID] = text
+		delete(female, curID)
+		positions[curID] = curPos
 		return nil
 	}
 
+	// startFemale reads the female variant following the male text, if
+	// any: ~male~ [SOUND] ~female~ [SOUND]. Nothing left on the line
+	// means the female variant may start on the next non-blank one.
+	startFemale := func(rest string) {
+		rest = strings.TrimSpace(rest)
+		if strings.HasPrefix(rest, "[") {
+			if end := strings.IndexByte(rest, ']'); end >= 0 {
+				rest = strings.TrimSpace(rest[end+1:])
+			}
+		}
+		var delim string
+		switch {
+		case rest == "":
+			m = modeAwaitFemale
+			return
+		case strings.HasPrefix(rest, "~"):
+			delim, m = "~", modeReadFemaleTilde
+		case strings.HasPrefix(rest, `"`):
+			delim, m = `"`, modeReadFemaleQuote
+		default:
+			return
+		}
+		rest = rest[1:]
+		if before, _, ok := strings.Cut(rest, delim); ok {
+			female[curID] = before
+			m = modeNormal
+			return
+		}
+		b.WriteString(rest)
+		b.WriteString("\n")
+	}
+
 	for sc.Scan() {
 		lineNo++
 		line := sc.Text()
+		if lineNo == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+
+		if m == modeAwaitFemale {
+			trim := strings.TrimSpace(line)
+			if trim == "" {
+				continue
+			}
+			m = modeNormal
+			if strings.HasPrefix(trim, "~") || strings.HasPrefix(trim, `"`) || strings.HasPrefix(trim, "[") {
+				startFemale(trim)
+				continue
+			}
+		}
 
 		switch m {
 		case modeNormal:
@@ -147,6 +315,7 @@ func ParseReader(r io.Reader, fileName string) (*Tra, error) {
 			}
 
 			curID = id
+			curPos = helpers.Pos{File: fileName, Line: lineNo, Col: strings.IndexByte(line, '@') + 1}
 
 			if strings.HasPrefix(right, "~") {
 				right = right[1:]
@@ -155,6 +324,7 @@ func ParseReader(r io.Reader, fileName string) (*Tra, error) {
 					if err := flushMale(); err != nil {
 						return nil, err
 					}
+					startFemale(right[end+1:])
 					continue
 				}
 				m = modeReadMaleTilde
@@ -170,6 +340,7 @@ func ParseReader(r io.Reader, fileName string) (*Tra, error) {
 				if err := flushMale(); err != nil {
 					return nil, err
 				}
+				startFemale(right[end+1:])
 				continue
 			}
 			m = modeReadMaleQuote
@@ -184,18 +355,35 @@ func ParseReader(r io.Reader, fileName string) (*Tra, error) {
 					return nil, err
 				}
 				m = modeNormal
+				startFemale(line[end+1:])
 				continue
 			}
 			b.WriteString(line)
 			b.WriteString("\n")
 			continue
 		case modeReadMaleQuote:
-			if before, _, ok := strings.Cut(line, "\""); ok {
+			if before, after, ok := strings.Cut(line, "\""); ok {
 				b.WriteString(before)
 				if err := flushMale(); err != nil {
 					return nil, err
 				}
 				m = modeNormal
+				startFemale(after)
+				continue
+			}
+			b.WriteString(line)
+			b.WriteString("\n")
+			continue
+		case modeReadFemaleTilde, modeReadFemaleQuote:
+			delim := "~"
+			if m == modeReadFemaleQuote {
+				delim = `"`
+			}
+			if before, _, ok := strings.Cut(line, delim); ok {
+				b.WriteString(before)
+				female[curID] = b.String()
+				b.Reset()
+				m = modeNormal
 				continue
 			}
 			b.WriteString(line)
@@ -207,15 +395,49 @@ func ParseReader(r io.Reader, fileName string) (*Tra, error) {
 	if err := sc.Err(); err != nil {
 		return nil, err
 	}
-	if m == modeReadMaleTilde || m == modeReadMaleQuote {
+	if m != modeNormal && m != modeAwaitFemale {
 		return nil, &ParseError{File: fileName, Line: lineNo, Msg: fmt.Sprintf("unterminated string literal for @%s", curID)}
 	}
 
 	tra := NewTra(out)
+	tra.Female = female
+	tra.Pos = positions
+	tra.Duplicates = duplicates
 
 	return &tra, nil
 }
 
+// HasFemale reports whether @id has a female variant differing from its
+// male text.
+func (t Tra) HasFemale(id string) bool {
+	f, ok := t.Female[id]
+	return ok && f != t.Texts[id]
+}
+
+// ASCII reports whether all texts of the file are plain ASCII, so that
+// the file reads the same in any code page.
+func (t Tra) ASCII() bool {
+	for _, texts := range []map[string]string{t.Texts, t.Female} {
+		for _, s := range texts {
+			for i := 0; i < len(s); i++ {
+				if s[i] >= utf8.RuneSelf {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// FemaleSource returns the text a female translation of @id translates:
+// the female variant if there is one, the only text otherwise.
+func (t Tra) FemaleSource(id string) string {
+	if t.HasFemale(id) {
+		return t.Female[id]
+	}
+	return t.Texts[id]
+}
+
 func (t Tra) GetTextByID(id *int) string {
 	if id == nil || t.Texts == nil {
 		return ""
@@ -227,3 +449,31 @@ func (t Tra) GetTextByID(id *int) string {
 	}
 	return fmt.Sprintf("#MISSING(@%s)", key)
 }
+
+// IDs returns the ids of the file in SortIDs order.
+func (t Tra) IDs() []string {
+	ids := make([]string, 0, len(t.Texts))
+	for id := range t.Texts {
+		ids = append(ids, id)
+	}
+	SortIDs(ids)
+	return ids
+}
+
+// SortIDs sorts ids numerically, with non-numeric ids last in string order.
+func SortIDs(ids []string) {
+	sort.Slice(ids, func(i, j int) bool {
+		ai, aErr := strconv.Atoi(ids[i])
+		aj, bErr := strconv.Atoi(ids[j])
+		if aErr == nil && bErr == nil {
+			return ai < aj
+		}
+		if aErr == nil {
+			return true
+		}
+		if bErr == nil {
+			return false
+		}
+		return ids[i] < ids[j]
+	})
+}
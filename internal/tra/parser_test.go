@@ -1,6 +1,11 @@
 package tra
 
 import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -24,6 +29,17 @@ func TestNewTra(t *testing.T) {
 	}
 }
 
+func TestTra_ASCII(t *testing.T) {
+	tr := NewTra(map[string]string{"1": "Hello, <CHARNAME>.", "2": "Bye."})
+	if !tr.ASCII() {
+		t.Fatal("plain English should be ASCII")
+	}
+	tr.Female = map[string]string{"2": "Żegnaj."}
+	if tr.ASCII() {
+		t.Fatal("a non-ASCII female variant should count")
+	}
+}
+
 func TestTra_GetTextByID(t *testing.T) {
 	id1 := 1
 	id2 := 2
@@ -194,3 +210,190 @@ func TestParseReader(t *testing.T) {
 		})
 	}
 }
+
+func TestParseReader_RecordsPositions(t *testing.T) {
+	input := "// header\n" +
+		"@1 = ~One~\n" +
+		"  @2 = ~Multi\n" +
+		"line~\n" +
+		"@3 = ~Three~\n"
+
+	got, err := ParseReader(strings.NewReader(input), "x.tra")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[string][2]int{
+		"1": {2, 1},
+		"2": {3, 3},
+		"3": {5, 1},
+	}
+	for id, w := range want {
+		p, ok := got.Pos[id]
+		if !ok {
+			t.Fatalf("missing position for @%s", id)
+		}
+		if p.File != "x.tra" || p.Line != w[0] || p.Col != w[1] {
+			t.Fatalf("@%s position mismatch: got %s:%d:%d, want x.tra:%d:%d", id, p.File, p.Line, p.Col, w[0], w[1])
+		}
+	}
+}
+
+func TestParseReaderWithOptions_LogsDuplicateIDs(t *testing.T) {
+	input := "@1 = ~First~\n" +
+		"@2 = ~Two~\n" +
+		"@1 = ~Second~\n"
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	got, err := ParseReaderWithOptions(strings.NewReader(input), "x.tra", Options{Logger: logger})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got.Texts["1"] != "Second" {
+		t.Fatalf("expected the last definition to win, got %q", got.Texts["1"])
+	}
+
+	out := buf.String()
+	for _, want := range []string{"level=WARN", "file=x.tra", "line=3", "id=@1"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("log %q lacks %q", out, want)
+		}
+	}
+	if strings.Count(out, "\n") != 1 {
+		t.Fatalf("expected one warning, got %q", out)
+	}
+}
+
+func TestParseReader_Female(t *testing.T) {
+	input := "@1 = ~Ready, sir.~ ~Ready, madam.~\n" +
+		"@2 = ~Same~ [SND01] ~Same~ [SND02]\n" +
+		"@3 = ~Multi\nline~ \"Female\nmulti\"\n" +
+		"@4 = ~Male only~ // comment\n" +
+		"@5 = \"Quoted\" ~Female~\n" +
+		"@6 = ~He left.~\n\n    ~She left.~\n" +
+		"@7 = ~Next~ [SND07]\n[SND07F] ~Next line~\n" +
+		"@8 = ~Male~\n@9 = ~Alone~\n"
+
+	got, err := ParseReader(strings.NewReader(input), "x.tra")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := map[string]string{"1": "Ready, madam.", "2": "Same", "3": "Female\nmulti", "5": "Female", "6": "She left.", "7": "Next line"}
+	if len(got.Female) != len(want) {
+		t.Fatalf("Female = %q, want %q", got.Female, want)
+	}
+	for id, w := range want {
+		if got.Female[id] != w {
+			t.Fatalf("Female[%s] = %q, want %q", id, got.Female[id], w)
+		}
+	}
+	if got.Texts["3"] != "Multi\nline" || got.Texts["5"] != "Quoted" || got.Texts["8"] != "Male" || got.Texts["9"] != "Alone" {
+		t.Fatalf("male texts changed: %q", got.Texts)
+	}
+	if !got.HasFemale("1") || got.HasFemale("2") || got.HasFemale("4") {
+		t.Fatalf("HasFemale: 1=%v 2=%v 4=%v", got.HasFemale("1"), got.HasFemale("2"), got.HasFemale("4"))
+	}
+
+	if _, err := ParseReader(strings.NewReader("@1 = ~a~ ~b\n"), "x.tra"); err == nil {
+		t.Fatal("expected an error for an unterminated female variant")
+	}
+}
+
+func TestParseReader_Duplicates(t *testing.T) {
+	input := "@1 = ~First~\n" +
+		"@2 = ~Two~\n" +
+		"@1 = ~Second~\n" +
+		"@2 = ~Two~\n"
+
+	got, err := ParseReader(strings.NewReader(input), "x.tra")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(got.Duplicates) != 2 {
+		t.Fatalf("expected 2 duplicates, got %+v", got.Duplicates)
+	}
+
+	first := got.Duplicates[0]
+	if first.ID != "1" || first.First.Line != 1 || first.Second.Line != 3 ||
+		first.FirstText != "First" || first.SecondText != "Second" || !first.Differs() {
+		t.Fatalf("unexpected duplicate: %+v", first)
+	}
+	if got.Duplicates[1].Differs() {
+		t.Fatalf("@2 has the same text twice: %+v", got.Duplicates[1])
+	}
+	if p := got.Pos["1"]; p.Line != 3 {
+		t.Fatalf("Pos should point at the definition in use, got line %d", p.Line)
+	}
+
+	_, err = ParseReaderWithOptions(strings.NewReader(input), "x.tra", Options{Strict: true})
+	var pe *ParseError
+	if !errors.As(err, &pe) || pe.Line != 3 || !strings.Contains(pe.Msg, "@1 already defined at line 1") {
+		t.Fatalf("strict mode: expected a ParseError at line 3, got %v", err)
+	}
+}
+
+func TestParseFileWithOptions_Encoding(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "tra", "polish")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	files := map[string][]byte{
+		"cp1250.tra": []byte("@1 = ~\xBF\xF3\xB3w~\n"),
+		"bom.tra":    []byte("\xEF\xBB\xBF@1 = ~żółw~\n"),
+		"utf8.tra":   []byte("@1 = ~żółw~\n"),
+	}
+	wantEnc := map[string]string{
+		"cp1250.tra": "cp1250",
+		"bom.tra":    "utf-8-bom",
+		"utf8.tra":   "utf-8",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	for name, enc := range wantEnc {
+		tra, err := ParseFileWithOptions(filepath.Join(dir, name), Options{Encoding: "auto"})
+		if err != nil {
+			t.Fatalf("%s: ParseFileWithOptions error: %v", name, err)
+		}
+		if tra.Texts["1"] != "żółw" || tra.Encoding != enc {
+			t.Fatalf("%s: got %q in %s, want %q in %s", name, tra.Texts["1"], tra.Encoding, "żółw", enc)
+		}
+	}
+
+	// an explicit encoding overrides detection
+	tra, err := ParseFileWithOptions(filepath.Join(dir, "cp1250.tra"), Options{Encoding: "cp1251"})
+	if err != nil {
+		t.Fatalf("ParseFileWithOptions error: %v", err)
+	}
+	if tra.Encoding != "cp1251" || tra.Texts["1"] == "żółw" {
+		t.Fatalf("expected cp1251 decoding, got %q in %s", tra.Texts["1"], tra.Encoding)
+	}
+}
+
+func TestParseFiles_SameName(t *testing.T) {
+	dir := t.TempDir()
+	var paths []string
+	for _, p := range []string{"a/setup.tra", "b/SETUP.TRA"} {
+		full := filepath.Join(dir, p)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte("@1 = ~Hello~\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, full)
+	}
+
+	if _, err := ParseFiles(paths[:1]); err != nil {
+		t.Fatalf("ParseFiles: %v", err)
+	}
+	_, err := ParseFiles(paths)
+	if err == nil || !strings.Contains(err.Error(), "same name") {
+		t.Fatalf("expected an error for two setup.tra files, got %v", err)
+	}
+}
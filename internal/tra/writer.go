@@ -0,0 +1,49 @@
+package tra
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/charset"
+)
+
+// Entry is a single string to write to a .tra file.
+type Entry struct {
+	ID     string
+	Male   string
+	Female string // optional; written as a second literal when set
+}
+
+// Write writes entries as a .tra file in the given encoding (see package
+// charset), one "@id = ~text~ [~female~]" per entry, in the given order.
+func Write(w io.Writer, entries []Entry, enc string) error {
+	var b strings.Builder
+	for _, e := range entries {
+		fmt.Fprintf(&b, "@%s = %s", e.ID, quote(e.Male))
+		if e.Female != "" {
+			b.WriteString(" " + quote(e.Female))
+		}
+		b.WriteString("\n")
+	}
+
+	data, err := charset.Encode([]byte(b.String()), enc)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// quote wraps s in the first WeiDU string delimiter it doesn't contain:
+// ~...~, "..." or ~~~~~...~~~~~.
+func quote(s string) string {
+	switch {
+	case !strings.Contains(s, "~"):
+		return "~" + s + "~"
+	case !strings.Contains(s, `"`):
+		return `"` + s + `"`
+	default:
+		return "~~~~~" + s + "~~~~~"
+	}
+}
@@ -0,0 +1,51 @@
+package tra
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	entries := []Entry{
+		{ID: "1", Male: "Hello"},
+		{ID: "2", Male: "Ready, sir.", Female: "Ready, madam."},
+		{ID: "3", Male: "A ~tilde~"},
+		{ID: "4", Male: "Both ~ and \""},
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, entries, "utf-8"); err != nil {
+		t.Fatalf("Write error: %v", err)
+	}
+
+	want := "@1 = ~Hello~\n" +
+		"@2 = ~Ready, sir.~ ~Ready, madam.~\n" +
+		"@3 = \"A ~tilde~\"\n" +
+		"@4 = ~~~~~Both ~ and \"~~~~~\n"
+	if buf.String() != want {
+		t.Fatalf("unexpected output:\n got: %q\nwant: %q", buf.String(), want)
+	}
+
+	tra, err := ParseReader(strings.NewReader(buf.String()), "out.tra")
+	if err != nil {
+		t.Fatalf("ParseReader error: %v", err)
+	}
+	if tra.Texts["1"] != "Hello" || tra.Texts["3"] != "A ~tilde~" {
+		t.Fatalf("round trip mismatch: %v", tra.Texts)
+	}
+}
+
+func TestWrite_Encoding(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, []Entry{{ID: "1", Male: "żółw"}}, "cp1250"); err != nil {
+		t.Fatalf("Write error: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), []byte("@1 = ~\xBF\xF3\xB3w~\n")) {
+		t.Fatalf("unexpected cp1250 output: % x", buf.Bytes())
+	}
+
+	if err := Write(&buf, []Entry{{ID: "1", Male: "привет"}}, "cp1250"); err == nil {
+		t.Fatalf("expected error for text the code page can't hold")
+	}
+}
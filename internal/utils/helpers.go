@@ -1,6 +1,7 @@
 package helpers
 
 import (
+	"fmt"
 	"path/filepath"
 	"strings"
 )
@@ -9,3 +10,54 @@ func BaseKey(filename string) string {
 	ext := filepath.Ext(filename)
 	return strings.TrimSuffix(filename, ext)
 }
+
+// Pos points at a place in a source file (.d, .tra, ...).
+// Line and Col are 1-based; the zero value means "unknown".
+type Pos struct {
+	File string
+	Line int
+	Col  int
+}
+
+// String renders the position as "file:line", the form used in the
+// Source column of the export (e.g. "02_dialog.d:14").
+func (p Pos) String() string {
+	if p.Line <= 0 {
+		return p.File
+	}
+	return fmt.Sprintf("%s:%d", p.File, p.Line)
+}
+
+// EditDistance is the Levenshtein distance between a and b.
+func EditDistance(a, b []rune) int {
+	d, _ := EditDistanceAtMost(a, b, max(len(a), len(b)))
+	return d
+}
+
+// EditDistanceAtMost is EditDistance for callers that only need
+// distances up to limit: it stops as soon as the distance is known to
+// exceed limit, and then reports false.
+func EditDistanceAtMost(a, b []rune, limit int) (int, bool) {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		rowMin := i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			rowMin = min(rowMin, cur[j])
+		}
+		if rowMin > limit {
+			return rowMin, false
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)], prev[len(b)] <= limit
+}
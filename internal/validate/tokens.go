@@ -0,0 +1,89 @@
+package validate
+
+import (
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	helpers "github.com/maciejjwojcik/dlg2csv/internal/utils"
+)
+
+// reToken matches engine tokens such as <CHARNAME>, <PRO_HESHE> and mod
+// tokens such as <AC#TOKEN>.
+var reToken = regexp.MustCompile(`<[A-Za-z0-9_#-]+>`)
+
+// Tokens returns the tokens of s in order of appearance.
+func Tokens(s string) []string {
+	return reToken.FindAllString(s, -1)
+}
+
+// checkTokens reports a translated cell whose tokens don't match those of
+// its source text. Empty cells are left to the other rules.
+func checkTokens(id, traKey, cell, source, translated string, pos helpers.Pos,
+	report func(rule string, pos helpers.Pos, format string, args ...any)) {
+
+	if translated == "" {
+		return
+	}
+	if problems := tokenProblems(source, translated); len(problems) > 0 {
+		report(RuleTokens, pos, "@%s of %s.tra, %s translation: %s", id, traKey, cell, strings.Join(problems, ", "))
+	}
+}
+
+// tokenProblems lists the tokens of source missing from translated, the
+// tokens translated has in excess, and pairs of them that look like a
+// misspelling, e.g. "<CHARNAM> should be <CHARNAME>".
+func tokenProblems(source, translated string) []string {
+	want := countTokens(source)
+	got := countTokens(translated)
+
+	var missing, extra []string
+	for tok, n := range want {
+		for i := got[tok]; i < n; i++ {
+			missing = append(missing, tok)
+		}
+	}
+	for tok, n := range got {
+		for i := want[tok]; i < n; i++ {
+			extra = append(extra, tok)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(extra)
+
+	var misspelled, unexpected []string
+	for _, e := range extra {
+		i := slices.IndexFunc(missing, func(m string) bool { return similarTokens(e, m) })
+		if i < 0 {
+			unexpected = append(unexpected, "unexpected "+e)
+			continue
+		}
+		misspelled = append(misspelled, e+" should be "+missing[i])
+		missing = append(missing[:i], missing[i+1:]...)
+	}
+
+	out := misspelled
+	for _, m := range missing {
+		out = append(out, "missing "+m)
+	}
+	return append(out, unexpected...)
+}
+
+func countTokens(s string) map[string]int {
+	n := map[string]int{}
+	for _, tok := range Tokens(s) {
+		n[tok]++
+	}
+	return n
+}
+
+// similarTokens reports whether a is likely a misspelling of b: the same
+// token in another case, or at most two edits away.
+func similarTokens(a, b string) bool {
+	a, b = strings.ToUpper(a), strings.ToUpper(b)
+	if a == b {
+		return true
+	}
+	return helpers.EditDistance([]rune(a), []rune(b)) <= 2 && len(b) > 5 // "<" + ">" + at least 4 letters
+}
@@ -0,0 +1,67 @@
+package validate
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/csv"
+	"github.com/maciejjwojcik/dlg2csv/internal/tra"
+	helpers "github.com/maciejjwojcik/dlg2csv/internal/utils"
+)
+
+func TestTokens(t *testing.T) {
+	got := Tokens("Hello <CHARNAME>, <PRO_HESHE> said <AC#NAME> is 1 < 2 > 0.")
+	want := []string{"<CHARNAME>", "<PRO_HESHE>", "<AC#NAME>"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Tokens = %q, want %q", got, want)
+	}
+}
+
+func TestTokenProblems(t *testing.T) {
+	tests := []struct {
+		name       string
+		translated string
+		want       []string
+	}{
+		{"same tokens", "Witaj <CHARNAME>, <GABBER> i <CHARNAME>.", nil},
+		{"other order", "<GABBER>: <CHARNAME>, <CHARNAME>!", nil},
+		{"missing", "Witaj <CHARNAME>, <GABBER>.", []string{"missing <CHARNAME>"}},
+		{"extra", "Witaj <CHARNAME>, <GABBER>, <CHARNAME> i <DAY>.", []string{"unexpected <DAY>"}},
+		{"misspelled", "Witaj <CHARNAM>, <GABBER> i <CHARNAME>.", []string{"<CHARNAM> should be <CHARNAME>"}},
+		{"lower case", "Witaj <charname>, <GABBER> i <CHARNAME>.", []string{"<charname> should be <CHARNAME>"}},
+		{"replaced", "Witaj <PLAYER1>, <GABBER> i <CHARNAME>.", []string{"missing <CHARNAME>", "unexpected <PLAYER1>"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tokenProblems("Hello <CHARNAME>, <GABBER> and <CHARNAME>.", tt.translated)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRun_Tokens(t *testing.T) {
+	dialogs, _ := parse(t)
+	tras := tra.TraByFile{"bdnpc": tra.NewTra(map[string]string{"1": "Hi <CHARNAME>.", "2": "Bye."})}
+	translations := csv.Translations{"bdnpc": {
+		"1": {Male: "Czesc <CHARNAME>.", Female: "Czesc <CHARNAM>.", Pos: helpers.Pos{File: "bdnpc.csv", Line: 2}},
+		"2": {Male: "Pa.", Pos: helpers.Pos{File: "bdnpc.csv", Line: 3}},
+	}}
+
+	var got []Finding
+	for _, f := range Run(dialogs, tras, Options{Translations: translations}) {
+		if f.Rule == RuleTokens {
+			got = append(got, f)
+		}
+	}
+	if len(got) != 1 {
+		t.Fatalf("expected one tokens finding, got %v", got)
+	}
+	f := got[0]
+	if f.Severity != Error || f.Pos.String() != "bdnpc.csv:2" ||
+		!strings.Contains(f.Msg, "female translation: <CHARNAM> should be <CHARNAME>") {
+		t.Fatalf("unexpected finding: %v", f)
+	}
+}
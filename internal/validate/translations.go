@@ -0,0 +1,86 @@
+package validate
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/tra"
+	helpers "github.com/maciejjwojcik/dlg2csv/internal/utils"
+)
+
+// genderedLanguages are target languages where a line addressing the
+// player often needs a female variant even when English has none, e.g.
+// Polish "Jesteś gotowy?" / "Jesteś gotowa?". Keys are language folder
+// names, as in the .tp2 and the game's lang folder.
+var genderedLanguages = map[string]bool{
+	"polish": true, "pl_pl": true,
+	"russian": true, "ru_ru": true,
+	"ukrainian": true, "uk_ua": true,
+	"czech": true, "cs_cz": true,
+	"slovak": true,
+	"french": true, "fr_fr": true,
+	"italian": true, "it_it": true,
+	"spanish": true, "es_es": true,
+	"portuguese": true, "brazilian": true, "pt_br": true,
+}
+
+// GenderedLanguage reports whether translations into the language folder
+// found anywhere in path (e.g. "lang/polish") may add female variants
+// the source doesn't have.
+func GenderedLanguage(path string) bool {
+	for _, part := range strings.Split(filepath.ToSlash(path), "/") {
+		if genderedLanguages[strings.ToLower(part)] {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckTranslations runs only the rules checking opts.Translations
+// (tokens, female-missing and female-extra), e.g. while importing.
+func CheckTranslations(tras tra.TraByFile, opts Options) []Finding {
+	var out []Finding
+	checkTranslations(tras, opts, reportTo(&out, opts))
+	sortFindings(out)
+	return out
+}
+
+// checkTranslations compares each translation with its source string.
+func checkTranslations(tras tra.TraByFile, opts Options,
+	report func(rule string, pos helpers.Pos, format string, args ...any)) {
+
+	traKeys := make([]string, 0, len(opts.Translations))
+	for k := range opts.Translations {
+		traKeys = append(traKeys, k)
+	}
+	sort.Strings(traKeys)
+
+	for _, k := range traKeys {
+		translated := opts.Translations[k]
+		ids := make([]string, 0, len(translated))
+		for id := range translated {
+			ids = append(ids, id)
+		}
+		tra.SortIDs(ids)
+
+		source := tras[k]
+		for _, id := range ids {
+			male, ok := source.Texts[id]
+			if !ok {
+				continue
+			}
+			t := translated[id]
+
+			checkTokens(id, k, "male", male, t.Male, t.Pos, report)
+			checkTokens(id, k, "female", source.FemaleSource(id), t.Female, t.Pos, report)
+
+			switch {
+			case source.HasFemale(id) && t.Female == "":
+				report(RuleFemaleMissing, t.Pos, "@%s of %s.tra has a female variant in the source but not in the translation", id, k)
+			case !source.HasFemale(id) && t.Female != "" && t.Female != t.Male && !opts.FemaleOptional:
+				report(RuleFemaleExtra, t.Pos, "@%s of %s.tra has a female translation but no female variant in the source", id, k)
+			}
+		}
+	}
+}
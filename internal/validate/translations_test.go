@@ -0,0 +1,57 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/csv"
+	"github.com/maciejjwojcik/dlg2csv/internal/tra"
+	helpers "github.com/maciejjwojcik/dlg2csv/internal/utils"
+)
+
+func TestGenderedLanguage(t *testing.T) {
+	for path, want := range map[string]bool{
+		"polish":            true,
+		"mymod/tra/russian": true,
+		"lang/pl_PL":        true,
+		"lang/en_US":        false,
+		"language/english":  false,
+		"german":            false,
+	} {
+		if got := GenderedLanguage(path); got != want {
+			t.Errorf("GenderedLanguage(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestCheckTranslations_Female(t *testing.T) {
+	source, err := tra.ParseReader(strings.NewReader(
+		"@1 = ~Ready, sir.~ ~Ready, madam.~\n"+
+			"@2 = ~Are you ready?~\n"+
+			"@3 = ~Same~ ~Same~\n"), "bdnpc.tra")
+	if err != nil {
+		t.Fatalf("ParseReader: %v", err)
+	}
+	tras := tra.TraByFile{"bdnpc": *source}
+	translations := csv.Translations{"bdnpc": {
+		"1": {Male: "Gotowy.", Pos: helpers.Pos{File: "bdnpc.csv", Line: 2}},
+		"2": {Male: "Gotowy?", Female: "Gotowa?", Pos: helpers.Pos{File: "bdnpc.csv", Line: 3}},
+		"3": {Male: "To samo", Pos: helpers.Pos{File: "bdnpc.csv", Line: 4}},
+	}}
+
+	got := CheckTranslations(tras, Options{Translations: translations})
+	if len(got) != 2 {
+		t.Fatalf("expected 2 findings, got %v", got)
+	}
+	if got[0].Rule != RuleFemaleMissing || got[0].Severity != Error || got[0].Pos.Line != 2 {
+		t.Fatalf("unexpected first finding: %v", got[0])
+	}
+	if got[1].Rule != RuleFemaleExtra || got[1].Severity != Warning || got[1].Pos.Line != 3 {
+		t.Fatalf("unexpected second finding: %v", got[1])
+	}
+
+	got = CheckTranslations(tras, Options{Translations: translations, FemaleOptional: true})
+	if len(got) != 1 || got[0].Rule != RuleFemaleMissing {
+		t.Fatalf("with FemaleOptional only female-missing is expected, got %v", got)
+	}
+}
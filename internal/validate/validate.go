@@ -0,0 +1,298 @@
+// Package validate checks a mod's .d and .tra files for problems that
+// would show up in game or in the translation: strings that don't exist,
+// strings nobody uses and transitions to dialogs or states that aren't there.
+package validate
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/csv"
+	"github.com/maciejjwojcik/dlg2csv/internal/d"
+	"github.com/maciejjwojcik/dlg2csv/internal/tra"
+	helpers "github.com/maciejjwojcik/dlg2csv/internal/utils"
+)
+
+// Severity of a finding.
+type Severity string
+
+const (
+	Error   Severity = "error"
+	Warning Severity = "warning"
+	Off     Severity = "off"
+)
+
+// Rule names, as used in Options.Rules and dlg2csv.yaml.
+const (
+	RuleMissingTra    = "missing-tra"    // @id used in a .d but defined in none of its .tra files
+	RuleUnusedTra     = "unused-tra"     // @id defined in a .tra but used by no .d file or setup code
+	RuleMissingStrref = "missing-strref" // #strref not found in dialog.tlk
+	RuleUnknownDialog = "unknown-dialog" // transition to a dialog neither the mod nor External defines
+	RuleMissingState  = "missing-state"  // GOTO to a named state the dialog doesn't have
+	RuleDuplicateTra  = "duplicate-tra"  // @id defined twice in the same .tra file
+	RuleTokens        = "tokens"         // translation with missing, extra or misspelled <TOKEN>s
+	RuleFemaleMissing = "female-missing" // source with a female variant translated without one
+	RuleFemaleExtra   = "female-extra"   // female translation of a source without one
+)
+
+// Defaults are the severities of the rules unless overridden.
+var Defaults = map[string]Severity{
+	RuleMissingTra:    Error,
+	RuleUnusedTra:     Warning,
+	RuleMissingStrref: Error,
+	RuleUnknownDialog: Warning,
+	RuleMissingState:  Error,
+	RuleDuplicateTra:  Warning,
+	RuleTokens:        Error,
+	RuleFemaleMissing: Error,
+	RuleFemaleExtra:   Warning,
+}
+
+// Finding is a problem found in the mod.
+type Finding struct {
+	Rule     string
+	Severity Severity
+	Pos      helpers.Pos
+	Msg      string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s: %s [%s]", f.Pos, f.Severity, f.Msg, f.Rule)
+}
+
+// Options configures Run.
+type Options struct {
+	// Rules overrides the severity of rules by name.
+	Rules map[string]Severity
+
+	// Tras maps .d files to their .tra files, as csv.Options.Tras.
+	Tras map[string][]string
+
+	// Strrefs resolves #strref references; missing-strref is only checked
+	// when it is set.
+	Strrefs interface {
+		Lookup(strref int) (string, bool)
+	}
+
+	// Used reports @ids used outside .d files, e.g. in setup code; they
+	// aren't reported as unused.
+	Used func(traKey string, id int) bool
+
+	// External lists dialogs of the base game or other mods that may be
+	// referenced without being defined by the mod.
+	External []string
+
+	// Dialogs reports whether a dialog exists in the game, usually from
+	// the override folder.
+	Dialogs func(resref string) bool
+
+	// Translations are the translated strings read back from the CSV
+	// files; tokens, female-missing and female-extra are only checked when
+	// they are set.
+	Translations csv.Translations
+
+	// FemaleOptional lets translations add female variants the source
+	// doesn't have, as languages with grammatical gender need (see
+	// GenderedLanguage); otherwise they are reported as female-extra.
+	FemaleOptional bool
+}
+
+// Rules returns the known rule names, sorted.
+func Rules() []string {
+	names := make([]string, 0, len(Defaults))
+	for n := range Defaults {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseSeverity checks a severity name.
+func ParseSeverity(s string) (Severity, error) {
+	switch sev := Severity(strings.ToLower(strings.TrimSpace(s))); sev {
+	case Error, Warning, Off:
+		return sev, nil
+	}
+	return "", fmt.Errorf("unknown severity %q (supported: %s, %s, %s)", s, Error, Warning, Off)
+}
+
+// Run checks the mod and returns the findings ordered by position.
+func Run(dialogs d.DByFile, tras tra.TraByFile, opts Options) []Finding {
+	var out []Finding
+	report := reportTo(&out, opts)
+
+	dKeys := make([]string, 0, len(dialogs))
+	for k := range dialogs {
+		dKeys = append(dKeys, k)
+	}
+	sort.Strings(dKeys)
+
+	// dialogs and state labels defined by the mod
+	states := map[string]map[string]bool{}
+	for _, k := range dKeys {
+		for _, o := range dialogs[k] {
+			dlg := strings.ToUpper(o.Dialog)
+			if states[dlg] == nil {
+				states[dlg] = map[string]bool{}
+			}
+			states[dlg][strings.ToLower(o.State)] = true
+		}
+	}
+	known := func(dlg string) bool {
+		dlg = strings.ToUpper(dlg)
+		if _, ok := states[dlg]; ok {
+			return true
+		}
+		for _, e := range opts.External {
+			if strings.EqualFold(e, dlg) {
+				return true
+			}
+		}
+		return opts.Dialogs != nil && opts.Dialogs(dlg)
+	}
+
+	used := map[string]map[string]bool{}
+	for _, k := range dKeys {
+		keys := opts.Tras[k]
+		if len(keys) == 0 {
+			keys = []string{k}
+		}
+		for _, o := range dialogs[k] {
+			switch {
+			case o.TraID != nil:
+				id := strconv.Itoa(*o.TraID)
+				found := false
+				for i := len(keys) - 1; i >= 0 && !found; i-- {
+					if _, ok := tras[keys[i]].Texts[id]; ok {
+						found = true
+						if used[keys[i]] == nil {
+							used[keys[i]] = map[string]bool{}
+						}
+						used[keys[i]][id] = true
+					}
+				}
+				if !found {
+					report(RuleMissingTra, o.Pos, "@%s is not defined in %s", id, strings.Join(traFiles(keys), ", "))
+				}
+			case o.StrRef != nil && opts.Strrefs != nil:
+				if _, ok := opts.Strrefs.Lookup(*o.StrRef); !ok {
+					report(RuleMissingStrref, o.Pos, "#%d is not in dialog.tlk", *o.StrRef)
+				}
+			}
+
+			checkTarget(o, known, states, report)
+		}
+	}
+
+	traKeys := make([]string, 0, len(tras))
+	for k := range tras {
+		traKeys = append(traKeys, k)
+	}
+	sort.Strings(traKeys)
+	for _, k := range traKeys {
+		t := tras[k]
+		for _, dup := range t.Duplicates {
+			if dup.Differs() {
+				report(RuleDuplicateTra, dup.Second, "@%s is already defined at line %d with a different text (%q, now %q); the last definition wins",
+					dup.ID, dup.First.Line, shorten(dup.FirstText), shorten(dup.SecondText))
+			} else {
+				report(RuleDuplicateTra, dup.Second, "@%s is already defined at line %d with the same text", dup.ID, dup.First.Line)
+			}
+		}
+		for _, id := range t.IDs() {
+			if used[k][id] {
+				continue
+			}
+			if n, err := strconv.Atoi(id); err == nil && opts.Used != nil && opts.Used(k, n) {
+				continue
+			}
+			pos := t.Pos[id]
+			if pos.File == "" {
+				pos.File = k + ".tra"
+			}
+			report(RuleUnusedTra, pos, "@%s is not used by any .d file", id)
+		}
+	}
+
+	if opts.Translations != nil {
+		checkTranslations(tras, opts, report)
+	}
+
+	sortFindings(out)
+	return out
+}
+
+// reportTo returns a function appending findings to out with the severity
+// set in opts, skipping rules that are off.
+func reportTo(out *[]Finding, opts Options) func(rule string, pos helpers.Pos, format string, args ...any) {
+	return func(rule string, pos helpers.Pos, format string, args ...any) {
+		sev, ok := opts.Rules[rule]
+		if !ok {
+			sev = Defaults[rule]
+		}
+		if sev != Off {
+			*out = append(*out, Finding{Rule: rule, Severity: sev, Pos: pos, Msg: fmt.Sprintf(format, args...)})
+		}
+	}
+}
+
+// sortFindings orders findings by position.
+func sortFindings(out []Finding) {
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i].Pos, out[j].Pos
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+}
+
+// checkTarget reports transitions to unknown dialogs, and GOTOs to named
+// states the mod doesn't define. Numeric states may be vanilla states of
+// an appended dialog, so only labels are checked.
+func checkTarget(o d.TextOccurrence, known func(string) bool, states map[string]map[string]bool,
+	report func(rule string, pos helpers.Pos, format string, args ...any)) {
+
+	typ := strings.ToUpper(o.ToType)
+	if typ != "GOTO" && typ != "EXTERN" && typ != "COPY_TRANS" {
+		return
+	}
+	dlg := o.Dialog
+	if o.ToDlg != nil {
+		dlg = *o.ToDlg
+	}
+	if typ != "GOTO" && !known(dlg) {
+		report(RuleUnknownDialog, o.Pos, "%s refers to unknown dialog %s", typ, dlg)
+		return
+	}
+
+	if o.ToState == nil || typ == "COPY_TRANS" {
+		return
+	}
+	state := strings.ToLower(*o.ToState)
+	if _, err := strconv.Atoi(state); err == nil {
+		return
+	}
+	if defined, ok := states[strings.ToUpper(dlg)]; ok && !defined[state] {
+		report(RuleMissingState, o.Pos, "%s %s:%s: no such state", typ, dlg, *o.ToState)
+	}
+}
+
+func traFiles(keys []string) []string {
+	out := make([]string, len(keys))
+	for i, k := range keys {
+		out[i] = k + ".tra"
+	}
+	return out
+}
+
+// shorten cuts long texts quoted in messages.
+func shorten(s string) string {
+	const maxText = 40
+	if r := []rune(s); len(r) > maxText {
+		return string(r[:maxText-3]) + "..."
+	}
+	return s
+}
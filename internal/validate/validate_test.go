@@ -0,0 +1,123 @@
+package validate
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/d"
+	"github.com/maciejjwojcik/dlg2csv/internal/tra"
+)
+
+const testD = `BEGIN BDNPC
+
+IF ~~ hello
+  SAY @1
+  IF ~~ THEN REPLY @2 GOTO bye
+  IF ~~ THEN REPLY @3 GOTO nowhere
+  IF ~~ THEN REPLY @4 EXTERN JAHEIJ 12
+  IF ~~ THEN REPLY @5 EXTERN BDOTHER 1
+  IF ~~ THEN REPLY @6 GOTO 7
+END
+
+IF ~~ bye
+  SAY #100
+  IF ~~ THEN REPLY @99 EXIT
+END
+`
+
+type tlk map[int]string
+
+func (t tlk) Lookup(strref int) (string, bool) {
+	s, ok := t[strref]
+	return s, ok
+}
+
+func parse(t *testing.T) (d.DByFile, tra.TraByFile) {
+	t.Helper()
+	occ, err := d.ParseReader(strings.NewReader(testD), "bdnpc.d")
+	if err != nil {
+		t.Fatalf("ParseReader: %v", err)
+	}
+	src := "@1 = ~old text~\n"
+	for _, id := range []string{"1", "2", "3", "4", "5", "6", "50"} {
+		src += "@" + id + " = ~text " + id + "~\n"
+	}
+	texts, err := tra.ParseReader(strings.NewReader(src), "bdnpc.tra")
+	if err != nil {
+		t.Fatalf("tra.ParseReader: %v", err)
+	}
+	return d.DByFile{"bdnpc": occ}, tra.TraByFile{"bdnpc": *texts}
+}
+
+func TestRun(t *testing.T) {
+	dialogs, tras := parse(t)
+
+	findings := Run(dialogs, tras, Options{External: []string{"jaheij"}, Strrefs: tlk{}})
+
+	want := map[string]Severity{
+		RuleMissingState:  Error,   // GOTO nowhere
+		RuleUnknownDialog: Warning, // EXTERN BDOTHER
+		RuleMissingStrref: Error,   // SAY #100
+		RuleMissingTra:    Error,   // REPLY @99
+		RuleUnusedTra:     Warning, // @50
+		RuleDuplicateTra:  Warning, // @1
+	}
+	got := map[string]Severity{}
+	for _, f := range findings {
+		if _, dup := got[f.Rule]; dup {
+			t.Fatalf("rule %s reported twice: %v", f.Rule, findings)
+		}
+		got[f.Rule] = f.Severity
+	}
+	if len(got) != len(want) {
+		t.Fatalf("findings:\n%v", findings)
+	}
+	for rule, sev := range want {
+		if got[rule] != sev {
+			t.Fatalf("%s: got %q, want %q\n%v", rule, got[rule], sev, findings)
+		}
+	}
+
+	for _, f := range findings {
+		if f.Rule == RuleMissingState && f.Pos.String() != "bdnpc.d:6" {
+			t.Fatalf("missing-state position: %v", f.Pos)
+		}
+		if f.Rule == RuleDuplicateTra && (f.Pos.String() != "bdnpc.tra:2" || !strings.Contains(f.Msg, "line 1 with a different text")) {
+			t.Fatalf("duplicate-tra finding: %v", f)
+		}
+	}
+}
+
+func TestRun_RuleOverrides(t *testing.T) {
+	dialogs, tras := parse(t)
+
+	findings := Run(dialogs, tras, Options{
+		Rules: map[string]Severity{RuleUnusedTra: Off, RuleUnknownDialog: Error},
+		Dialogs: func(resref string) bool {
+			return resref == "JAHEIJ"
+		},
+		Used: func(traKey string, id int) bool { return id == 50 },
+	})
+
+	for _, f := range findings {
+		switch f.Rule {
+		case RuleUnusedTra:
+			t.Fatalf("unused-tra is off: %v", f)
+		case RuleUnknownDialog:
+			if f.Severity != Error || !strings.Contains(f.Msg, "BDOTHER") {
+				t.Fatalf("unexpected finding: %v", f)
+			}
+		case RuleMissingStrref:
+			t.Fatalf("missing-strref needs a dialog.tlk: %v", f)
+		}
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	if s, err := ParseSeverity(" Warning "); err != nil || s != Warning {
+		t.Fatalf("ParseSeverity = %q, %v", s, err)
+	}
+	if _, err := ParseSeverity("fatal"); err == nil {
+		t.Fatalf("expected error")
+	}
+}
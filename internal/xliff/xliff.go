@@ -0,0 +1,398 @@
+// Package xliff reads and writes XLIFF 2.0 files for CAT tools. Each .tra
+// string is a <unit> with its @id as the id, followed by a unit with the
+// id suffixed "-female" for strings with a female variant, as the variants
+// are separate sentences to translate. Notes tell the translator where the
+// string is used in the dialogs.
+package xliff
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/csv"
+	"github.com/maciejjwojcik/dlg2csv/internal/d"
+	"github.com/maciejjwojcik/dlg2csv/internal/tra"
+)
+
+// Namespace is the XLIFF 2.0 core namespace.
+const Namespace = "urn:oasis:names:tc:xliff:document:2.0"
+
+// femaleSuffix marks the unit id of the female variant of a string.
+const femaleSuffix = "-female"
+
+// Segment states: a target of an initial segment is a draft, e.g. a
+// machine translation not yet confirmed in the CAT tool.
+const (
+	stateInitial    = "initial"
+	stateTranslated = "translated"
+)
+
+// Note categories, one note per line using the string.
+const (
+	NoteDialog    = "dialog"    // dialog of the line, e.g. BDNPC
+	NoteState     = "state"     // its state
+	NoteSpeaker   = "speaker"   // speaking dialog, PC for replies
+	NoteCondition = "condition" // trigger of the line
+	NoteGoto      = "goto"      // where a reply leads, as in the CSV Goto column
+	NoteVariant   = "variant"   // "female" on the unit of a female variant
+)
+
+// Note is a note on a unit.
+type Note struct {
+	Category string
+	Text     string
+}
+
+// Unit is a .tra string, or its female variant.
+type Unit struct {
+	File   string // .tra file, e.g. "bdnpc.tra"
+	ID     string // @id without the @
+	Female bool   // the female variant of the string
+
+	Notes  []Note
+	Source string
+	Target string // empty if not translated
+}
+
+// key is the .tra file base name, as in tra.TraByFile.
+func (u Unit) key() string {
+	return strings.ToLower(strings.TrimSuffix(u.File, filepath.Ext(u.File)))
+}
+
+// Document is the content of an XLIFF file.
+type Document struct {
+	SourceLang string // e.g. "en"
+	TargetLang string // e.g. "pl"; may be empty for a file not translated yet
+	Units      []Unit
+}
+
+// Units returns a unit for each string of source, with notes on the lines
+// using it as returned by csv.Usages. The targets are filled from
+// translations, which may be nil. A female unit follows the strings with
+// a female variant in the source or in the translation.
+func Units(source tra.TraByFile, usages map[string]map[string][]d.TextOccurrence, translations csv.Translations) []Unit {
+	var units []Unit
+	for _, k := range slices.Sorted(maps.Keys(source)) {
+		s := source[k]
+		for _, id := range s.IDs() {
+			var notes []Note
+			for _, o := range usages[k][id] {
+				notes = append(notes, notesOf(o)...)
+			}
+
+			t := translations[k][id]
+			units = append(units, Unit{File: k + ".tra", ID: id, Notes: notes, Source: s.Texts[id], Target: t.Male})
+			if s.HasFemale(id) || t.Female != "" {
+				notes = append(slices.Clip(notes), Note{NoteVariant, "female"})
+				units = append(units, Unit{File: k + ".tra", ID: id, Female: true, Notes: notes, Source: s.FemaleSource(id), Target: t.Female})
+			}
+		}
+	}
+	return units
+}
+
+// notesOf describes a line using a string.
+func notesOf(o d.TextOccurrence) []Note {
+	speaker := o.SpeakerDlg
+	switch o.Kind {
+	case d.KindPC:
+		speaker = "PC"
+	case d.KindJournal:
+		speaker = "JOURNAL"
+	}
+	notes := []Note{{NoteDialog, o.Dialog}, {NoteState, o.State}}
+	if speaker != "" {
+		notes = append(notes, Note{NoteSpeaker, speaker})
+	}
+	if o.Condition != "" {
+		notes = append(notes, Note{NoteCondition, o.Condition})
+	}
+	if to := csv.FormatGoto(o); to != "" {
+		notes = append(notes, Note{NoteGoto, to})
+	}
+	return notes
+}
+
+// Translations returns the translated units with a file and @id still
+// holding the same source text in source, e.g. to write translated .tra
+// files, and the number of translated units skipped as their string
+// changed or is gone, or as they are a female variant without the male
+// one. The positions of the translations name the XLIFF file, name.
+func (doc Document) Translations(source tra.TraByFile, name string) (csv.Translations, int) {
+	out := csv.Translations{}
+	skipped := 0
+	for _, u := range doc.Units {
+		if u.ID == "" || u.Target == "" {
+			continue
+		}
+		k := u.key()
+		s, ok := source[k]
+		if !ok {
+			skipped++
+			continue
+		}
+		want, ok := s.Texts[u.ID]
+		if u.Female {
+			want = s.FemaleSource(u.ID)
+		}
+		if !ok || want != u.Source {
+			skipped++
+			continue
+		}
+
+		if out[k] == nil {
+			out[k] = map[string]csv.Translation{}
+		}
+		t := out[k][u.ID]
+		if u.Female {
+			t.Female = u.Target
+		} else {
+			t.Male = u.Target
+		}
+		t.Pos.File = name
+		out[k][u.ID] = t
+	}
+	return out, skipped + out.DropFemaleOnly()
+}
+
+// XLIFF 2.0 document structure.
+type document struct {
+	XMLName xml.Name `xml:"urn:oasis:names:tc:xliff:document:2.0 xliff"`
+	Version string   `xml:"version,attr"`
+	SrcLang string   `xml:"srcLang,attr"`
+	TrgLang string   `xml:"trgLang,attr,omitempty"`
+	Files   []file   `xml:"file"`
+}
+
+type file struct {
+	ID       string  `xml:"id,attr"`
+	Original string  `xml:"original,attr,omitempty"`
+	Units    []unit  `xml:"unit"`
+	Groups   []group `xml:"group"`
+}
+
+// group is read to find the units of files reorganized by other tools.
+type group struct {
+	Units  []unit  `xml:"unit"`
+	Groups []group `xml:"group"`
+}
+
+type unit struct {
+	ID       string        `xml:"id,attr"`
+	Name     string        `xml:"name,attr,omitempty"`
+	Space    string        `xml:"http://www.w3.org/XML/1998/namespace space,attr,omitempty"`
+	Notes    *notes        `xml:"notes,omitempty"`
+	Data     *originalData `xml:"originalData,omitempty"`
+	Segments []segment     `xml:",any"` // <segment>s, and <ignorable>s between them
+}
+
+// originalData is only read: dlg2csv writes the tokens as text.
+type originalData struct {
+	Data []data `xml:"data"`
+}
+
+// data is the original code of a placeholder, e.g. <CHARNAME> for
+// <ph id="1" dataRef="d1"/>.
+type data struct {
+	ID   string `xml:"id,attr"`
+	Text string `xml:",chardata"`
+}
+
+// notes is left out of units without notes: XLIFF requires at least one
+// <note> in it.
+type notes struct {
+	Notes []note `xml:"note"`
+}
+
+type note struct {
+	Category string `xml:"category,attr,omitempty"`
+	Text     string `xml:",chardata"`
+}
+
+type segment struct {
+	XMLName xml.Name
+	State   string  `xml:"state,attr,omitempty"`
+	Source  content `xml:"source"`
+	Target  content `xml:"target,omitempty"`
+}
+
+// content is the text of a <source> or <target>. Reading it keeps the
+// text of inline elements, and the code of placeholders written by CAT
+// tools: the <originalData> they refer to, as in <ph id="1" dataRef="d1"/>,
+// or else their equivalent text, as in <ph id="1" equiv="&lt;CHARNAME&gt;"/>.
+// References are kept as dataRef markers until the unit is read whole.
+type content string
+
+func (c *content) UnmarshalXML(dec *xml.Decoder, start xml.StartElement) error {
+	var b strings.Builder
+	var ends []string // code written at the end of each open element
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.CharData:
+			b.Write(t)
+		case xml.StartElement:
+			attrs := map[string]string{}
+			for _, a := range t.Attr {
+				attrs[a.Name.Local] = a.Value
+			}
+			b.WriteString(code(attrs["dataRef"]+attrs["dataRefStart"], attrs["equiv"]+attrs["equivStart"]))
+			ends = append(ends, code(attrs["dataRefEnd"], attrs["equivEnd"]))
+		case xml.EndElement:
+			if len(ends) == 0 {
+				*c = content(b.String())
+				return nil
+			}
+			b.WriteString(ends[len(ends)-1])
+			ends = ends[:len(ends)-1]
+		}
+	}
+}
+
+// code is a dataRef marker for ref, or equiv without a ref.
+func code(ref, equiv string) string {
+	if ref == "" {
+		return equiv
+	}
+	return dataRef + ref + dataRef
+}
+
+// dataRef delimits a reference to <originalData> in read content; .tra
+// text has no NUL.
+const dataRef = "\x00"
+
+// resolve replaces the dataRef markers in s with the original data.
+func resolve(s string, data map[string]string) string {
+	if !strings.Contains(s, dataRef) {
+		return s
+	}
+	var b strings.Builder
+	for i, part := range strings.Split(s, dataRef) {
+		if i%2 == 1 {
+			part = data[part]
+		}
+		b.WriteString(part)
+	}
+	return b.String()
+}
+
+// Write writes doc as an XLIFF 2.0 document, a <file> per .tra file.
+func Write(w io.Writer, doc Document) error {
+	out := document{Version: "2.0", SrcLang: doc.SourceLang, TrgLang: doc.TargetLang}
+	files := map[string]int{}
+	for _, u := range doc.Units {
+		i, ok := files[u.File]
+		if !ok {
+			i = len(out.Files)
+			files[u.File] = i
+			out.Files = append(out.Files, file{ID: fmt.Sprintf("f%d", i+1), Original: u.File})
+		}
+
+		x := unit{ID: u.ID, Name: "@" + u.ID, Space: "preserve"}
+		if u.Female {
+			x.ID, x.Name = u.ID+femaleSuffix, x.Name+"/female"
+		}
+		if len(u.Notes) > 0 {
+			x.Notes = &notes{}
+			for _, n := range u.Notes {
+				x.Notes.Notes = append(x.Notes.Notes, note(n))
+			}
+		}
+		x.Segments = []segment{segmentOf(u.Source, u.Target)}
+		out.Files[i].Units = append(out.Files[i].Units, x)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func segmentOf(source, target string) segment {
+	x := segment{XMLName: xml.Name{Local: "segment"}, State: stateInitial, Source: content(source), Target: content(target)}
+	if target != "" {
+		x.State = stateTranslated
+	}
+	return x
+}
+
+// Read reads an XLIFF 2.x document. The segments of a unit are joined with
+// the <ignorable>s between them, as CAT tools may split a unit into
+// sentences. A unit with a draft, a target in an initial segment, is not
+// translated.
+func Read(r io.Reader) (Document, error) {
+	var x document
+	if err := xml.NewDecoder(r).Decode(&x); err != nil {
+		return Document{}, fmt.Errorf("read XLIFF: %w", err)
+	}
+	if !strings.HasPrefix(x.Version, "2.") {
+		return Document{}, fmt.Errorf("read XLIFF: version %q, want 2.0", x.Version)
+	}
+
+	doc := Document{SourceLang: x.SrcLang, TargetLang: x.TrgLang}
+	for _, f := range x.Files {
+		name := f.Original
+		if name == "" {
+			name = f.ID
+		}
+		units := f.Units
+		for _, g := range f.Groups {
+			units = append(units, g.units()...)
+		}
+		for _, xu := range units {
+			u := Unit{File: name, ID: strings.TrimPrefix(xu.ID, "@")}
+			u.ID, u.Female = strings.CutSuffix(u.ID, femaleSuffix)
+			if xu.Notes != nil {
+				for _, n := range xu.Notes.Notes {
+					u.Notes = append(u.Notes, Note(n))
+				}
+			}
+			data := map[string]string{}
+			if xu.Data != nil {
+				for _, d := range xu.Data.Data {
+					data[d.ID] = d.Text
+				}
+			}
+			translated, draft := false, false
+			for _, s := range xu.Segments {
+				u.Source += resolve(string(s.Source), data)
+				switch {
+				case s.XMLName.Local == "ignorable" && s.Target == "":
+					s.Target = s.Source // the same in both languages
+				case s.Target != "" && s.State == stateInitial:
+					draft = true
+				case s.Target != "":
+					translated = true
+				}
+				u.Target += resolve(string(s.Target), data)
+			}
+			if !translated || draft {
+				u.Target = ""
+			}
+			doc.Units = append(doc.Units, u)
+		}
+	}
+	return doc, nil
+}
+
+func (g group) units() []unit {
+	units := g.Units
+	for _, sub := range g.Groups {
+		units = append(units, sub.units()...)
+	}
+	return units
+}
@@ -0,0 +1,207 @@
+package xliff
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/maciejjwojcik/dlg2csv/internal/csv"
+	"github.com/maciejjwojcik/dlg2csv/internal/fixture"
+)
+
+func TestWriteRead_RoundTrip(t *testing.T) {
+	dialogs, source, target := fixture.Dialogs(t), fixture.Tra(t, "english"), fixture.Tra(t, "polish")
+
+	doc := Document{SourceLang: "en", TargetLang: "pl",
+		Units: Units(source, csv.Usages(dialogs, source, nil), csv.TraTranslations(source, target))}
+	if len(doc.Units) != 4 {
+		t.Fatalf("expected a unit per string and one for the female variant, got %+v", doc.Units)
+	}
+	if u := doc.Units[1]; !u.Female || u.Source != "Ready, my lady?" || u.Target != "Gotowa, <CHARNAME>?" {
+		t.Fatalf("female unit of @1: %+v", u)
+	}
+	if u := doc.Units[3]; u.Target != "" || u.Notes != nil {
+		t.Fatalf("@3 is unused and untranslated: %+v", u)
+	}
+
+	var buf bytes.Buffer
+	if err := Write(&buf, doc); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`<xliff xmlns="urn:oasis:names:tc:xliff:document:2.0" version="2.0" srcLang="en" trgLang="pl">`,
+		`<file id="f1" original="bdnpc.tra">`,
+		`<unit id="1" name="@1" xml:space="preserve">`,
+		`<note category="condition">Global(&#34;Met&#34;,&#34;LOCALS&#34;,0)</note>`,
+		`<note category="speaker">PC</note>`,
+		`<note category="goto">hello</note>`,
+		`<unit id="1-female" name="@1/female" xml:space="preserve">`,
+		`<note category="variant">female</note>`,
+		`<segment state="translated">`,
+		`<source>  Bye &amp; farewell.</source>`,
+		// an unused string has no <notes>, which must not be empty
+		`<unit id="3" name="@3" xml:space="preserve">
+      <segment state="initial">`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("output lacks %s:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "<notes></notes>") {
+		t.Fatalf("output has an empty <notes>:\n%s", out)
+	}
+
+	got, err := Read(strings.NewReader(out))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if !reflect.DeepEqual(got, doc) {
+		t.Fatalf("round trip:\ngot  %+v\nwant %+v", got, doc)
+	}
+
+	translations, stale := got.Translations(source, "pl.xlf")
+	if stale != 0 {
+		t.Fatalf("stale = %d", stale)
+	}
+	want := csv.Translations{"bdnpc": {
+		"1": {Male: "Gotowy, <CHARNAME>?", Female: "Gotowa, <CHARNAME>?"},
+		"2": {Male: "  Żegnaj."},
+	}}
+	for _, ids := range want {
+		for id, tr := range ids {
+			tr.Pos.File = "pl.xlf"
+			ids[id] = tr
+		}
+	}
+	if !reflect.DeepEqual(translations, want) {
+		t.Fatalf("Translations:\ngot  %+v\nwant %+v", translations, want)
+	}
+
+	// the source of @2 changed since the export
+	changed := fixture.ParseTra(t, "@1 = ~Ready, <CHARNAME>?~ ~Ready, my lady?~\n@2 = ~Farewell.~\n")
+	translations, stale = got.Translations(changed, "pl.xlf")
+	if stale != 1 || len(translations["bdnpc"]) != 1 {
+		t.Fatalf("expected @2 skipped, got %v, stale %d", translations, stale)
+	}
+}
+
+func TestDocument_Translations_FemaleOnly(t *testing.T) {
+	doc := Document{Units: []Unit{
+		{File: "bdnpc.tra", ID: "1", Source: "Ready, <CHARNAME>?"},
+		{File: "bdnpc.tra", ID: "1", Female: true, Source: "Ready, my lady?", Target: "Gotowa?"},
+	}}
+
+	translations, skipped := doc.Translations(fixture.Tra(t, "english"), "pl.xlf")
+	if len(translations) != 0 || skipped != 1 {
+		t.Fatalf("a female target alone should be skipped, got %+v (skipped %d)", translations, skipped)
+	}
+}
+
+func TestRead_OtherTools(t *testing.T) {
+	const src = `<?xml version="1.0" encoding="UTF-8"?>
+<xliff xmlns="urn:oasis:names:tc:xliff:document:2.0" version="2.1" srcLang="en-US" trgLang="pl-PL">
+  <file id="bdnpc.tra">
+    <group id="g1">
+      <unit id="@7">
+        <segment>
+          <source>Hi, <ph id="1" equiv="&lt;CHARNAME&gt;"/>.</source>
+          <target>Cześć, <ph id="1" equiv="&lt;CHARNAME&gt;"/>.</target>
+        </segment>
+        <ignorable>
+          <source> </source>
+        </ignorable>
+        <segment>
+          <source>Bye.</source>
+          <target>Pa.</target>
+        </segment>
+      </unit>
+    </group>
+  </file>
+</xliff>`
+	doc, err := Read(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	want := Document{SourceLang: "en-US", TargetLang: "pl-PL", Units: []Unit{{
+		File: "bdnpc.tra", ID: "7",
+		Source: "Hi, <CHARNAME>. Bye.", Target: "Cześć, <CHARNAME>. Pa.",
+	}}}
+	if !reflect.DeepEqual(doc, want) {
+		t.Fatalf("Read:\ngot  %+v\nwant %+v", doc, want)
+	}
+
+	if _, err := Read(strings.NewReader(`<xliff version="1.2"><file/></xliff>`)); err == nil {
+		t.Fatalf("expected an error for XLIFF 1.2")
+	}
+}
+
+func TestRead_Placeholders(t *testing.T) {
+	const src = `<?xml version="1.0" encoding="UTF-8"?>
+<xliff xmlns="urn:oasis:names:tc:xliff:document:2.0" version="2.0" srcLang="en" trgLang="pl">
+  <file id="f1" original="bdnpc.tra">
+    <unit id="1">
+      <originalData>
+        <data id="d1">&lt;CHARNAME&gt;</data>
+        <data id="d2">&lt;i&gt;</data>
+        <data id="d3">&lt;/i&gt;</data>
+      </originalData>
+      <segment state="translated">
+        <source>Ready, <ph id="1" dataRef="d1"/>?</source>
+        <target><pc id="2" dataRefStart="d2" dataRefEnd="d3">Gotowy</pc>, <ph id="1" dataRef="d1"/>?</target>
+      </segment>
+    </unit>
+    <unit id="2">
+      <segment state="translated">
+        <source><ph id="1" equiv="&lt;PRO_HESHE&gt;"/> left.</source>
+        <target><ph id="1" disp="PRO_HESHE" equiv="&lt;PRO_HESHE&gt;"/> odszedł.</target>
+      </segment>
+    </unit>
+  </file>
+</xliff>`
+	doc, err := Read(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	want := []Unit{
+		{File: "bdnpc.tra", ID: "1", Source: "Ready, <CHARNAME>?", Target: "<i>Gotowy</i>, <CHARNAME>?"},
+		{File: "bdnpc.tra", ID: "2", Source: "<PRO_HESHE> left.", Target: "<PRO_HESHE> odszedł."},
+	}
+	if !reflect.DeepEqual(doc.Units, want) {
+		t.Fatalf("Read:\ngot  %+v\nwant %+v", doc.Units, want)
+	}
+}
+
+func TestRead_State(t *testing.T) {
+	const src = `<?xml version="1.0" encoding="UTF-8"?>
+<xliff xmlns="urn:oasis:names:tc:xliff:document:2.0" version="2.0" srcLang="en" trgLang="pl">
+  <file id="f1" original="bdnpc.tra">
+    <unit id="1">
+      <segment state="final"><source>Ready?</source><target>Gotowy?</target></segment>
+    </unit>
+    <unit id="2">
+      <segment><source>Bye.</source><target>Pa.</target></segment>
+    </unit>
+    <unit id="3">
+      <segment state="initial"><source>Sword</source><target>Miecz</target></segment>
+    </unit>
+    <unit id="4">
+      <segment state="reviewed"><source>Big.</source><target>Duży.</target></segment>
+      <segment state="initial"><source>Shield.</source><target>Tarcza.</target></segment>
+    </unit>
+  </file>
+</xliff>`
+	doc, err := Read(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	got := map[string]string{}
+	for _, u := range doc.Units {
+		got[u.ID] = u.Target
+	}
+	want := map[string]string{"1": "Gotowy?", "2": "Pa.", "3": "", "4": ""}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("targets = %v, want %v (drafts in initial segments are not translations)", got, want)
+	}
+}